@@ -2,6 +2,7 @@ package agent
 
 import (
 	"log"
+	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -180,3 +181,114 @@ func (a *trackingAccumulator) onDelivery(info telegraf.DeliveryInfo) {
 		panic("channel is full")
 	}
 }
+
+// abandonableAccumulator discards the metrics added by a gather once it has
+// been abandoned, so that a gather which never returns cannot send metrics
+// after the agent has closed its channels.
+type abandonableAccumulator struct {
+	telegraf.Accumulator
+
+	mu        sync.RWMutex
+	abandoned bool
+}
+
+func newAbandonableAccumulator(acc telegraf.Accumulator) *abandonableAccumulator {
+	return &abandonableAccumulator{Accumulator: acc}
+}
+
+// abandon discards all further metrics, it waits for metrics being added to
+// be sent.
+func (ac *abandonableAccumulator) abandon() {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.abandoned = true
+}
+
+func (ac *abandonableAccumulator) AddFields(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	if !ac.abandoned {
+		ac.Accumulator.AddFields(measurement, fields, tags, t...)
+	}
+}
+
+func (ac *abandonableAccumulator) AddGauge(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	if !ac.abandoned {
+		ac.Accumulator.AddGauge(measurement, fields, tags, t...)
+	}
+}
+
+func (ac *abandonableAccumulator) AddCounter(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	if !ac.abandoned {
+		ac.Accumulator.AddCounter(measurement, fields, tags, t...)
+	}
+}
+
+func (ac *abandonableAccumulator) AddSummary(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	if !ac.abandoned {
+		ac.Accumulator.AddSummary(measurement, fields, tags, t...)
+	}
+}
+
+func (ac *abandonableAccumulator) AddHistogram(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	if !ac.abandoned {
+		ac.Accumulator.AddHistogram(measurement, fields, tags, t...)
+	}
+}
+
+func (ac *abandonableAccumulator) AddMetric(m telegraf.Metric) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	if ac.abandoned {
+		m.Drop()
+		return
+	}
+	ac.Accumulator.AddMetric(m)
+}
+
+func (ac *abandonableAccumulator) WithTracking(maxTracked int) telegraf.TrackingAccumulator {
+	return &trackingAccumulator{
+		Accumulator: ac,
+		delivered:   make(chan telegraf.DeliveryInfo, maxTracked),
+	}
+}
@@ -2,22 +2,68 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/internal/config"
+	"github.com/influxdata/telegraf/internal/cron"
 	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/logger"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 )
 
+// processorFlushInterval is how often the metrics output by service
+// processors are collected.
+const processorFlushInterval = 100 * time.Millisecond
+
+// errGatherBusy is returned for a gather skipped because the previous gather
+// timed out and did not return yet.
+var errGatherBusy = errors.New("still running a timed out gather")
+
 // Agent runs a set of plugins.
 type Agent struct {
 	Config *config.Config
+
+	// mu guards the plugin lists in Config, which are replaced by Reload.
+	mu sync.RWMutex
+
+	// reloadMu prevents plugins from being started by Reload while the
+	// agent is starting or stopping its inputs.
+	reloadMu sync.Mutex
+
+	inputs      *group
+	aggregators *group
+	outputs     *group
+
+	// gatherRequests are read by the goroutine of each input, to gather it
+	// on request of the management API.  Guarded by reloadMu.
+	gatherRequests map[*models.RunningInput]chan gatherRequest
+
+	inputC       chan telegraf.Metric
+	aggregationC chan telegraf.Metric
+
+	// stopped holds the metrics returned by the service processors removed
+	// by a reload, by stage, until the next flush of the stage.
+	stoppedMu sync.Mutex
+	stopped   map[string][]telegraf.Metric
+
+	// failover is only used by the goroutine adding metrics to outputs.
+	failover failover
+
+	// pipelines run the named pipelines of the configuration.
+	pipelines []*Agent
+
+	// statusOutputs holds the running []*models.RunningOutput, it is read
+	// without locking by outputs reporting on the other outputs.
+	statusOutputs atomic.Value
 }
 
 // NewAgent returns an Agent for the given Config.
@@ -25,10 +71,17 @@ func NewAgent(config *config.Config) (*Agent, error) {
 	a := &Agent{
 		Config: config,
 	}
+	for _, pipeline := range config.Pipelines {
+		a.pipelines = append(a.pipelines, &Agent{Config: pipeline})
+	}
 	return a, nil
 }
 
 // Run starts and runs the Agent until the context is done.
+//
+// The main configuration and each of its pipelines run with their own
+// channels, so that a blocked output only holds back its own pipeline.  When
+// a pipeline fails all of them are stopped.
 func (a *Agent) Run(ctx context.Context) error {
 	log.Printf("I! [agent] Config: Interval:%s, Quiet:%#v, Hostname:%#v, "+
 		"Flush Interval:%s",
@@ -38,7 +91,70 @@ func (a *Agent) Run(ctx context.Context) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
+	configHash.Set(a.Config.Hash())
+
+	var listener net.Listener
+	if a.Config.Agent.APIAddress != "" {
+		var err error
+		listener, err = net.Listen("tcp", a.Config.Agent.APIAddress)
+		if err != nil {
+			return fmt.Errorf("starting management API: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
+	var wg sync.WaitGroup
+	var once sync.Once
+	var runErr error
+	for _, p := range a.allPipelines() {
+		wg.Add(1)
+		go func(p *Agent) {
+			defer wg.Done()
+
+			if p.Config.Pipeline != "" {
+				log.Printf("I! [agent] Starting pipeline %s", p.Config.Pipeline)
+			}
+			err := p.runPipeline(ctx)
+			if err != nil {
+				once.Do(func() {
+					runErr = err
+					cancel()
+				})
+			}
+		}(p)
+	}
+
+	if listener != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := a.runAPI(ctx, listener)
+			if err != nil {
+				log.Printf("E! [agent] Error running management API: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	if runErr != nil {
+		return runErr
+	}
+
+	log.Printf("D! [agent] Stopped Successfully")
+	return nil
+}
+
+// allPipelines returns the agent followed by the agents of its pipelines.
+func (a *Agent) allPipelines() []*Agent {
+	return append([]*Agent{a}, a.pipelines...)
+}
+
+// runPipeline runs the plugins of a single pipeline until the context is
+// done.
+func (a *Agent) runPipeline(ctx context.Context) error {
 	log.Printf("D! [agent] Connecting outputs")
 	err := a.connectOutputs(ctx)
 	if err != nil {
@@ -51,22 +167,38 @@ func (a *Agent) Run(ctx context.Context) error {
 
 	startTime := time.Now()
 
+	a.reloadMu.Lock()
 	log.Printf("D! [agent] Starting service inputs")
 	err = a.startServiceInputs(ctx, inputC)
 	if err != nil {
+		a.reloadMu.Unlock()
 		return err
 	}
 
-	var wg sync.WaitGroup
+	a.inputC = inputC
+	a.aggregationC = make(chan telegraf.Metric, 100)
+	a.inputs = newGroup(ctx)
+	a.aggregators = newGroup(context.Background())
+	a.outputs = newGroup(context.Background())
 
-	src := inputC
-	dst := inputC
+	for _, input := range a.Config.Inputs {
+		a.startInput(startTime, input)
+	}
+	for _, agg := range a.Config.Aggregators {
+		a.startAggregator(startTime, agg)
+	}
+	for _, output := range a.Config.Outputs {
+		a.startOutput(startTime, output)
+	}
+	a.reloadMu.Unlock()
+
+	var wg sync.WaitGroup
 
 	wg.Add(1)
 	go func(dst chan telegraf.Metric) {
 		defer wg.Done()
 
-		err := a.runInputs(ctx, startTime, dst)
+		err := a.runInputs(ctx)
 		if err != nil {
 			log.Printf("E! [agent] Error running inputs: %v", err)
 		}
@@ -76,55 +208,43 @@ func (a *Agent) Run(ctx context.Context) error {
 
 		close(dst)
 		log.Printf("D! [agent] Input channel closed")
-	}(dst)
-
-	src = dst
-
-	if len(a.Config.Processors) > 0 {
-		dst = procC
-
-		wg.Add(1)
-		go func(src, dst chan telegraf.Metric) {
-			defer wg.Done()
-
-			err := a.runProcessors(src, dst)
-			if err != nil {
-				log.Printf("E! [agent] Error running processors: %v", err)
-			}
-			close(dst)
-			log.Printf("D! [agent] Processor channel closed")
-		}(src, dst)
+	}(inputC)
 
-		src = dst
-	}
-
-	if len(a.Config.Aggregators) > 0 {
-		dst = outputC
+	// Processors and aggregators always run, even when none are configured,
+	// so that they can be added when the configuration is reloaded.
+	wg.Add(1)
+	go func(src, dst chan telegraf.Metric) {
+		defer wg.Done()
 
-		wg.Add(1)
-		go func(src, dst chan telegraf.Metric) {
-			defer wg.Done()
+		err := a.runProcessors(src, dst)
+		if err != nil {
+			log.Printf("E! [agent] Error running processors: %v", err)
+		}
+		close(dst)
+		log.Printf("D! [agent] Processor channel closed")
+	}(inputC, procC)
 
-			err := a.runAggregators(startTime, src, dst)
-			if err != nil {
-				log.Printf("E! [agent] Error running aggregators: %v", err)
-			}
-			close(dst)
-			log.Printf("D! [agent] Output channel closed")
-		}(src, dst)
+	wg.Add(1)
+	go func(src, dst chan telegraf.Metric) {
+		defer wg.Done()
 
-		src = dst
-	}
+		err := a.runAggregators(src, dst)
+		if err != nil {
+			log.Printf("E! [agent] Error running aggregators: %v", err)
+		}
+		close(dst)
+		log.Printf("D! [agent] Output channel closed")
+	}(procC, outputC)
 
 	wg.Add(1)
 	go func(src chan telegraf.Metric) {
 		defer wg.Done()
 
-		err := a.runOutputs(startTime, src)
+		err := a.runOutputs(ctx, src)
 		if err != nil {
 			log.Printf("E! [agent] Error running outputs: %v", err)
 		}
-	}(src)
+	}(outputC)
 
 	wg.Wait()
 
@@ -134,20 +254,31 @@ func (a *Agent) Run(ctx context.Context) error {
 		return err
 	}
 
-	log.Printf("D! [agent] Stopped Successfully")
 	return nil
 }
 
 // Test runs the inputs once and prints the output to stdout in line protocol.
-func (a *Agent) Test(ctx context.Context) error {
+//
+// When wait is zero the metrics are printed as gathered and service inputs
+// are skipped.  Otherwise service inputs are started and collect metrics for
+// the wait duration before they are stopped, and all metrics are passed
+// through the processors and aggregators before they are printed.  The
+// pipelines are tested one after the other.
+func (a *Agent) Test(ctx context.Context, wait time.Duration) error {
+	for _, p := range a.allPipelines() {
+		if err := p.testPipeline(ctx, wait); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testPipeline runs the inputs of a single pipeline once.
+func (a *Agent) testPipeline(ctx context.Context, wait time.Duration) error {
 	var wg sync.WaitGroup
-	metricC := make(chan telegraf.Metric)
-	nulC := make(chan telegraf.Metric)
-	defer func() {
-		close(metricC)
-		close(nulC)
-		wg.Wait()
-	}()
+	metricC := make(chan telegraf.Metric, 100)
+	aggC := make(chan telegraf.Metric, 100)
+	outputC := make(chan telegraf.Metric, 100)
 
 	wg.Add(1)
 	go func() {
@@ -155,7 +286,8 @@ func (a *Agent) Test(ctx context.Context) error {
 
 		s := influx.NewSerializer()
 		s.SetFieldSortOrder(influx.SortFields)
-		for metric := range metricC {
+		for metric := range outputC {
+			redactMetric(metric)
 			octets, err := s.Serialize(metric)
 			if err == nil {
 				fmt.Print("> ", string(octets))
@@ -164,28 +296,145 @@ func (a *Agent) Test(ctx context.Context) error {
 		}
 	}()
 
-	wg.Add(1)
+	// addProcessed adds the metrics of the input stage processors to the
+	// aggregators, and forwards the others through the output stage.
+	addProcessed := func(metrics []telegraf.Metric) {
+		for _, metric := range metrics {
+			if ok := a.addAggregators(metric); ok {
+				metric.Drop()
+				continue
+			}
+			for _, metric := range a.applyProcessors(models.StageOutputs, metric) {
+				outputC <- metric
+			}
+		}
+	}
+
+	procDone := make(chan struct{})
+	go func() {
+		defer close(procDone)
+		for metric := range metricC {
+			if wait == 0 {
+				outputC <- metric
+				continue
+			}
+			addProcessed(a.applyProcessors(models.StageInputs, metric))
+		}
+		addProcessed(a.flushProcessors(models.StageInputs, true))
+	}()
+
+	aggDone := make(chan struct{})
+	go func() {
+		defer close(aggDone)
+		for metric := range aggC {
+			metrics := a.applyProcessors(models.StageAggregators, metric)
+			for _, metric := range a.applyProcessors(models.StageOutputs, metrics...) {
+				outputC <- metric
+			}
+		}
+		metrics := a.flushProcessors(models.StageAggregators, true)
+		for _, metric := range a.applyProcessors(models.StageOutputs, metrics...) {
+			outputC <- metric
+		}
+		for _, metric := range a.flushProcessors(models.StageOutputs, true) {
+			outputC <- metric
+		}
+	}()
+
+	// Aggregators push each period while waiting for the service inputs, and
+	// once more after all metrics have been added.  They are not run without
+	// a wait, like the processors.
+	var aggWg sync.WaitGroup
+	aggCtx, cancelAgg := context.WithCancel(context.Background())
+	aggregators := a.Config.Aggregators
+	if wait == 0 {
+		aggregators = nil
+	}
+	startTime := time.Now()
+	for _, agg := range aggregators {
+		acc := NewAccumulator(agg, aggC)
+		acc.SetPrecision(a.Config.Agent.Precision.Duration,
+			a.Config.Agent.Interval.Duration)
+		agg.SetPeriodStart(startTime)
+
+		aggWg.Add(1)
+		go func(agg *models.RunningAggregator) {
+			defer aggWg.Done()
+			a.push(aggCtx, agg, acc)
+		}(agg)
+	}
+
+	err := a.testInputs(ctx, wait, metricC)
+
+	close(metricC)
+	<-procDone
+	cancelAgg()
+	aggWg.Wait()
+	close(aggC)
+	<-aggDone
+	close(outputC)
+	wg.Wait()
+
+	return err
+}
+
+// redactMetric replaces the secrets of the configuration in the tags and
+// string fields of a metric, so that they are not printed.
+func redactMetric(metric telegraf.Metric) {
+	for _, tag := range metric.TagList() {
+		if value := logger.Redact(tag.Value); value != tag.Value {
+			metric.AddTag(tag.Key, value)
+		}
+	}
+	for _, field := range metric.FieldList() {
+		if str, ok := field.Value.(string); ok {
+			if value := logger.Redact(str); value != str {
+				metric.AddField(field.Key, value)
+			}
+		}
+	}
+}
+
+// testInputs gathers the inputs once and then waits for the service inputs.
+func (a *Agent) testInputs(
+	ctx context.Context,
+	wait time.Duration,
+	dst chan<- telegraf.Metric,
+) error {
+	nulC := make(chan telegraf.Metric)
+	defer close(nulC)
 	go func() {
-		defer wg.Done()
 		for range nulC {
 		}
 	}()
 
+	for _, input := range a.Config.Inputs {
+		input.SetDefaultTags(a.Config.Tags)
+	}
+
+	if wait > 0 {
+		log.Printf("D! [agent] Starting service inputs")
+		err := a.startServiceInputs(ctx, dst)
+		if err != nil {
+			return err
+		}
+		defer a.stopServiceInputs()
+	}
+
 	for _, input := range a.Config.Inputs {
 		select {
 		case <-ctx.Done():
 			return nil
 		default:
-			if _, ok := input.Input.(telegraf.ServiceInput); ok {
-				log.Printf("W!: [agent] skipping plugin [[%s]]: service inputs not supported in --test mode",
+			if _, ok := input.Input.(telegraf.ServiceInput); ok && wait == 0 {
+				log.Printf("W! [agent] skipping plugin [[%s]]: service inputs require --test-wait in --test mode",
 					input.Name())
 				continue
 			}
 
-			acc := NewAccumulator(input, metricC)
+			acc := NewAccumulator(input, dst)
 			acc.SetPrecision(a.Config.Agent.Precision.Duration,
 				a.Config.Agent.Interval.Duration)
-			input.SetDefaultTags(a.Config.Tags)
 
 			// Special instructions for some inputs. cpu, for example, needs to be
 			// run twice in order to return cpu usage percentages.
@@ -210,50 +459,94 @@ func (a *Agent) Test(ctx context.Context) error {
 		}
 	}
 
+	if wait > 0 {
+		log.Printf("D! [agent] Waiting %s for service inputs", wait)
+		internal.SleepContext(ctx, wait)
+	}
+
 	return nil
 }
 
-// runInputs starts and triggers the periodic gather for Inputs.
+// runInputs waits until the context is done and then stops the periodic
+// gather for Inputs.
 //
-// When the context is done the timers are stopped and this function returns
-// after all ongoing Gather calls complete.
-func (a *Agent) runInputs(
-	ctx context.Context,
-	startTime time.Time,
-	dst chan<- telegraf.Metric,
-) error {
-	var wg sync.WaitGroup
-	for _, input := range a.Config.Inputs {
-		interval := a.Config.Agent.Interval.Duration
-		precision := a.Config.Agent.Precision.Duration
-		jitter := a.Config.Agent.CollectionJitter.Duration
+// This function returns after all ongoing Gather calls complete.
+func (a *Agent) runInputs(ctx context.Context) error {
+	<-ctx.Done()
 
-		// Overwrite agent interval if this plugin has its own.
-		if input.Config.Interval != 0 {
-			interval = input.Config.Interval
-		}
+	// Wait for a reload in progress, no inputs can be started afterwards.
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	a.inputs.close()
+	return nil
+}
 
-		acc := NewAccumulator(input, dst)
-		acc.SetPrecision(precision, interval)
+// startInput starts the periodic gather for an Input.
+func (a *Agent) startInput(startTime time.Time, input *models.RunningInput) error {
+	interval := a.Config.Agent.Interval.Duration
+	precision := a.Config.Agent.Precision.Duration
+	jitter := a.Config.Agent.CollectionJitter.Duration
 
-		wg.Add(1)
-		go func(input *models.RunningInput) {
-			defer wg.Done()
+	// Overwrite agent interval if this plugin has its own.
+	if input.Config.Interval != 0 {
+		interval = input.Config.Interval
+	}
 
-			if a.Config.Agent.RoundInterval {
-				err := internal.SleepContext(
-					ctx, internal.AlignDuration(startTime, interval))
-				if err != nil {
-					return
-				}
-			}
+	acc := NewAccumulator(input, a.inputC)
+	acc.SetPrecision(precision, interval)
 
-			a.gatherOnInterval(ctx, acc, input, interval, jitter)
-		}(input)
+	requests := make(chan gatherRequest)
+	if a.gatherRequests == nil {
+		a.gatherRequests = make(map[*models.RunningInput]chan gatherRequest)
 	}
-	wg.Wait()
+	a.gatherRequests[input] = requests
 
-	return nil
+	return a.inputs.start(input, func(ctx context.Context) {
+		if input.Config.Schedule != nil {
+			a.gatherOnSchedule(ctx, acc, input, input.Config.Schedule, jitter, requests)
+			return
+		}
+
+		if a.Config.Agent.RoundInterval {
+			err := internal.SleepContext(
+				ctx, internal.AlignDuration(startTime, interval))
+			if err != nil {
+				return
+			}
+		}
+
+		a.gatherOnInterval(ctx, acc, input, interval, jitter, requests)
+	})
+}
+
+// gatherRequest asks the goroutine of an input to gather it immediately, the
+// error of the gather is sent on the channel.
+type gatherRequest chan error
+
+// waitGather waits for the channel c, serving the requested gathers
+// meanwhile.  It returns false if the context is done first.
+func (a *Agent) waitGather(
+	ctx context.Context,
+	c <-chan time.Time,
+	acc telegraf.Accumulator,
+	input *models.RunningInput,
+	interval time.Duration,
+	busy *<-chan struct{},
+	requests <-chan gatherRequest,
+) bool {
+	for {
+		select {
+		case <-c:
+			return true
+		case req := <-requests:
+			var err error
+			*busy, err = a.gatherIfIdle(ctx, acc, input, interval, *busy)
+			req <- err
+		case <-ctx.Done():
+			return false
+		}
+	}
 }
 
 // gather runs an input's gather function periodically until the context is
@@ -264,149 +557,349 @@ func (a *Agent) gatherOnInterval(
 	input *models.RunningInput,
 	interval time.Duration,
 	jitter time.Duration,
+	requests <-chan gatherRequest,
 ) {
 	defer panicRecover(input)
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// busy is closed once an abandoned gather returns, the input is not
+	// gathered again until then.
+	var busy <-chan struct{}
 	for {
 		err := internal.SleepContext(ctx, internal.RandomDuration(jitter))
 		if err != nil {
 			return
 		}
 
-		err = a.gatherOnce(acc, input, interval)
-		if err != nil {
-			acc.AddError(err)
+		busy, _ = a.gatherIfIdle(ctx, acc, input, interval, busy)
+
+		if !a.waitGather(ctx, ticker.C, acc, input, interval, &busy, requests) {
+			return
 		}
+	}
+}
 
-		select {
-		case <-ticker.C:
-			continue
-		case <-ctx.Done():
+// gatherOnSchedule runs an input's gather function at the times of its
+// schedule until the context is done.  Runs missed while a gather is in
+// progress are skipped.
+func (a *Agent) gatherOnSchedule(
+	ctx context.Context,
+	acc telegraf.Accumulator,
+	input *models.RunningInput,
+	schedule *cron.Schedule,
+	jitter time.Duration,
+	requests <-chan gatherRequest,
+) {
+	defer panicRecover(input)
+
+	var busy <-chan struct{}
+	next := schedule.Next(time.Now())
+	for !next.IsZero() {
+		// The time until the following run is used as the interval for
+		// warnings about slow gathers.
+		interval := schedule.Next(next).Sub(next)
+		if interval <= 0 {
+			interval = a.Config.Agent.Interval.Duration
+		}
+
+		timer := time.NewTimer(time.Until(next) + internal.RandomDuration(jitter))
+		ok := a.waitGather(ctx, timer.C, acc, input, interval, &busy, requests)
+		timer.Stop()
+		if !ok {
 			return
 		}
+
+		busy, _ = a.gatherIfIdle(ctx, acc, input, interval, busy)
+
+		// The wall clock may have been set back while sleeping.
+		now := time.Now()
+		if now.Before(next) {
+			now = next
+		}
+		next = schedule.Next(now)
+	}
+
+	log.Printf("W! [agent] schedule of input %q has no further runs",
+		input.Name())
+}
+
+// gatherIfIdle gathers the input unless it is still running a gather which
+// was abandoned.  It returns the channel closed once the latest abandoned
+// gather returns, and the error of the gather which is already added to the
+// accumulator.
+func (a *Agent) gatherIfIdle(
+	ctx context.Context,
+	acc telegraf.Accumulator,
+	input *models.RunningInput,
+	interval time.Duration,
+	busy <-chan struct{},
+) (<-chan struct{}, error) {
+	select {
+	case <-busy:
+		busy = nil
+	default:
 	}
+
+	if busy != nil {
+		log.Printf("W! [agent] input %q is still running a timed out gather, skipping",
+			input.Name())
+		return busy, errGatherBusy
+	}
+
+	busy, err := a.gatherOnce(ctx, acc, input, interval)
+	if err != nil {
+		acc.AddError(err)
+	}
+	return busy, err
 }
 
 // gatherOnce runs the input's Gather function once, logging a warning each
 // interval it fails to complete before.
+//
+// When the input has a gather timeout, the gather is cancelled once the
+// timeout expires and abandoned: metrics it adds afterwards are discarded.
+// The returned channel is then closed once the abandoned gather returns.
 func (a *Agent) gatherOnce(
+	ctx context.Context,
 	acc telegraf.Accumulator,
 	input *models.RunningInput,
-	timeout time.Duration,
-) error {
-	ticker := time.NewTicker(timeout)
+	interval time.Duration,
+) (<-chan struct{}, error) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	done := make(chan error)
+	var expired <-chan time.Time
+	gatherAcc := newAbandonableAccumulator(acc)
+	timeout := input.Config.GatherTimeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	done := make(chan error, 1)
+	finished := make(chan struct{})
 	go func() {
-		done <- input.Gather(acc)
+		defer close(finished)
+		done <- input.GatherContext(ctx, gatherAcc)
 	}()
 
 	for {
 		select {
 		case err := <-done:
-			return err
+			return nil, err
 		case <-ticker.C:
 			log.Printf("W! [agent] input %q did not complete within its interval",
 				input.Name())
+		case <-expired:
+			gatherAcc.abandon()
+			input.GatherTimeouts.Incr(1)
+			return finished, fmt.Errorf("gather timed out after %s", timeout)
 		}
 	}
 }
 
-// runProcessors applies processors to metrics.
+// runProcessors applies the input stage processors to metrics.
+//
+// When the source channel is closed the service processors of the stage are
+// stopped, and the metrics they return are forwarded before this function
+// returns.
 func (a *Agent) runProcessors(
 	src <-chan telegraf.Metric,
 	agg chan<- telegraf.Metric,
 ) error {
-	for metric := range src {
-		metrics := a.applyProcessors(metric)
+	ticker := time.NewTicker(processorFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		var metrics []telegraf.Metric
+		select {
+		case metric, ok := <-src:
+			if !ok {
+				for _, metric := range a.flushProcessors(models.StageInputs, true) {
+					agg <- metric
+				}
+				return nil
+			}
+			metrics = a.applyProcessors(models.StageInputs, metric)
+		case <-ticker.C:
+			metrics = a.flushProcessors(models.StageInputs, false)
+		}
 
 		for _, metric := range metrics {
 			agg <- metric
 		}
 	}
-
-	return nil
 }
 
-// applyProcessors applies all processors to a metric.
-func (a *Agent) applyProcessors(m telegraf.Metric) []telegraf.Metric {
-	metrics := []telegraf.Metric{m}
+// applyProcessors applies the processors of a stage to the metrics.
+func (a *Agent) applyProcessors(stage string, metrics ...telegraf.Metric) []telegraf.Metric {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	for _, processor := range a.Config.Processors {
+		if !processor.InStage(stage) {
+			continue
+		}
 		metrics = processor.Apply(metrics...)
 	}
 
 	return metrics
 }
 
-// runAggregators triggers the periodic push for Aggregators.
+// flushProcessors returns the metrics output by the service processors of a
+// stage, after applying the processors following them in the stage, and by
+// the service processors removed from the stage.  When stop is true the
+// services are stopped.
+func (a *Agent) flushProcessors(stage string, stop bool) []telegraf.Metric {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var metrics []telegraf.Metric
+	for _, processor := range a.Config.Processors {
+		if !processor.InStage(stage) {
+			continue
+		}
+		if len(metrics) > 0 {
+			metrics = processor.Apply(metrics...)
+		}
+		if stop {
+			metrics = append(metrics, processor.Stop()...)
+		} else {
+			metrics = append(metrics, processor.Flush()...)
+		}
+	}
+
+	a.stoppedMu.Lock()
+	defer a.stoppedMu.Unlock()
+	metrics = append(metrics, a.stopped[stage]...)
+	delete(a.stopped, stage)
+	return metrics
+}
+
+// addStopped adds the metrics returned by a service processor removed from
+// the stage, they are forwarded to the next stage with the next flush.
+func (a *Agent) addStopped(stage string, metrics []telegraf.Metric) {
+	if len(metrics) == 0 {
+		return
+	}
+
+	a.stoppedMu.Lock()
+	defer a.stoppedMu.Unlock()
+	if a.stopped == nil {
+		a.stopped = make(map[string][]telegraf.Metric)
+	}
+	a.stopped[stage] = append(a.stopped[stage], metrics...)
+}
+
+// runAggregators adds metrics to the Aggregators and forwards their
+// aggregations, after applying the aggregator stage processors.  The output
+// stage processors are applied to all forwarded metrics.
 //
-// When the context is done a final push will occur and then this function
-// will return.
+// When the source channel is closed a final push will occur, the service
+// processors of the aggregator and output stages are stopped and then this
+// function will return.
 func (a *Agent) runAggregators(
-	startTime time.Time,
 	src <-chan telegraf.Metric,
 	dst chan<- telegraf.Metric,
 ) error {
-	ctx, cancel := context.WithCancel(context.Background())
-
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		for metric := range src {
-			var dropOriginal bool
-			for _, agg := range a.Config.Aggregators {
-				if ok := agg.Add(metric); ok {
-					dropOriginal = true
+
+		ticker := time.NewTicker(processorFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			var metrics []telegraf.Metric
+			select {
+			case metric, ok := <-a.aggregationC:
+				if !ok {
+					metrics = a.flushProcessors(models.StageAggregators, true)
+					for _, metric := range a.applyProcessors(models.StageOutputs, metrics...) {
+						dst <- metric
+					}
+					return
 				}
+				metrics = a.applyProcessors(models.StageAggregators, metric)
+				metrics = a.applyProcessors(models.StageOutputs, metrics...)
+			case <-ticker.C:
+				metrics = a.flushProcessors(models.StageAggregators, false)
+				metrics = a.applyProcessors(models.StageOutputs, metrics...)
+				metrics = append(metrics, a.flushProcessors(models.StageOutputs, false)...)
 			}
 
-			if !dropOriginal {
+			for _, metric := range metrics {
 				dst <- metric
 			}
 		}
-		cancel()
 	}()
 
-	precision := a.Config.Agent.Precision.Duration
-	interval := a.Config.Agent.Interval.Duration
-	aggregations := make(chan telegraf.Metric, 100)
-	for _, agg := range a.Config.Aggregators {
-		wg.Add(1)
-		go func(agg *models.RunningAggregator) {
-			defer wg.Done()
-
-			if a.Config.Agent.RoundInterval {
-				// Aggregators are aligned to the agent interval regardless of
-				// their period.
-				err := internal.SleepContext(ctx, internal.AlignDuration(startTime, interval))
-				if err != nil {
-					return
-				}
-			}
+	for metric := range src {
+		if ok := a.addAggregators(metric); ok {
+			// The aggregators hold the delivery of the original metric
+			// until their aggregates are delivered.
+			metric.Drop()
+			continue
+		}
+		for _, metric := range a.applyProcessors(models.StageOutputs, metric) {
+			dst <- metric
+		}
+	}
 
-			agg.SetPeriodStart(startTime)
+	a.aggregators.close()
+	close(a.aggregationC)
+	wg.Wait()
 
-			acc := NewAccumulator(agg, aggregations)
-			acc.SetPrecision(precision, interval)
-			a.push(ctx, agg, acc)
-			close(aggregations)
-		}(agg)
+	for _, metric := range a.flushProcessors(models.StageOutputs, true) {
+		dst <- metric
 	}
+	return nil
+}
 
-	for metric := range aggregations {
-		metrics := a.applyProcessors(metric)
-		for _, metric := range metrics {
-			dst <- metric
+// addAggregators adds a metric to all aggregators, returning true if the
+// original metric should be dropped.
+func (a *Agent) addAggregators(metric telegraf.Metric) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var dropOriginal bool
+	for _, agg := range a.Config.Aggregators {
+		if ok := agg.Add(metric); ok {
+			dropOriginal = true
 		}
 	}
+	return dropOriginal
+}
 
-	wg.Wait()
-	return nil
+// startAggregator starts the periodic push for an Aggregator.
+func (a *Agent) startAggregator(startTime time.Time, agg *models.RunningAggregator) error {
+	precision := a.Config.Agent.Precision.Duration
+	interval := a.Config.Agent.Interval.Duration
+
+	acc := NewAccumulator(agg, a.aggregationC)
+	acc.SetPrecision(precision, interval)
+
+	return a.aggregators.start(agg, func(ctx context.Context) {
+		if a.Config.Agent.RoundInterval {
+			// Aggregators are aligned to the agent interval regardless of
+			// their period.
+			err := internal.SleepContext(ctx, internal.AlignDuration(startTime, interval))
+			if err != nil {
+				return
+			}
+		}
+
+		agg.SetPeriodStart(startTime)
+		a.push(ctx, agg, acc)
+	})
 }
 
 // push runs the push for a single aggregator every period.  More simple than
@@ -433,58 +926,77 @@ func (a *Agent) push(
 	}
 }
 
-// runOutputs triggers the periodic write for Outputs.
+// runOutputs adds metrics to the Outputs.
 //
-// When the context is done, outputs continue to run until their buffer is
-// closed, afterwich they run flush once more.
-func (a *Agent) runOutputs(
-	startTime time.Time,
-	src <-chan telegraf.Metric,
-) error {
-	interval := a.Config.Agent.FlushInterval.Duration
-	jitter := a.Config.Agent.FlushJitter.Duration
+// When the source channel is closed the outputs are flushed once more and
+// then this function returns.
+func (a *Agent) runOutputs(ctx context.Context, src <-chan telegraf.Metric) error {
+	for metric := range src {
+		a.waitOutputs(ctx)
+		a.addOutputs(metric)
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	log.Println("I! [agent] Hang on, flushing any cached metrics before shutdown")
+	a.outputs.close()
 
-	var wg sync.WaitGroup
-	for _, output := range a.Config.Outputs {
-		interval := interval
-		// Overwrite agent flush_interval if this plugin has its own.
-		if output.Config.FlushInterval != 0 {
-			interval = output.Config.FlushInterval
+	return nil
+}
+
+// waitOutputs blocks while an output using the block drop policy has a full
+// buffer.  The plugin lock is not held while waiting, so that the outputs can
+// be reloaded.  Once the context is done metrics are no longer held back.
+func (a *Agent) waitOutputs(ctx context.Context) {
+	a.mu.RLock()
+	outputs := a.Config.Outputs
+	a.mu.RUnlock()
+
+	for _, output := range outputs {
+		output.WaitBuffer(ctx)
+	}
+}
+
+// addOutputs adds a metric to all outputs, or to the active output of a
+// failover group.
+func (a *Agent) addOutputs(metric telegraf.Metric) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	outputs := a.failover.outputs(a.Config.Outputs)
+	for i, output := range outputs {
+		if i == len(outputs)-1 {
+			output.AddMetric(metric)
+		} else {
+			output.AddMetric(metric.Copy())
 		}
+	}
+}
 
-		wg.Add(1)
-		go func(output *models.RunningOutput) {
-			defer wg.Done()
+// startOutput starts the periodic write for an Output.
+func (a *Agent) startOutput(startTime time.Time, output *models.RunningOutput) error {
+	return a.outputs.start(output, func(ctx context.Context) {
+		a.runOutput(ctx, startTime, output)
+	})
+}
 
-			if a.Config.Agent.RoundInterval {
-				err := internal.SleepContext(
-					ctx, internal.AlignDuration(startTime, interval))
-				if err != nil {
-					return
-				}
-			}
+// runOutput runs the periodic write of an Output until the context is done.
+func (a *Agent) runOutput(ctx context.Context, startTime time.Time, output *models.RunningOutput) {
+	interval := a.Config.Agent.FlushInterval.Duration
+	jitter := a.Config.Agent.FlushJitter.Duration
 
-			a.flush(ctx, output, interval, jitter)
-		}(output)
+	// Overwrite agent flush_interval if this plugin has its own.
+	if output.Config.FlushInterval != 0 {
+		interval = output.Config.FlushInterval
 	}
 
-	for metric := range src {
-		for i, output := range a.Config.Outputs {
-			if i == len(a.Config.Outputs)-1 {
-				output.AddMetric(metric)
-			} else {
-				output.AddMetric(metric.Copy())
-			}
+	if a.Config.Agent.RoundInterval {
+		err := internal.SleepContext(
+			ctx, internal.AlignDuration(startTime, interval))
+		if err != nil {
+			return
 		}
 	}
 
-	log.Println("I! [agent] Hang on, flushing any cached metrics before shutdown")
-	cancel()
-	wg.Wait()
-
-	return nil
+	a.flush(ctx, output, interval, jitter)
 }
 
 // flush runs an output's flush function periodically until the context is
@@ -510,7 +1022,7 @@ func (a *Agent) flush(
 		// Favor shutdown over other methods.
 		select {
 		case <-ctx.Done():
-			logError(a.flushOnce(output, interval, output.Write))
+			logError(a.flushOnce(output, interval, output.WriteFinal))
 			return
 		default:
 		}
@@ -527,7 +1039,7 @@ func (a *Agent) flush(
 				logError(a.flushOnce(output, interval, output.WriteBatch))
 			}
 		case <-ctx.Done():
-			logError(a.flushOnce(output, interval, output.Write))
+			logError(a.flushOnce(output, interval, output.WriteFinal))
 			return
 		}
 	}
@@ -564,9 +1076,12 @@ func (a *Agent) flushOnce(
 
 // connectOutputs connects to all outputs.
 func (a *Agent) connectOutputs(ctx context.Context) error {
+	a.statusOutputs.Store(a.Config.Outputs)
 	for _, output := range a.Config.Outputs {
+		a.setOutputStatus(output)
+
 		log.Printf("D! [agent] Attempting connection to output: %s\n", output.Name)
-		err := output.Output.Connect()
+		err := output.Connect()
 		if err != nil {
 			log.Printf("E! [agent] Failed to connect to output %s, retrying in 15s, "+
 				"error was '%s' \n", output.Name, err)
@@ -576,7 +1091,7 @@ func (a *Agent) connectOutputs(ctx context.Context) error {
 				return err
 			}
 
-			err = output.Output.Connect()
+			err = output.Connect()
 			if err != nil {
 				return err
 			}
@@ -586,11 +1101,31 @@ func (a *Agent) connectOutputs(ctx context.Context) error {
 	return nil
 }
 
+// setOutputStatus gives an output reporting on the other outputs the
+// function returning their status.
+func (a *Agent) setOutputStatus(output *models.RunningOutput) {
+	so, ok := output.Output.(telegraf.StatusOutput)
+	if !ok {
+		return
+	}
+
+	so.SetStatus(func() []telegraf.OutputStatus {
+		outputs, _ := a.statusOutputs.Load().([]*models.RunningOutput)
+		status := make([]telegraf.OutputStatus, 0, len(outputs))
+		for _, o := range outputs {
+			if o != output {
+				status = append(status, o.Status())
+			}
+		}
+		return status
+	})
+}
+
 // closeOutputs closes all outputs.
 func (a *Agent) closeOutputs() error {
 	var err error
 	for _, output := range a.Config.Outputs {
-		err = output.Output.Close()
+		err = output.Close()
 	}
 	return err
 }
@@ -604,14 +1139,7 @@ func (a *Agent) startServiceInputs(
 
 	for _, input := range a.Config.Inputs {
 		if si, ok := input.Input.(telegraf.ServiceInput); ok {
-			// Service input plugins are not subject to timestamp rounding.
-			// This only applies to the accumulator passed to Start(), the
-			// Gather() accumulator does apply rounding according to the
-			// precision agent setting.
-			acc := NewAccumulator(input, dst)
-			acc.SetPrecision(time.Nanosecond, 0)
-
-			err := si.Start(acc)
+			err := startServiceInput(input, si, dst)
 			if err != nil {
 				log.Printf("E! [agent] Service for input %s failed to start: %v",
 					input.Name(), err)
@@ -630,6 +1158,22 @@ func (a *Agent) startServiceInputs(
 	return nil
 }
 
+// startServiceInput starts the service of a single input.
+func startServiceInput(
+	input *models.RunningInput,
+	si telegraf.ServiceInput,
+	dst chan<- telegraf.Metric,
+) error {
+	// Service input plugins are not subject to timestamp rounding.
+	// This only applies to the accumulator passed to Start(), the
+	// Gather() accumulator does apply rounding according to the
+	// precision agent setting.
+	acc := NewAccumulator(input, dst)
+	acc.SetPrecision(time.Nanosecond, 0)
+
+	return si.Start(acc)
+}
+
 // stopServiceInputs stops all service inputs.
 func (a *Agent) stopServiceInputs() {
 	for _, input := range a.Config.Inputs {
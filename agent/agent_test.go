@@ -1,9 +1,18 @@
 package agent
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal/config"
+	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/metric"
 
 	// needing to load the plugins
 	_ "github.com/influxdata/telegraf/plugins/inputs/all"
@@ -11,6 +20,7 @@ import (
 	_ "github.com/influxdata/telegraf/plugins/outputs/all"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAgent_OmitHostname(t *testing.T) {
@@ -109,3 +119,476 @@ func TestAgent_LoadOutput(t *testing.T) {
 	a, _ = NewAgent(c)
 	assert.Equal(t, 3, len(a.Config.Outputs))
 }
+
+type testServiceInput struct {
+	wg      sync.WaitGroup
+	stopped bool
+}
+
+func (i *testServiceInput) SampleConfig() string                  { return "" }
+func (i *testServiceInput) Description() string                   { return "" }
+func (i *testServiceInput) Gather(acc telegraf.Accumulator) error { return nil }
+
+func (i *testServiceInput) Start(acc telegraf.Accumulator) error {
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+		acc.AddFields("service", map[string]interface{}{"value": 42}, nil,
+			time.Unix(0, 0))
+	}()
+	return nil
+}
+
+func (i *testServiceInput) Stop() {
+	i.wg.Wait()
+	i.stopped = true
+}
+
+type testTagProcessor struct{}
+
+func (p *testTagProcessor) SampleConfig() string { return "" }
+func (p *testTagProcessor) Description() string  { return "" }
+func (p *testTagProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, m := range in {
+		m.AddTag("processed", "true")
+	}
+	return in
+}
+
+// captureStdout returns everything written to stdout while running f.
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+
+	f()
+	w.Close()
+	return <-done
+}
+
+func TestAgent_TestServiceInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		wait     time.Duration
+		expected string
+		stopped  bool
+	}{
+		{
+			name:     "skipped without wait",
+			expected: "",
+		},
+		{
+			name:     "started with wait",
+			wait:     100 * time.Millisecond,
+			expected: "> service,processed=true value=42i 0\n",
+			stopped:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &testServiceInput{}
+
+			c := config.NewConfig()
+			c.Agent.OmitHostname = true
+			c.Inputs = append(c.Inputs, models.NewRunningInput(input,
+				&models.InputConfig{Name: "service"}))
+			c.Processors = append(c.Processors, &models.RunningProcessor{
+				Name:      "tag",
+				Processor: &testTagProcessor{},
+				Config:    &models.ProcessorConfig{Name: "tag"},
+			})
+			a, err := NewAgent(c)
+			assert.NoError(t, err)
+
+			output := captureStdout(t, func() {
+				err = a.Test(context.Background(), tt.wait)
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, output)
+			assert.Equal(t, tt.stopped, input.stopped)
+		})
+	}
+}
+
+type testInput struct{}
+
+func (i *testInput) SampleConfig() string { return "" }
+func (i *testInput) Description() string  { return "" }
+func (i *testInput) Gather(acc telegraf.Accumulator) error {
+	acc.AddFields("input", map[string]interface{}{"value": 42}, nil,
+		time.Unix(0, 0))
+	return nil
+}
+
+func TestAgent_TestProcessors(t *testing.T) {
+	tests := []struct {
+		name     string
+		wait     time.Duration
+		expected string
+	}{
+		{
+			name:     "printed as gathered without wait",
+			expected: "> input value=42i 0\n",
+		},
+		{
+			name:     "processed with wait",
+			wait:     time.Millisecond,
+			expected: "> input,processed=true value=42i 0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config.NewConfig()
+			c.Agent.OmitHostname = true
+			c.Inputs = append(c.Inputs, models.NewRunningInput(&testInput{},
+				&models.InputConfig{Name: "input"}))
+			c.Processors = append(c.Processors, &models.RunningProcessor{
+				Name:      "tag",
+				Processor: &testTagProcessor{},
+				Config:    &models.ProcessorConfig{Name: "tag"},
+			})
+			a, err := NewAgent(c)
+			assert.NoError(t, err)
+
+			output := captureStdout(t, func() {
+				err = a.Test(context.Background(), tt.wait)
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, output)
+		})
+	}
+}
+
+// stageProcessor sets a tag with the name of the stage it is configured in.
+type stageProcessor struct {
+	stage string
+}
+
+func (p *stageProcessor) SampleConfig() string { return "" }
+func (p *stageProcessor) Description() string  { return "" }
+func (p *stageProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, m := range in {
+		m.AddTag("stage_"+p.stage, "true")
+	}
+	return in
+}
+
+func TestAgent_ApplyProcessorsStages(t *testing.T) {
+	c := config.NewConfig()
+	for _, stage := range []string{"default", models.StageInputs,
+		models.StageAggregators, models.StageOutputs} {
+		conf := &models.ProcessorConfig{Name: stage}
+		if stage != "default" {
+			conf.Stage = stage
+		}
+		c.Processors = append(c.Processors, &models.RunningProcessor{
+			Name:      stage,
+			Processor: &stageProcessor{stage: stage},
+			Config:    conf,
+		})
+	}
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	tests := []struct {
+		stage    string
+		expected map[string]string
+	}{
+		{
+			stage: models.StageInputs,
+			expected: map[string]string{
+				"stage_default": "true",
+				"stage_inputs":  "true",
+			},
+		},
+		{
+			stage: models.StageAggregators,
+			expected: map[string]string{
+				"stage_default":     "true",
+				"stage_aggregators": "true",
+			},
+		},
+		{
+			stage: models.StageOutputs,
+			expected: map[string]string{
+				"stage_outputs": "true",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stage, func(t *testing.T) {
+			m, err := metric.New("cpu", map[string]string{},
+				map[string]interface{}{"value": 42}, time.Unix(0, 0))
+			require.NoError(t, err)
+
+			metrics := a.applyProcessors(tt.stage, m)
+			require.Len(t, metrics, 1)
+			require.Equal(t, tt.expected, metrics[0].Tags())
+		})
+	}
+}
+
+// holdingProcessor holds the metrics applied to it, and returns them when
+// flushed or stopped.
+type holdingProcessor struct {
+	held    []telegraf.Metric
+	flush   bool
+	stopped bool
+}
+
+func (p *holdingProcessor) SampleConfig() string { return "" }
+func (p *holdingProcessor) Description() string  { return "" }
+func (p *holdingProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	p.held = append(p.held, in...)
+	return nil
+}
+
+func (p *holdingProcessor) Flush() []telegraf.Metric {
+	if !p.flush {
+		return nil
+	}
+	held := p.held
+	p.held = nil
+	return held
+}
+
+func (p *holdingProcessor) Stop() []telegraf.Metric {
+	p.stopped = true
+	held := p.held
+	p.held = nil
+	return held
+}
+
+func TestAgent_FlushProcessors(t *testing.T) {
+	holding := &holdingProcessor{flush: true}
+	c := config.NewConfig()
+	c.Processors = append(c.Processors,
+		&models.RunningProcessor{
+			Name:      "holding",
+			Processor: holding,
+			Config:    &models.ProcessorConfig{Name: "holding", Stage: models.StageInputs},
+		},
+		&models.RunningProcessor{
+			Name:      "inputs",
+			Processor: &stageProcessor{stage: models.StageInputs},
+			Config:    &models.ProcessorConfig{Name: "inputs", Stage: models.StageInputs},
+		})
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	m, err := metric.New("cpu", map[string]string{},
+		map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	require.NoError(t, err)
+	require.Empty(t, a.applyProcessors(models.StageInputs, m))
+	require.Empty(t, a.flushProcessors(models.StageOutputs, false))
+
+	// The flushed metrics go through the processors following the service.
+	metrics := a.flushProcessors(models.StageInputs, false)
+	require.Len(t, metrics, 1)
+	require.Equal(t, map[string]string{"stage_inputs": "true"}, metrics[0].Tags())
+	require.False(t, holding.stopped)
+
+	require.Empty(t, a.flushProcessors(models.StageInputs, true))
+	require.True(t, holding.stopped)
+}
+
+func TestAgent_TestServiceProcessor(t *testing.T) {
+	holding := &holdingProcessor{}
+	c := config.NewConfig()
+	c.Agent.OmitHostname = true
+	c.Inputs = append(c.Inputs, models.NewRunningInput(&testInput{},
+		&models.InputConfig{Name: "input"}))
+	c.Processors = append(c.Processors, &models.RunningProcessor{
+		Name:      "holding",
+		Processor: holding,
+		Config:    &models.ProcessorConfig{Name: "holding", Stage: models.StageInputs},
+	})
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	// The metrics held by the processor are printed once it is stopped.
+	output := captureStdout(t, func() {
+		err = a.Test(context.Background(), time.Millisecond)
+	})
+	require.NoError(t, err)
+	require.Equal(t, "> input value=42i 0\n", output)
+	require.True(t, holding.stopped)
+}
+
+type blockingInput struct {
+	release chan struct{}
+}
+
+func (i *blockingInput) SampleConfig() string { return "" }
+func (i *blockingInput) Description() string  { return "" }
+func (i *blockingInput) Gather(acc telegraf.Accumulator) error {
+	<-i.release
+	acc.AddFields("blocking", map[string]interface{}{"value": 42}, nil)
+	return nil
+}
+
+type blockingContextInput struct {
+	blockingInput
+}
+
+func (i *blockingContextInput) GatherContext(ctx context.Context, acc telegraf.Accumulator) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestAgent_GatherTimeout(t *testing.T) {
+	release := make(chan struct{})
+	input := models.NewRunningInput(&blockingInput{release: release},
+		&models.InputConfig{
+			Name:          "gather_timeout",
+			GatherTimeout: 10 * time.Millisecond,
+		})
+	metricC := make(chan telegraf.Metric, 10)
+	acc := NewAccumulator(input, metricC)
+
+	a, _ := NewAgent(config.NewConfig())
+	busy, err := a.gatherOnce(context.Background(), acc, input, time.Hour)
+	require.Error(t, err)
+	require.Equal(t, int64(1), input.GatherTimeouts.Get())
+
+	// The abandoned gather still runs, but its metrics are discarded.
+	select {
+	case <-busy:
+		t.Fatal("abandoned gather reported as returned")
+	default:
+	}
+	close(release)
+	<-busy
+	require.Len(t, metricC, 0)
+}
+
+func TestAgent_GatherContextTimeout(t *testing.T) {
+	input := models.NewRunningInput(&blockingContextInput{},
+		&models.InputConfig{
+			Name:          "gather_context_timeout",
+			GatherTimeout: 10 * time.Millisecond,
+		})
+	acc := NewAccumulator(input, make(chan telegraf.Metric, 10))
+
+	a, _ := NewAgent(config.NewConfig())
+	busy, err := a.gatherOnce(context.Background(), acc, input, time.Hour)
+	require.Error(t, err)
+	require.Equal(t, int64(1), input.GatherTimeouts.Get())
+
+	select {
+	case <-busy:
+	case <-time.After(5 * time.Second):
+		t.Fatal("gather did not return once its context was done")
+	}
+}
+
+// stalledOutput blocks writes until it is released.
+type stalledOutput struct {
+	release chan struct{}
+}
+
+func (o *stalledOutput) SampleConfig() string { return "" }
+func (o *stalledOutput) Description() string  { return "" }
+func (o *stalledOutput) Connect() error       { return nil }
+func (o *stalledOutput) Close() error         { return nil }
+func (o *stalledOutput) Write(metrics []telegraf.Metric) error {
+	<-o.release
+	return nil
+}
+
+func TestAgent_RunPipelines(t *testing.T) {
+	// The main pipeline stalls once the buffer of its output is full.
+	stalled := &stalledOutput{release: make(chan struct{})}
+	c := newReloadConfig(1, "a", &reloadOutput{})
+	c.Outputs = []*models.RunningOutput{models.NewRunningOutput("stalled", stalled,
+		&models.OutputConfig{Name: "stalled", BufferDropPolicy: models.DropBlock}, 1, 1)}
+
+	output := &reloadOutput{}
+	p := newReloadConfig(2, "b", output)
+	p.Pipeline = "other"
+	c.Pipelines = append(c.Pipelines, p)
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+	waitFor(t, func() bool { return output.received(2) })
+	require.False(t, output.received(1))
+
+	close(stalled.release)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+// statusOutput records the status function set by the agent.
+type statusOutput struct {
+	sync.Mutex
+	status func() []telegraf.OutputStatus
+}
+
+func (o *statusOutput) SampleConfig() string                  { return "" }
+func (o *statusOutput) Description() string                   { return "" }
+func (o *statusOutput) Connect() error                        { return nil }
+func (o *statusOutput) Close() error                          { return nil }
+func (o *statusOutput) Write(metrics []telegraf.Metric) error { return nil }
+func (o *statusOutput) SetStatus(status func() []telegraf.OutputStatus) {
+	o.Lock()
+	defer o.Unlock()
+	o.status = status
+}
+
+func (o *statusOutput) get() []telegraf.OutputStatus {
+	o.Lock()
+	defer o.Unlock()
+	if o.status == nil {
+		return nil
+	}
+	return o.status()
+}
+
+func TestAgent_OutputStatus(t *testing.T) {
+	output := &reloadOutput{}
+	c := newReloadConfig(1, "a", output)
+	status := &statusOutput{}
+	c.Outputs = append(c.Outputs, models.NewRunningOutput("status", status,
+		&models.OutputConfig{Name: "status"}, 1000, 10000))
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	start := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+	waitFor(t, func() bool { return output.received(1) })
+
+	// Only the other outputs are reported.
+	waitFor(t, func() bool {
+		s := status.get()
+		return len(s) == 1 && s[0].Name == "reload" && s[0].LastWrite.After(start)
+	})
+	require.Equal(t, 10000, status.get()[0].BufferLimit)
+
+	cancel()
+	require.NoError(t, <-done)
+}
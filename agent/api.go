@@ -0,0 +1,445 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/logger"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// pluginInfo describes a loaded plugin in the management API.
+type pluginInfo struct {
+	Name     string                 `json:"name"`
+	Pipeline string                 `json:"pipeline,omitempty"`
+	Checksum string                 `json:"checksum"`
+	Config   map[string]interface{} `json:"config"`
+}
+
+// outputInfo describes the buffer of an output in the management API.
+type outputInfo struct {
+	Name        string  `json:"name"`
+	Pipeline    string  `json:"pipeline,omitempty"`
+	BufferSize  int     `json:"buffer_size"`
+	BufferLimit int     `json:"buffer_limit"`
+	BufferFill  float64 `json:"buffer_fill"`
+}
+
+// statInfo is a selfstat metric in the management API.
+type statInfo struct {
+	Name   string                 `json:"name"`
+	Tags   map[string]string      `json:"tags"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// runAPI serves the management API on listener until the context is done.
+func (a *Agent) runAPI(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{Handler: a.apiHandler()}
+
+	go func() {
+		<-ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	log.Printf("I! [agent] Management API listening on %s", listener.Addr())
+	err := server.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// apiHandler returns the handler for the management API.
+func (a *Agent) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plugins", a.servePlugins)
+	mux.HandleFunc("/stats", a.serveStats)
+	mux.HandleFunc("/outputs", a.serveOutputs)
+	mux.HandleFunc("/inputs/", a.serveGather)
+	mux.HandleFunc("/outputs/", a.serveFlush)
+	mux.HandleFunc("/pipelines/", a.servePipeline)
+	return mux
+}
+
+// servePlugins lists the loaded plugins of all pipelines.  Only the common
+// plugin settings are included, plugin specific settings may contain
+// credentials.
+func (a *Agent) servePlugins(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	plugins := map[string][]pluginInfo{
+		"inputs":      []pluginInfo{},
+		"processors":  []pluginInfo{},
+		"aggregators": []pluginInfo{},
+		"outputs":     []pluginInfo{},
+	}
+	for _, p := range a.allPipelines() {
+		p.addPluginInfo(plugins)
+	}
+
+	writeJSON(w, plugins)
+}
+
+// addPluginInfo adds the plugins of the pipeline to the plugin lists.
+func (a *Agent) addPluginInfo(plugins map[string][]pluginInfo) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	pipeline := a.Config.Pipeline
+	for _, input := range a.Config.Inputs {
+		config := filterConfig(input.Config.Filter)
+		setDuration(config, "interval", input.Config.Interval)
+		setString(config, "name_override", input.Config.NameOverride)
+		setString(config, "name_prefix", input.Config.MeasurementPrefix)
+		setString(config, "name_suffix", input.Config.MeasurementSuffix)
+		if len(input.Config.Tags) > 0 {
+			config["tags"] = redactTags(input.Config.Tags)
+		}
+		plugins["inputs"] = append(plugins["inputs"], pluginInfo{Name: input.Name(),
+			Pipeline: pipeline, Checksum: input.Checksum, Config: config})
+	}
+	for _, processor := range a.Config.Processors {
+		config := filterConfig(processor.Config.Filter)
+		config["order"] = processor.Config.Order
+		setString(config, "stage", processor.Config.Stage)
+		plugins["processors"] = append(plugins["processors"], pluginInfo{Name: "processors." + processor.Name,
+			Pipeline: pipeline, Checksum: processor.Checksum, Config: config})
+	}
+	for _, agg := range a.Config.Aggregators {
+		config := filterConfig(agg.Config.Filter)
+		setDuration(config, "period", agg.Config.Period)
+		setDuration(config, "delay", agg.Config.Delay)
+		config["drop_original"] = agg.Config.DropOriginal
+		setString(config, "name_override", agg.Config.NameOverride)
+		setString(config, "name_prefix", agg.Config.MeasurementPrefix)
+		setString(config, "name_suffix", agg.Config.MeasurementSuffix)
+		if len(agg.Config.Tags) > 0 {
+			config["tags"] = redactTags(agg.Config.Tags)
+		}
+		plugins["aggregators"] = append(plugins["aggregators"], pluginInfo{Name: agg.Name(),
+			Pipeline: pipeline, Checksum: agg.Checksum, Config: config})
+	}
+	for _, output := range a.Config.Outputs {
+		config := filterConfig(output.Config.Filter)
+		setDuration(config, "flush_interval", output.Config.FlushInterval)
+		config["metric_batch_size"] = output.MetricBatchSize
+		config["metric_buffer_limit"] = output.MetricBufferLimit
+		setString(config, "buffer_directory", output.Config.BufferDirectory)
+		setString(config, "failover_group", output.Config.FailoverGroup)
+		plugins["outputs"] = append(plugins["outputs"], pluginInfo{Name: "outputs." + output.Name,
+			Pipeline: pipeline, Checksum: output.Checksum, Config: config})
+	}
+}
+
+// serveStats returns the current internal statistics.
+func (a *Agent) serveStats(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	stats := []statInfo{}
+	for _, m := range selfstat.Metrics() {
+		stats = append(stats, statInfo{
+			Name:   m.Name(),
+			Tags:   m.Tags(),
+			Fields: m.Fields(),
+		})
+	}
+	writeJSON(w, stats)
+}
+
+// serveOutputs returns the buffer fill of each output of all pipelines.
+func (a *Agent) serveOutputs(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	outputs := []outputInfo{}
+	for _, p := range a.allPipelines() {
+		p.mu.RLock()
+		for _, output := range p.Config.Outputs {
+			info := outputInfo{
+				Name:        "outputs." + output.Name,
+				Pipeline:    p.Config.Pipeline,
+				BufferSize:  output.BufferLength(),
+				BufferLimit: output.MetricBufferLimit,
+			}
+			if info.BufferLimit > 0 {
+				info.BufferFill = float64(info.BufferSize) / float64(info.BufferLimit)
+			}
+			outputs = append(outputs, info)
+		}
+		p.mu.RUnlock()
+	}
+
+	writeJSON(w, outputs)
+}
+
+// servePipeline handles the requests to the inputs and outputs of a named
+// pipeline, /pipelines/<pipeline>/inputs/<name>/gather and
+// /pipelines/<pipeline>/outputs/<name>/flush.
+func (a *Agent) servePipeline(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/pipelines/")
+	i := strings.Index(path, "/")
+	if i <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+	pipeline, path := path[:i], path[i:]
+
+	var p *Agent
+	for _, candidate := range a.pipelines {
+		if candidate.Config.Pipeline == pipeline {
+			p = candidate
+		}
+	}
+	if p == nil {
+		http.Error(w, fmt.Sprintf("pipeline %q not found", pipeline), http.StatusNotFound)
+		return
+	}
+
+	if name, ok := actionPath(path, "/inputs/", "/gather"); ok {
+		if allowMethod(w, r, http.MethodPost) {
+			p.gatherInputs(w, r, name)
+		}
+		return
+	}
+	if name, ok := actionPath(path, "/outputs/", "/flush"); ok {
+		if allowMethod(w, r, http.MethodPost) {
+			p.flushOutputs(w, r, name)
+		}
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// serveGather runs an immediate gather of the named input of the main
+// configuration, handling requests to /inputs/<name>/gather.
+func (a *Agent) serveGather(w http.ResponseWriter, r *http.Request) {
+	name, ok := actionPath(r.URL.Path, "/inputs/", "/gather")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	a.gatherInputs(w, r, name)
+}
+
+// gatherInputs gathers the inputs of the pipeline with the given name.  The
+// gather is run by the goroutine of the input, so that it never runs
+// concurrently with a scheduled gather.
+func (a *Agent) gatherInputs(w http.ResponseWriter, r *http.Request, name string) {
+	type target struct {
+		name     string
+		requests chan<- gatherRequest
+		done     <-chan struct{}
+	}
+
+	// The lock keeps the inputs from being replaced while looking them up,
+	// it is not held while gathering.
+	a.reloadMu.Lock()
+	if a.inputs == nil || a.inputs.isClosed() {
+		a.reloadMu.Unlock()
+		http.Error(w, "agent is not running", http.StatusServiceUnavailable)
+		return
+	}
+	var targets []target
+	for _, input := range a.Config.Inputs {
+		if input.Name() != name && input.Config.Name != name {
+			continue
+		}
+		targets = append(targets, target{
+			name:     input.Name(),
+			requests: a.gatherRequests[input],
+			done:     a.inputs.done(input),
+		})
+	}
+	a.reloadMu.Unlock()
+
+	if len(targets) == 0 {
+		http.Error(w, fmt.Sprintf("input %q not found", name), http.StatusNotFound)
+		return
+	}
+
+	for _, t := range targets {
+		log.Printf("I! [agent] Gathering input %s on request", t.name)
+		err := requestGather(r.Context(), t.requests, t.done)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("gather %s: %v", t.name, err),
+				http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, map[string]int{"gathered": len(targets)})
+}
+
+// requestGather asks the goroutine of an input to gather it and waits for the
+// gather, done is closed once the goroutine returns.
+func requestGather(ctx context.Context, requests chan<- gatherRequest, done <-chan struct{}) error {
+	req := make(gatherRequest, 1)
+	select {
+	case requests <- req:
+	case <-done:
+		return errors.New("input is stopped")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req:
+		return err
+	case <-done:
+		return errors.New("input is stopped")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serveFlush runs an immediate write of the named output of the main
+// configuration, handling requests to /outputs/<name>/flush.
+func (a *Agent) serveFlush(w http.ResponseWriter, r *http.Request) {
+	name, ok := actionPath(r.URL.Path, "/outputs/", "/flush")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	a.flushOutputs(w, r, name)
+}
+
+// flushOutputs writes the buffered metrics of the outputs of the pipeline with
+// the given name.
+func (a *Agent) flushOutputs(w http.ResponseWriter, r *http.Request, name string) {
+	// Holding the lock keeps the outputs from being removed while writing.
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	if a.outputs == nil || a.outputs.isClosed() {
+		http.Error(w, "agent is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	var found int
+	for _, output := range a.Config.Outputs {
+		if output.Name != name && "outputs."+output.Name != name {
+			continue
+		}
+		found++
+
+		interval := a.Config.Agent.FlushInterval.Duration
+		if output.Config.FlushInterval != 0 {
+			interval = output.Config.FlushInterval
+		}
+
+		log.Printf("I! [agent] Flushing output %s on request", output.Name)
+		err := a.flushOnce(output, interval, output.Write)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("flush %s: %v", output.Name, err),
+				http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if found == 0 {
+		http.Error(w, fmt.Sprintf("output %q not found", name), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]int{"flushed": found})
+}
+
+// actionPath returns the plugin name from a path of the form
+// <prefix><name><suffix>.
+func actionPath(path, prefix, suffix string) (string, bool) {
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+		return "", false
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(path, prefix), suffix)
+	if name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	return name, true
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("E! [agent] Error writing API response: %v", err)
+	}
+}
+
+// filterConfig returns the non empty metric filter settings.
+func filterConfig(f models.Filter) map[string]interface{} {
+	config := make(map[string]interface{})
+	setStrings := func(key string, values []string) {
+		if len(values) > 0 {
+			config[key] = values
+		}
+	}
+	setTags := func(key string, filters []models.TagFilter) {
+		if len(filters) > 0 {
+			tags := make(map[string][]string, len(filters))
+			for _, tf := range filters {
+				tags[tf.Name] = tf.Filter
+			}
+			config[key] = tags
+		}
+	}
+
+	setStrings("namepass", f.NamePass)
+	setStrings("namedrop", f.NameDrop)
+	setStrings("fieldpass", f.FieldPass)
+	setStrings("fielddrop", f.FieldDrop)
+	setStrings("taginclude", f.TagInclude)
+	setStrings("tagexclude", f.TagExclude)
+	setTags("tagpass", f.TagPass)
+	setTags("tagdrop", f.TagDrop)
+	return config
+}
+
+func setString(config map[string]interface{}, key, value string) {
+	if value != "" {
+		config[key] = value
+	}
+}
+
+func setDuration(config map[string]interface{}, key string, value time.Duration) {
+	if value != 0 {
+		config[key] = value.String()
+	}
+}
+
+// redactTags returns a copy of the tags with the secrets of the configuration
+// replaced.
+func redactTags(tags map[string]string) map[string]string {
+	redacted := make(map[string]string, len(tags))
+	for k, v := range tags {
+		redacted[k] = logger.Redact(v)
+	}
+	return redacted
+}
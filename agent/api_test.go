@@ -0,0 +1,274 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent_APIPlugins(t *testing.T) {
+	a, err := NewAgent(newReloadConfig(1, "a", &reloadOutput{}))
+	require.NoError(t, err)
+	a.Config.Inputs[0].Config.Interval = 5 * time.Second
+
+	rec := httptest.NewRecorder()
+	a.apiHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/plugins", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var plugins map[string][]pluginInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &plugins))
+	require.Len(t, plugins["inputs"], 1)
+	require.Equal(t, "inputs.reload", plugins["inputs"][0].Name)
+	require.Equal(t, "a", plugins["inputs"][0].Checksum)
+	require.Equal(t, "5s", plugins["inputs"][0].Config["interval"])
+	require.Len(t, plugins["outputs"], 1)
+	require.Equal(t, "outputs.reload", plugins["outputs"][0].Name)
+	require.Len(t, plugins["processors"], 0)
+	require.Len(t, plugins["aggregators"], 0)
+}
+
+func TestAgent_APIOutputs(t *testing.T) {
+	a, err := NewAgent(newReloadConfig(1, "a", &reloadOutput{}))
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	a.apiHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/outputs", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var outputs []outputInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &outputs))
+	require.Equal(t, []outputInfo{
+		{Name: "outputs.reload", BufferSize: 0, BufferLimit: 10000},
+	}, outputs)
+}
+
+func TestAgent_APIStats(t *testing.T) {
+	a, err := NewAgent(newReloadConfig(1, "a", &reloadOutput{}))
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	a.apiHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/stats", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var stats []statInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+}
+
+func TestAgent_APIErrors(t *testing.T) {
+	a, err := NewAgent(newReloadConfig(1, "a", &reloadOutput{}))
+	require.NoError(t, err)
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{"POST", "/plugins", http.StatusMethodNotAllowed},
+		{"GET", "/inputs/reload/gather", http.StatusMethodNotAllowed},
+		{"POST", "/inputs/reload/flush", http.StatusNotFound},
+		{"POST", "/inputs/reload/cpu/gather", http.StatusNotFound},
+		{"POST", "/inputs/reload/gather", http.StatusServiceUnavailable},
+		{"POST", "/outputs/reload/flush", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		a.apiHandler().ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		require.Equal(t, tt.code, rec.Code, "%s %s", tt.method, tt.path)
+	}
+}
+
+func TestAgent_APIGatherFlush(t *testing.T) {
+	output := &reloadOutput{}
+	c := newReloadConfig(1, "a", output)
+	c.Agent.Interval.Duration = time.Hour
+	c.Agent.FlushInterval.Duration = time.Hour
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+	waitFor(t, func() bool {
+		a.reloadMu.Lock()
+		defer a.reloadMu.Unlock()
+		return a.outputs != nil
+	})
+
+	handler := a.apiHandler()
+	post := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", path, nil))
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusNotFound, post("/inputs/missing/gather"))
+	require.Equal(t, http.StatusNotFound, post("/outputs/missing/flush"))
+
+	// The input is gathered once on startup, the next gather is an hour away.
+	ro := a.Config.Outputs[0]
+	waitFor(t, func() bool { return ro.BufferLength() == 1 })
+	require.Equal(t, http.StatusOK, post("/inputs/reload/gather"))
+	waitFor(t, func() bool { return ro.BufferLength() == 2 })
+	require.False(t, output.received(1))
+
+	require.Equal(t, http.StatusOK, post("/outputs/reload/flush"))
+	require.True(t, output.received(1))
+	require.Equal(t, 0, ro.BufferLength())
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+// serialInput blocks its gathers until released and records whether two
+// gathers ever ran at once.
+type serialInput struct {
+	release chan struct{}
+	running int32
+	overlap int32
+	gathers int32
+}
+
+func (i *serialInput) SampleConfig() string { return "" }
+func (i *serialInput) Description() string  { return "" }
+func (i *serialInput) Gather(acc telegraf.Accumulator) error {
+	if atomic.AddInt32(&i.running, 1) > 1 {
+		atomic.StoreInt32(&i.overlap, 1)
+	}
+	<-i.release
+	atomic.AddInt32(&i.running, -1)
+	atomic.AddInt32(&i.gathers, 1)
+	return nil
+}
+
+func TestAgent_APIGatherSerialized(t *testing.T) {
+	input := &serialInput{release: make(chan struct{})}
+	c := newReloadConfig(1, "a", &reloadOutput{})
+	c.Agent.Interval.Duration = time.Hour
+	c.Inputs = []*models.RunningInput{models.NewRunningInput(input,
+		&models.InputConfig{Name: "serial"})}
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+	waitFor(t, func() bool { return atomic.LoadInt32(&input.running) == 1 })
+
+	code := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		a.apiHandler().ServeHTTP(rec, httptest.NewRequest("POST", "/inputs/serial/gather", nil))
+		code <- rec.Code
+	}()
+
+	// The requested gather waits for the scheduled one, without blocking
+	// reloads meanwhile.
+	locked := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		a.reloadMu.Lock()
+		a.reloadMu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload lock held while gathering")
+	}
+
+	close(input.release)
+	require.Equal(t, http.StatusOK, <-code)
+	require.Equal(t, int32(2), atomic.LoadInt32(&input.gathers))
+	require.Equal(t, int32(0), atomic.LoadInt32(&input.overlap))
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestAgent_APIPipelines(t *testing.T) {
+	output := &reloadOutput{}
+	c := newReloadConfig(1, "a", &reloadOutput{})
+	c.Agent.Interval.Duration = time.Hour
+	c.Agent.FlushInterval.Duration = time.Hour
+	p := newReloadConfig(2, "b", output)
+	p.Pipeline = "other"
+	p.Agent.Interval.Duration = time.Hour
+	p.Agent.FlushInterval.Duration = time.Hour
+	c.Pipelines = append(c.Pipelines, p)
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	handler := a.apiHandler()
+	get := func(path string, v interface{}) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v))
+	}
+	call := func(method, path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec.Code
+	}
+	post := func(path string) int {
+		return call("POST", path)
+	}
+
+	var plugins map[string][]pluginInfo
+	get("/plugins", &plugins)
+	require.Len(t, plugins["inputs"], 2)
+	require.Equal(t, "", plugins["inputs"][0].Pipeline)
+	require.Equal(t, "a", plugins["inputs"][0].Checksum)
+	require.Equal(t, "other", plugins["inputs"][1].Pipeline)
+	require.Equal(t, "b", plugins["inputs"][1].Checksum)
+
+	var outputs []outputInfo
+	get("/outputs", &outputs)
+	require.Equal(t, []outputInfo{
+		{Name: "outputs.reload", BufferSize: 0, BufferLimit: 10000},
+		{Name: "outputs.reload", Pipeline: "other", BufferSize: 0, BufferLimit: 10000},
+	}, outputs)
+
+	require.Equal(t, http.StatusNotFound, post("/pipelines/missing/inputs/reload/gather"))
+	require.Equal(t, http.StatusNotFound, post("/pipelines/other/inputs/reload/flush"))
+	require.Equal(t, http.StatusMethodNotAllowed, call("GET", "/pipelines/other/outputs/reload/flush"))
+	require.Equal(t, http.StatusServiceUnavailable, post("/pipelines/other/inputs/reload/gather"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	// Each pipeline gathers its input once on startup.
+	first := a.Config.Outputs[0]
+	ro := a.pipelines[0].Config.Outputs[0]
+	waitFor(t, func() bool { return first.BufferLength() == 1 && ro.BufferLength() == 1 })
+	require.Equal(t, http.StatusNotFound, post("/pipelines/other/inputs/missing/gather"))
+	require.Equal(t, http.StatusOK, post("/pipelines/other/inputs/reload/gather"))
+	waitFor(t, func() bool { return ro.BufferLength() == 2 })
+
+	require.Equal(t, http.StatusOK, post("/pipelines/other/outputs/reload/flush"))
+	require.True(t, output.received(2))
+	require.Equal(t, 0, ro.BufferLength())
+	require.Equal(t, 1, first.BufferLength())
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"log"
+
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+// failover selects the outputs that receive metrics.  Outputs without a
+// failover group always receive metrics, of each group only the first
+// healthy output does.  When no output of a group is healthy the first
+// output is used, so that its buffer holds the metrics until it recovers.
+type failover struct {
+	// Index within the group of the output last selected.
+	active map[string]int
+
+	// The selection is kept until the outputs, or the health of an output
+	// in a group, change.
+	configured []*models.RunningOutput
+	healthy    []bool
+	selected   []*models.RunningOutput
+}
+
+// outputs returns the outputs that should receive the next metric.
+func (f *failover) outputs(outputs []*models.RunningOutput) []*models.RunningOutput {
+	if f.selected != nil && !f.changed(outputs) {
+		return f.selected
+	}
+
+	f.configured = outputs
+	f.healthy = f.healthy[:0]
+	for _, output := range outputs {
+		f.healthy = append(f.healthy, output.Healthy())
+	}
+	f.selected = f.selectOutputs(outputs)
+	return f.selected
+}
+
+// changed reports whether the outputs or their health differ from the last
+// selection.
+func (f *failover) changed(outputs []*models.RunningOutput) bool {
+	if len(outputs) != len(f.configured) {
+		return true
+	}
+	for i, output := range outputs {
+		if output != f.configured[i] {
+			return true
+		}
+		if output.Config.FailoverGroup != "" && output.Healthy() != f.healthy[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// selectOutputs selects the outputs according to the health recorded in
+// f.healthy.
+func (f *failover) selectOutputs(outputs []*models.RunningOutput) []*models.RunningOutput {
+	var selected map[string]int
+	for i, output := range outputs {
+		group := output.Config.FailoverGroup
+		if group == "" {
+			continue
+		}
+		if selected == nil {
+			selected = make(map[string]int)
+		}
+
+		current, ok := selected[group]
+		if !ok || (!f.healthy[current] && f.healthy[i]) {
+			selected[group] = i
+		}
+	}
+	if selected == nil {
+		return outputs
+	}
+
+	active := make([]*models.RunningOutput, 0, len(outputs))
+	for i, output := range outputs {
+		group := output.Config.FailoverGroup
+		if group == "" || selected[group] == i {
+			active = append(active, output)
+		}
+	}
+
+	f.logChanges(outputs, selected)
+	return active
+}
+
+// logChanges logs when a group starts sending metrics to a different output.
+func (f *failover) logChanges(outputs []*models.RunningOutput, selected map[string]int) {
+	if f.active == nil {
+		f.active = make(map[string]int)
+	}
+
+	for group, i := range selected {
+		member := 0
+		for _, output := range outputs[:i] {
+			if output.Config.FailoverGroup == group {
+				member++
+			}
+		}
+
+		previous, ok := f.active[group]
+		f.active[group] = member
+		if !ok || previous == member {
+			continue
+		}
+		if f.healthy[i] {
+			log.Printf("I! [agent] Failover group %q switched to output %s (member %d)",
+				group, outputs[i].Name, member+1)
+		} else {
+			log.Printf("W! [agent] Failover group %q has no healthy outputs, "+
+				"buffering in output %s", group, outputs[i].Name)
+		}
+	}
+}
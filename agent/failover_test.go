@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type failoverOutput struct {
+	fail bool
+}
+
+func (o *failoverOutput) SampleConfig() string { return "" }
+func (o *failoverOutput) Description() string  { return "" }
+func (o *failoverOutput) Connect() error       { return nil }
+func (o *failoverOutput) Close() error         { return nil }
+func (o *failoverOutput) Write(metrics []telegraf.Metric) error {
+	if o.fail {
+		return errors.New("write failed")
+	}
+	return nil
+}
+
+func newFailoverOutput(name, group string, output *failoverOutput) *models.RunningOutput {
+	return models.NewRunningOutput(name, output,
+		&models.OutputConfig{Name: name, FailoverGroup: group}, 1000, 10000)
+}
+
+func TestFailover(t *testing.T) {
+	primary := &failoverOutput{}
+	secondary := &failoverOutput{}
+	outputs := []*models.RunningOutput{
+		newFailoverOutput("primary", "influx", primary),
+		newFailoverOutput("other", "", &failoverOutput{}),
+		newFailoverOutput("secondary", "influx", secondary),
+	}
+
+	write := func(ro *models.RunningOutput) {
+		ro.AddMetric(testutil.TestMetric(1))
+		ro.Write()
+	}
+
+	var f failover
+	require.Equal(t, outputs[:2], f.outputs(outputs))
+
+	// Metrics go to the secondary while the primary fails.
+	primary.fail = true
+	write(outputs[0])
+	require.Equal(t, outputs[1:], f.outputs(outputs))
+
+	// With no healthy outputs the primary is used.
+	secondary.fail = true
+	write(outputs[2])
+	require.Equal(t, outputs[:2], f.outputs(outputs))
+
+	secondary.fail = false
+	write(outputs[2])
+	require.Equal(t, outputs[1:], f.outputs(outputs))
+
+	// Fail back once the primary recovers.
+	primary.fail = false
+	write(outputs[0])
+	require.Equal(t, outputs[:2], f.outputs(outputs))
+}
+
+func TestFailoverNoGroups(t *testing.T) {
+	outputs := []*models.RunningOutput{
+		newFailoverOutput("a", "", &failoverOutput{}),
+		newFailoverOutput("b", "", &failoverOutput{}),
+	}
+
+	var f failover
+	require.Equal(t, outputs, f.outputs(outputs))
+}
+
+func TestFailoverCached(t *testing.T) {
+	primary := &failoverOutput{}
+	outputs := []*models.RunningOutput{
+		newFailoverOutput("primary", "influx", primary),
+		newFailoverOutput("secondary", "influx", &failoverOutput{}),
+	}
+
+	var f failover
+	require.Equal(t, outputs[:1], f.outputs(outputs))
+	allocs := testing.AllocsPerRun(100, func() {
+		f.outputs(outputs)
+	})
+	require.Zero(t, allocs)
+
+	// The selection changes with the health of the outputs, and with the
+	// outputs themselves.
+	primary.fail = true
+	outputs[0].AddMetric(testutil.TestMetric(1))
+	outputs[0].Write()
+	require.Equal(t, outputs[1:], f.outputs(outputs))
+
+	reloaded := []*models.RunningOutput{
+		newFailoverOutput("other", "influx", &failoverOutput{}),
+	}
+	require.Equal(t, reloaded, f.outputs(reloaded))
+}
@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var errGroupClosed = errors.New("group is closed")
+
+// unit is a plugin goroutine run by a group.
+type unit struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// group runs one goroutine per plugin.  Plugins can be stopped individually,
+// which is used when the configuration is reloaded, or all at once when the
+// group is closed.
+type group struct {
+	sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	units  map[interface{}]*unit
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func newGroup(ctx context.Context) *group {
+	ctx, cancel := context.WithCancel(ctx)
+	return &group{
+		ctx:    ctx,
+		cancel: cancel,
+		units:  make(map[interface{}]*unit),
+	}
+}
+
+// start runs fn in a new goroutine for the plugin.  The context passed to fn
+// is done when the plugin is stopped or the group is closed.
+func (g *group) start(plugin interface{}, fn func(ctx context.Context)) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.closed {
+		return errGroupClosed
+	}
+
+	ctx, cancel := context.WithCancel(g.ctx)
+	u := &unit{cancel: cancel, done: make(chan struct{})}
+	g.units[plugin] = u
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer close(u.done)
+		fn(ctx)
+	}()
+	return nil
+}
+
+// stop cancels the plugin and waits for its goroutine to return.
+func (g *group) stop(plugin interface{}) {
+	g.Lock()
+	u, ok := g.units[plugin]
+	delete(g.units, plugin)
+	g.Unlock()
+
+	if !ok {
+		return
+	}
+	u.cancel()
+	<-u.done
+}
+
+// done returns a channel closed once the goroutine of the plugin returns, it
+// is closed already if the plugin is not running.
+func (g *group) done(plugin interface{}) <-chan struct{} {
+	g.Lock()
+	defer g.Unlock()
+
+	if u, ok := g.units[plugin]; ok {
+		return u.done
+	}
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+// isClosed returns true once close has been called.
+func (g *group) isClosed() bool {
+	g.Lock()
+	defer g.Unlock()
+	return g.closed
+}
+
+// close cancels all plugins and waits for their goroutines to return, no
+// plugins can be started afterwards.
+func (g *group) close() {
+	g.Lock()
+	g.closed = true
+	g.Unlock()
+
+	g.cancel()
+	g.wg.Wait()
+}
@@ -0,0 +1,304 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/config"
+	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// configHash is the hash of the configuration the agent is running with.
+var configHash = selfstat.Register("agent", "config_hash", map[string]string{})
+
+// ErrRestartRequired is returned by Reload when the new configuration cannot
+// be applied to the running agent.
+var ErrRestartRequired = errors.New("configuration change requires an agent restart")
+
+// Reload applies a new configuration to the running agent.
+//
+// Plugins are compared using the checksum of their configuration table, only
+// plugins that were added, removed or changed are stopped and started.
+// Unchanged plugins keep running along with their buffers and service
+// listeners.  Changes to the agent settings or global tags, and adding or
+// removing pipelines, require a restart and are not applied.
+//
+// Plugins that fail to start are logged and left out of the configuration.
+func (a *Agent) Reload(c *config.Config) error {
+	pipelines := a.allPipelines()
+	configs := append([]*config.Config{c}, c.Pipelines...)
+	if len(configs) != len(pipelines) {
+		return ErrRestartRequired
+	}
+
+	for _, p := range pipelines {
+		p.reloadMu.Lock()
+		defer p.reloadMu.Unlock()
+	}
+
+	for i, p := range pipelines {
+		if !p.canReload(configs[i]) {
+			return ErrRestartRequired
+		}
+	}
+
+	now := time.Now()
+	for i, p := range pipelines {
+		p.reloadOutputs(now, configs[i].Outputs)
+		p.reloadProcessors(configs[i].Processors)
+		p.reloadAggregators(now, configs[i].Aggregators)
+		p.reloadInputs(now, configs[i].Inputs)
+	}
+	configHash.Set(c.Hash())
+	return nil
+}
+
+// canReload returns true if the pipeline is running and the configuration
+// only differs from it in its plugins.
+func (a *Agent) canReload(c *config.Config) bool {
+	if a.inputs == nil || a.inputs.isClosed() {
+		return false
+	}
+
+	return a.Config.Pipeline == c.Pipeline &&
+		reflect.DeepEqual(a.Config.Agent, c.Agent) &&
+		reflect.DeepEqual(a.Config.Tags, c.Tags)
+}
+
+// reloadOutputs replaces the running outputs.
+//
+// The lock is only held to replace the outputs, so that a slow write or
+// connection does not stall the pipeline.  New outputs receive metrics from
+// then on, buffering them until they are connected, so that no metrics are
+// lost while an output is replaced.  Removed outputs are closed before the
+// new outputs connect, allowing them to reuse listeners.
+func (a *Agent) reloadOutputs(now time.Time, configured []*models.RunningOutput) {
+	running := make([]string, 0, len(a.Config.Outputs))
+	for _, output := range a.Config.Outputs {
+		running = append(running, output.Config.Name+output.Checksum)
+	}
+	keys := make([]string, 0, len(configured))
+	for _, output := range configured {
+		keys = append(keys, output.Config.Name+output.Checksum)
+	}
+	reuse, removed := matchPlugins(running, keys)
+
+	outputs := make([]*models.RunningOutput, 0, len(configured))
+	var added []*models.RunningOutput
+	for i, output := range configured {
+		if reuse[i] >= 0 {
+			outputs = append(outputs, a.Config.Outputs[reuse[i]])
+			continue
+		}
+		a.setOutputStatus(output)
+		outputs = append(outputs, output)
+		added = append(added, output)
+	}
+
+	a.mu.Lock()
+	previous := a.Config.Outputs
+	a.Config.Outputs = outputs
+	a.mu.Unlock()
+	a.statusOutputs.Store(outputs)
+
+	for _, i := range removed {
+		output := previous[i]
+		log.Printf("I! [agent] Stopping output %s", output.Name)
+		a.outputs.stop(output)
+		if err := output.Close(); err != nil {
+			log.Printf("E! [agent] Error closing output %s: %v", output.Name, err)
+		}
+	}
+
+	for _, output := range added {
+		log.Printf("I! [agent] Starting output %s", output.Name)
+		a.startReloadedOutput(now, output)
+	}
+}
+
+// startReloadedOutput starts an output added by a reload, it is connected
+// first and retried every 15s until it succeeds.
+func (a *Agent) startReloadedOutput(now time.Time, output *models.RunningOutput) error {
+	return a.outputs.start(output, func(ctx context.Context) {
+		for {
+			err := output.Connect()
+			if err == nil {
+				break
+			}
+			log.Printf("E! [agent] Failed to connect to output %s, retrying in 15s: %v",
+				output.Name, err)
+			if err := internal.SleepContext(ctx, 15*time.Second); err != nil {
+				return
+			}
+		}
+		a.runOutput(ctx, now, output)
+	})
+}
+
+// reloadProcessors replaces the processors, removed processors are stopped
+// once they are no longer applied.  The metrics returned by a stopped service
+// processor are forwarded to the next stage.
+func (a *Agent) reloadProcessors(configured models.RunningProcessors) {
+	running := make([]string, 0, len(a.Config.Processors))
+	for _, processor := range a.Config.Processors {
+		running = append(running, processor.Name+processor.Checksum)
+	}
+	keys := make([]string, 0, len(configured))
+	for _, processor := range configured {
+		keys = append(keys, processor.Name+processor.Checksum)
+	}
+	reuse, removed := matchPlugins(running, keys)
+
+	for _, i := range removed {
+		log.Printf("I! [agent] Removing processor %s", a.Config.Processors[i].Name)
+	}
+
+	processors := make(models.RunningProcessors, 0, len(configured))
+	for i, processor := range configured {
+		if reuse[i] >= 0 {
+			processor = a.Config.Processors[reuse[i]]
+		} else {
+			log.Printf("I! [agent] Adding processor %s", processor.Name)
+		}
+		processors = append(processors, processor)
+	}
+
+	a.mu.Lock()
+	previous := a.Config.Processors
+	a.Config.Processors = processors
+	a.mu.Unlock()
+
+	// The metrics output by a removed processor skip the rest of its stage.
+	for _, i := range removed {
+		stage := previous[i].Config.Stage
+		if stage == models.StageDefault {
+			stage = models.StageInputs
+		}
+		a.addStopped(stage, previous[i].Stop())
+	}
+}
+
+// reloadAggregators replaces the aggregators.  Removed aggregators push
+// their current aggregations before they stop.
+func (a *Agent) reloadAggregators(now time.Time, configured []*models.RunningAggregator) {
+	running := make([]string, 0, len(a.Config.Aggregators))
+	for _, agg := range a.Config.Aggregators {
+		running = append(running, agg.Name()+agg.Checksum)
+	}
+	keys := make([]string, 0, len(configured))
+	for _, agg := range configured {
+		keys = append(keys, agg.Name()+agg.Checksum)
+	}
+	reuse, removed := matchPlugins(running, keys)
+
+	var added []*models.RunningAggregator
+	aggregators := make([]*models.RunningAggregator, 0, len(configured))
+	for i, agg := range configured {
+		if reuse[i] >= 0 {
+			agg = a.Config.Aggregators[reuse[i]]
+		} else {
+			added = append(added, agg)
+		}
+		aggregators = append(aggregators, agg)
+	}
+
+	a.mu.Lock()
+	previous := a.Config.Aggregators
+	a.Config.Aggregators = aggregators
+	a.mu.Unlock()
+
+	// The lock must not be held while stopping, the final push is sent
+	// through the processors.
+	for _, i := range removed {
+		log.Printf("I! [agent] Stopping aggregator %s", previous[i].Name())
+		a.aggregators.stop(previous[i])
+	}
+
+	for _, agg := range added {
+		log.Printf("I! [agent] Starting aggregator %s", agg.Name())
+		a.startAggregator(now, agg)
+	}
+}
+
+// reloadInputs replaces the inputs.  Removed inputs are stopped before new
+// inputs are started, so that service inputs can listen on the same address.
+func (a *Agent) reloadInputs(now time.Time, configured []*models.RunningInput) {
+	running := make([]string, 0, len(a.Config.Inputs))
+	for _, input := range a.Config.Inputs {
+		running = append(running, input.Name()+input.Checksum)
+	}
+	keys := make([]string, 0, len(configured))
+	for _, input := range configured {
+		keys = append(keys, input.Name()+input.Checksum)
+	}
+	reuse, removed := matchPlugins(running, keys)
+
+	// The lock must not be held while stopping, a gather in progress may be
+	// waiting on the processors.
+	for _, i := range removed {
+		input := a.Config.Inputs[i]
+		log.Printf("I! [agent] Stopping input %s", input.Name())
+		a.inputs.stop(input)
+		delete(a.gatherRequests, input)
+		if si, ok := input.Input.(telegraf.ServiceInput); ok {
+			si.Stop()
+		}
+	}
+
+	inputs := make([]*models.RunningInput, 0, len(configured))
+	for i, input := range configured {
+		if reuse[i] >= 0 {
+			inputs = append(inputs, a.Config.Inputs[reuse[i]])
+			continue
+		}
+
+		log.Printf("I! [agent] Starting input %s", input.Name())
+		if si, ok := input.Input.(telegraf.ServiceInput); ok {
+			err := startServiceInput(input, si, a.inputC)
+			if err != nil {
+				log.Printf("E! [agent] Service for input %s failed to start: %v",
+					input.Name(), err)
+				continue
+			}
+		}
+		a.startInput(now, input)
+		inputs = append(inputs, input)
+	}
+
+	a.mu.Lock()
+	a.Config.Inputs = inputs
+	a.mu.Unlock()
+}
+
+// matchPlugins pairs each configured plugin with a running plugin that has
+// the same key.  For every configured plugin the index of the running plugin
+// to reuse, or -1, is returned along with the indexes of the running plugins
+// that are no longer configured.
+func matchPlugins(running, configured []string) ([]int, []int) {
+	used := make([]bool, len(running))
+	reuse := make([]int, len(configured))
+	for i, key := range configured {
+		reuse[i] = -1
+		for j := range running {
+			if !used[j] && running[j] == key {
+				used[j] = true
+				reuse[i] = j
+				break
+			}
+		}
+	}
+
+	var removed []int
+	for j := range running {
+		if !used[j] {
+			removed = append(removed, j)
+		}
+	}
+	return reuse, removed
+}
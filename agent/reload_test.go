@@ -0,0 +1,273 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/config"
+	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/stretchr/testify/require"
+)
+
+type reloadInput struct {
+	value int64
+}
+
+func (i *reloadInput) SampleConfig() string { return "" }
+func (i *reloadInput) Description() string  { return "" }
+func (i *reloadInput) Gather(acc telegraf.Accumulator) error {
+	acc.AddFields("reload", map[string]interface{}{"value": i.value}, nil)
+	return nil
+}
+
+type reloadOutput struct {
+	sync.Mutex
+	connects int
+	closes   int
+	values   map[int64]bool
+
+	// connect blocks Connect until it is closed, if set.
+	connect chan struct{}
+}
+
+func (o *reloadOutput) SampleConfig() string { return "" }
+func (o *reloadOutput) Description() string  { return "" }
+
+func (o *reloadOutput) Connect() error {
+	if o.connect != nil {
+		<-o.connect
+	}
+	o.Lock()
+	defer o.Unlock()
+	o.connects++
+	return nil
+}
+
+func (o *reloadOutput) Close() error {
+	o.Lock()
+	defer o.Unlock()
+	o.closes++
+	return nil
+}
+
+func (o *reloadOutput) Write(metrics []telegraf.Metric) error {
+	o.Lock()
+	defer o.Unlock()
+	if o.values == nil {
+		o.values = make(map[int64]bool)
+	}
+	for _, m := range metrics {
+		if v, ok := m.GetField("value"); ok {
+			o.values[v.(int64)] = true
+		}
+	}
+	return nil
+}
+
+func (o *reloadOutput) received(value int64) bool {
+	o.Lock()
+	defer o.Unlock()
+	return o.values[value]
+}
+
+func newReloadConfig(value int64, checksum string, output *reloadOutput) *config.Config {
+	c := config.NewConfig()
+	c.Agent.Interval.Duration = 10 * time.Millisecond
+	c.Agent.FlushInterval.Duration = 10 * time.Millisecond
+	c.Agent.RoundInterval = false
+
+	ri := models.NewRunningInput(&reloadInput{value: value},
+		&models.InputConfig{Name: "reload"})
+	ri.Checksum = checksum
+	c.Inputs = append(c.Inputs, ri)
+
+	ro := models.NewRunningOutput("reload", output,
+		&models.OutputConfig{Name: "reload"}, 1000, 10000)
+	ro.Checksum = "output"
+	c.Outputs = append(c.Outputs, ro)
+	return c
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAgent_ReloadNotRunning(t *testing.T) {
+	a, err := NewAgent(newReloadConfig(1, "a", &reloadOutput{}))
+	require.NoError(t, err)
+
+	err = a.Reload(newReloadConfig(2, "b", &reloadOutput{}))
+	require.Equal(t, ErrRestartRequired, err)
+}
+
+func TestAgent_Reload(t *testing.T) {
+	output := &reloadOutput{}
+	a, err := NewAgent(newReloadConfig(1, "a", output))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+	waitFor(t, func() bool { return output.received(1) })
+
+	// The output is unchanged and continues running, the new instance is
+	// never connected.
+	unused := &reloadOutput{}
+	err = a.Reload(newReloadConfig(2, "b", unused))
+	require.NoError(t, err)
+	waitFor(t, func() bool { return output.received(2) })
+
+	cancel()
+	require.NoError(t, <-done)
+
+	require.Equal(t, 1, output.connects)
+	require.Equal(t, 1, output.closes)
+	require.Equal(t, 0, unused.connects)
+	require.Len(t, a.Config.Inputs, 1)
+	require.Equal(t, "b", a.Config.Inputs[0].Checksum)
+}
+
+func TestAgent_ReloadOutputConnecting(t *testing.T) {
+	output := &reloadOutput{}
+	a, err := NewAgent(newReloadConfig(1, "a", output))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+	waitFor(t, func() bool { return output.received(1) })
+
+	// The reload does not wait for the new output to connect, it buffers
+	// the metrics meanwhile.
+	replaced := &reloadOutput{connect: make(chan struct{})}
+	c := newReloadConfig(1, "a", replaced)
+	c.Outputs[0].Checksum = "changed"
+	require.NoError(t, a.Reload(c))
+	output.Lock()
+	require.Equal(t, 1, output.closes)
+	output.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+	require.False(t, replaced.received(1))
+	close(replaced.connect)
+	waitFor(t, func() bool { return replaced.received(1) })
+
+	cancel()
+	require.NoError(t, <-done)
+	require.Equal(t, 1, replaced.connects)
+	require.Equal(t, 1, replaced.closes)
+}
+
+func TestAgent_ReloadAgentChanged(t *testing.T) {
+	output := &reloadOutput{}
+	a, err := NewAgent(newReloadConfig(1, "a", output))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+	waitFor(t, func() bool { return output.received(1) })
+
+	c := newReloadConfig(1, "a", &reloadOutput{})
+	c.Agent.Interval.Duration = time.Second
+	err = a.Reload(c)
+	require.Equal(t, ErrRestartRequired, err)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestMatchPlugins(t *testing.T) {
+	reuse, removed := matchPlugins(
+		[]string{"a", "b", "b", "c"},
+		[]string{"b", "d", "a", "b", "b"},
+	)
+	require.Equal(t, []int{1, -1, 0, 2, -1}, reuse)
+	require.Equal(t, []int{3}, removed)
+}
+
+func TestAgent_ReloadPipelines(t *testing.T) {
+	output := &reloadOutput{}
+	c := newReloadConfig(1, "a", &reloadOutput{})
+	p := newReloadConfig(2, "a", output)
+	p.Pipeline = "other"
+	c.Pipelines = append(c.Pipelines, p)
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+	waitFor(t, func() bool { return output.received(2) })
+
+	// Removing a pipeline requires a restart.
+	err = a.Reload(newReloadConfig(1, "a", &reloadOutput{}))
+	require.Equal(t, ErrRestartRequired, err)
+
+	c = newReloadConfig(1, "a", &reloadOutput{})
+	p = newReloadConfig(3, "b", &reloadOutput{})
+	p.Pipeline = "other"
+	c.Pipelines = append(c.Pipelines, p)
+	require.NoError(t, a.Reload(c))
+	waitFor(t, func() bool { return output.received(3) })
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestAgent_ReloadServiceProcessor(t *testing.T) {
+	holding := &holdingProcessor{}
+	c := config.NewConfig()
+	c.Processors = append(c.Processors,
+		&models.RunningProcessor{
+			Name:      "holding",
+			Processor: holding,
+			Config:    &models.ProcessorConfig{Name: "holding", Stage: models.StageInputs},
+			Checksum:  "a",
+		},
+		&models.RunningProcessor{
+			Name:      "inputs",
+			Processor: &stageProcessor{stage: models.StageInputs},
+			Config:    &models.ProcessorConfig{Name: "inputs", Stage: models.StageInputs},
+			Checksum:  "a",
+		})
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	m, err := metric.New("cpu", map[string]string{},
+		map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	require.NoError(t, err)
+	require.Empty(t, a.applyProcessors(models.StageInputs, m))
+
+	// The metrics of the removed processor are forwarded with the next flush
+	// of its stage, without the processors following it.
+	a.reloadProcessors(c.Processors[1:])
+	require.True(t, holding.stopped)
+	metrics := a.flushProcessors(models.StageInputs, false)
+	require.Len(t, metrics, 1)
+	require.Empty(t, metrics[0].Tags())
+	require.Empty(t, a.flushProcessors(models.StageInputs, false))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/influxdata/telegraf/internal/config"
+)
+
+// checkConfig loads the configuration files and prints every problem found,
+// returning an error if there are any.
+func checkConfig() error {
+	c := config.NewConfig()
+	c.Check = true
+
+	err := c.LoadConfig(*fConfig)
+	if err == nil && *fConfigDirectory != "" {
+		err = c.LoadDirectory(*fConfigDirectory)
+	}
+
+	problems := c.Problems
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].File != problems[j].File {
+			return problems[i].File < problems[j].File
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems in the configuration", len(problems))
+	}
+	fmt.Println("Configuration is valid")
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf/internal/config"
+)
+
+// runKeyring manages the secrets of a keyring file used by the keyring
+// secret store.  The password is read from the environment and secret values
+// from stdin, so that neither shows in the process list.
+func runKeyring(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: telegraf keyring <set|delete|list> <file> [key]")
+	}
+	command, path := args[0], args[1]
+
+	password, ok := os.LookupEnv("TELEGRAF_KEYRING_PASSWORD")
+	if !ok {
+		return errors.New("TELEGRAF_KEYRING_PASSWORD is not set")
+	}
+
+	secrets, err := config.ReadKeyring(path, password)
+	if os.IsNotExist(err) && command == "set" {
+		secrets = make(map[string]string)
+	} else if err != nil {
+		return err
+	}
+
+	switch command {
+	case "list":
+		keys := make([]string, 0, len(secrets))
+		for key := range secrets {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+		return nil
+	case "set", "delete":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: telegraf keyring %s <file> <key>", command)
+		}
+		key := args[2]
+
+		if command == "delete" {
+			delete(secrets, key)
+		} else {
+			value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && value == "" {
+				return fmt.Errorf("reading secret from stdin: %s", err)
+			}
+			secrets[key] = strings.TrimRight(value, "\r\n")
+		}
+		return config.WriteKeyring(path, password, secrets)
+	default:
+		return fmt.Errorf("unknown keyring command %q", command)
+	}
+}
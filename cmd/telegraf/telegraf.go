@@ -13,6 +13,7 @@ import (
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/influxdata/telegraf/agent"
 	"github.com/influxdata/telegraf/internal"
@@ -34,9 +35,13 @@ var pprofAddr = flag.String("pprof-addr", "",
 var fQuiet = flag.Bool("quiet", false,
 	"run in quiet mode")
 var fTest = flag.Bool("test", false, "gather metrics, print them out, and exit")
+var fTestWait = flag.Duration("test-wait", 0,
+	"wait for service inputs to collect metrics in test mode, implies --test")
 var fConfig = flag.String("config", "", "configuration file to load")
 var fConfigDirectory = flag.String("config-directory", "",
 	"directory containing additional *.conf files")
+var fConfigPollInterval = flag.Duration("config-poll-interval", 0,
+	"interval to poll a configuration loaded from a URL for changes")
 var fVersion = flag.Bool("version", false, "display the version and exit")
 var fSampleConfig = flag.Bool("sample-config", false,
 	"print out full sample configuration")
@@ -81,70 +86,172 @@ func reloadLoop(
 		reload <- false
 
 		ctx, cancel := context.WithCancel(context.Background())
+		running := make(chan *agent.Agent, 1)
 
-		signals := make(chan os.Signal)
+		signals := make(chan os.Signal, 1)
 		signal.Notify(signals, os.Interrupt, syscall.SIGHUP,
 			syscall.SIGTERM, syscall.SIGINT)
 		go func() {
-			select {
-			case sig := <-signals:
-				if sig == syscall.SIGHUP {
-					log.Printf("I! Reloading Telegraf config")
+			var poll <-chan time.Time
+			if *fConfigPollInterval > 0 {
+				ticker := time.NewTicker(*fConfigPollInterval)
+				defer ticker.Stop()
+				poll = ticker.C
+			}
+
+			var ag *agent.Agent
+			for {
+				select {
+				case ag = <-running:
+				case <-poll:
+					if ag == nil || !remoteConfigChanged(ag.Config) {
+						continue
+					}
+					log.Printf("I! Remote config changed, reloading Telegraf config")
+					if reloadAgent(ag, inputFilters, outputFilters) {
+						continue
+					}
 					<-reload
 					reload <- true
+					cancel()
+					return
+				case sig := <-signals:
+					if sig == syscall.SIGHUP {
+						log.Printf("I! Reloading Telegraf config")
+						if ag != nil && reloadAgent(ag, inputFilters, outputFilters) {
+							continue
+						}
+						<-reload
+						reload <- true
+					}
+					cancel()
+					return
+				case <-stop:
+					cancel()
+					return
 				}
-				cancel()
-			case <-stop:
-				cancel()
 			}
 		}()
 
-		err := runAgent(ctx, inputFilters, outputFilters)
+		err := runAgent(ctx, running, inputFilters, outputFilters)
 		if err != nil {
 			log.Fatalf("E! [telegraf] Error running agent: %v", err)
 		}
 	}
 }
 
-func runAgent(ctx context.Context,
+// reloadAgent applies the configuration to the running agent, returning
+// false if the agent needs to be restarted instead.  When the configuration
+// cannot be loaded the agent continues with its current configuration.
+func reloadAgent(ag *agent.Agent,
 	inputFilters []string,
 	outputFilters []string,
-) error {
-	// Setup default logging. This may need to change after reading the config
-	// file, but we can configure it to use our logger implementation now.
-	logger.SetupLogging(false, false, "")
-	log.Printf("I! Starting Telegraf %s", version)
+) bool {
+	c, err := loadConfig(inputFilters, outputFilters)
+	if err != nil {
+		log.Printf("E! [telegraf] Error loading config, keeping the "+
+			"current config: %v", err)
+		return true
+	}
 
-	// If no other options are specified, load the config file and run.
+	err = ag.Reload(c)
+	if err != nil {
+		log.Printf("I! [telegraf] Restarting agent: %v", err)
+		return false
+	}
+
+	log.Printf("I! Loaded inputs: %s", strings.Join(c.InputNames(), " "))
+	log.Printf("I! Loaded aggregators: %s", strings.Join(c.AggregatorNames(), " "))
+	log.Printf("I! Loaded processors: %s", strings.Join(c.ProcessorNames(), " "))
+	log.Printf("I! Loaded outputs: %s", strings.Join(c.OutputNames(), " "))
+	return true
+}
+
+// remoteConfigChanged returns true if any configuration loaded from a URL
+// has changed.
+func remoteConfigChanged(c *config.Config) bool {
+	var changed bool
+	for _, remote := range c.Remotes {
+		ok, err := remote.Changed()
+		if err != nil {
+			log.Printf("E! [telegraf] Error polling config %s: %v", remote.URL, err)
+			continue
+		}
+		changed = changed || ok
+	}
+	return changed
+}
+
+// loadConfig loads and validates the configuration files.
+func loadConfig(
+	inputFilters []string,
+	outputFilters []string,
+) (*config.Config, error) {
 	c := config.NewConfig()
 	c.OutputFilters = outputFilters
 	c.InputFilters = inputFilters
 	err := c.LoadConfig(*fConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if *fConfigDirectory != "" {
 		err = c.LoadDirectory(*fConfigDirectory)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
-	if !*fTest && len(c.Outputs) == 0 {
-		return errors.New("Error: no outputs found, did you provide a valid config file?")
+
+	// The main configuration may leave all plugins to its pipelines.
+	var inputs int
+	for _, p := range append([]*config.Config{c}, c.Pipelines...) {
+		inputs += len(p.Inputs)
+		if p.Pipeline == "" && len(c.Pipelines) > 0 && len(p.Inputs) == 0 {
+			continue
+		}
+		if !testMode() && len(p.Outputs) == 0 {
+			if p.Pipeline != "" {
+				return nil, fmt.Errorf("Error: no outputs found in pipeline %s", p.Pipeline)
+			}
+			return nil, errors.New("Error: no outputs found, did you provide a valid config file?")
+		}
+
+		if int64(p.Agent.Interval.Duration) <= 0 {
+			return nil, fmt.Errorf("Agent interval must be positive, found %s",
+				p.Agent.Interval.Duration)
+		}
+
+		if int64(p.Agent.FlushInterval.Duration) <= 0 {
+			return nil, fmt.Errorf("Agent flush_interval must be positive; found %s",
+				p.Agent.Interval.Duration)
+		}
 	}
-	if len(c.Inputs) == 0 {
-		return errors.New("Error: no inputs found, did you provide a valid config file?")
+	if inputs == 0 {
+		return nil, errors.New("Error: no inputs found, did you provide a valid config file?")
 	}
+	return c, nil
+}
 
-	if int64(c.Agent.Interval.Duration) <= 0 {
-		return fmt.Errorf("Agent interval must be positive, found %s",
-			c.Agent.Interval.Duration)
-	}
+// testMode returns true if the metrics should be printed instead of written to
+// the outputs.
+func testMode() bool {
+	return *fTest || *fTestWait > 0
+}
 
-	if int64(c.Agent.FlushInterval.Duration) <= 0 {
-		return fmt.Errorf("Agent flush_interval must be positive; found %s",
-			c.Agent.Interval.Duration)
+func runAgent(ctx context.Context,
+	running chan<- *agent.Agent,
+	inputFilters []string,
+	outputFilters []string,
+) error {
+	// Setup default logging. This may need to change after reading the config
+	// file, but we can configure it to use our logger implementation now.
+	logger.SetupLogging(false, false, "", "")
+	log.Printf("I! Starting Telegraf %s", version)
+
+	// If no other options are specified, load the config file and run.
+	c, err := loadConfig(inputFilters, outputFilters)
+	if err != nil {
+		return err
 	}
 
 	ag, err := agent.NewAgent(c)
@@ -157,10 +264,11 @@ func runAgent(ctx context.Context,
 		ag.Config.Agent.Debug || *fDebug,
 		ag.Config.Agent.Quiet || *fQuiet,
 		ag.Config.Agent.Logfile,
+		ag.Config.Agent.LogFormat,
 	)
 
-	if *fTest {
-		return ag.Test(ctx)
+	if testMode() {
+		return ag.Test(ctx, *fTestWait)
 	}
 
 	log.Printf("I! Loaded inputs: %s", strings.Join(c.InputNames(), " "))
@@ -187,6 +295,7 @@ func runAgent(ctx context.Context,
 		}
 	}
 
+	running <- ag
 	return ag.Run(ctx)
 }
 
@@ -288,6 +397,12 @@ func main() {
 			fmt.Println(formatFullVersion())
 			return
 		case "config":
+			if len(args) > 1 && args[1] == "check" {
+				if err := checkConfig(); err != nil {
+					log.Fatal("E! " + err.Error())
+				}
+				return
+			}
 			config.PrintSampleConfig(
 				inputFilters,
 				outputFilters,
@@ -295,6 +410,11 @@ func main() {
 				processorFilters,
 			)
 			return
+		case "keyring":
+			if err := runKeyring(args[1:]); err != nil {
+				log.Fatal("E! " + err.Error())
+			}
+			return
 		}
 	}
 
@@ -1,5 +1,7 @@
 package telegraf
 
+import "context"
+
 type Input interface {
 	// SampleConfig returns the default configuration of the Input
 	SampleConfig() string
@@ -12,6 +14,17 @@ type Input interface {
 	Gather(Accumulator) error
 }
 
+// ContextInput is an Input whose gather can be cancelled.  GatherContext is
+// called instead of Gather, the context is done when the gather times out or
+// the agent is stopping.
+type ContextInput interface {
+	Input
+
+	// GatherContext gathers metrics like Gather, returning early once the
+	// context is done.
+	GatherContext(ctx context.Context, acc Accumulator) error
+}
+
 type ServiceInput interface {
 	Input
 
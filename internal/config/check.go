@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/influxdata/toml/ast"
+)
+
+// Problem is an issue found while checking the configuration.
+type Problem struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.File, p.Message)
+}
+
+// addProblem records a problem in the file being loaded.
+func (c *Config) addProblem(line int, format string, args ...interface{}) {
+	c.Problems = append(c.Problems, Problem{
+		File:    c.loading,
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// pluginError handles an error loading the plugin defined in tbl.  When
+// checking, the error is recorded and nil is returned so that the remaining
+// plugins are still checked.
+func (c *Config) pluginError(path string, tbl *ast.Table, err error) error {
+	if err == nil {
+		return nil
+	}
+	if c.Check {
+		c.addProblem(tbl.Line, "%s", err)
+		return nil
+	}
+	return fmt.Errorf("Error parsing %s, %s", path, err)
+}
+
+// checkOptions records the options of tbl which do not match a field of v, and
+// those which are deprecated.  Unknown options are removed from the table, so
+// that the rest of it can still be loaded.
+func (c *Config) checkOptions(source string, tbl *ast.Table, v interface{}) {
+	if !c.Check {
+		return
+	}
+	c.checkTableOptions(source, "", tbl, reflect.TypeOf(v))
+}
+
+func (c *Config) checkTableOptions(source, prefix string, tbl *ast.Table, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	keys := make([]string, 0, len(tbl.Fields))
+	for key := range tbl.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		node := tbl.Fields[key]
+		line := nodeLine(node)
+
+		field, ok := findOption(t, key)
+		if !ok {
+			c.addProblem(line, "%s: unknown option %q", source, prefix+key)
+			delete(tbl.Fields, key)
+			continue
+		}
+		if tag, ok := field.Tag.Lookup("deprecated"); ok {
+			c.addProblem(line, "%s: option %q is %s", source, prefix+key,
+				deprecationNotice(tag))
+		}
+
+		elem := field.Type
+		for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Slice {
+			elem = elem.Elem()
+		}
+		switch node := node.(type) {
+		case *ast.Table:
+			c.checkTableOptions(source, prefix+key+".", node, elem)
+		case []*ast.Table:
+			for _, t := range node {
+				c.checkTableOptions(source, prefix+key+".", t, elem)
+			}
+		}
+	}
+}
+
+// findOption returns the struct field the toml package would set for the
+// option name.
+func findOption(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		if ft.PkgPath != "" {
+			continue
+		}
+		tag := strings.SplitN(ft.Tag.Get("toml"), ",", 2)[0]
+		if strings.TrimSpace(tag) == name {
+			return ft, true
+		}
+		if ft.Anonymous && ft.Type.Kind() == reflect.Struct {
+			if field, ok := findOption(ft.Type, name); ok {
+				return field, true
+			}
+		}
+	}
+	for _, n := range []string{strings.Title(name), toCamelCase(name), strings.ToUpper(name)} {
+		if field, ok := t.FieldByName(n); ok {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// toCamelCase converts a snake_case option name to a field name.
+func toCamelCase(s string) string {
+	var result []rune
+	upper := true
+	for _, r := range s {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		result = append(result, r)
+	}
+	return string(result)
+}
+
+// deprecationNotice formats a deprecated tag, which has the form
+// "<version>;<notice>".
+func deprecationNotice(tag string) string {
+	parts := strings.SplitN(tag, ";", 2)
+	if len(parts) == 1 {
+		return "deprecated: " + strings.TrimSpace(tag)
+	}
+	return fmt.Sprintf("deprecated since %s: %s",
+		strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+}
+
+func nodeLine(node interface{}) int {
+	switch node := node.(type) {
+	case *ast.KeyValue:
+		return node.Line
+	case *ast.Table:
+		return node.Line
+	case []*ast.Table:
+		if len(node) > 0 {
+			return node[0].Line
+		}
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type checkInput struct {
+	Address  string
+	Server   string `deprecated:"1.2;use address"`
+	Endpoint []struct {
+		URL string `toml:"url"`
+	}
+}
+
+func (i *checkInput) SampleConfig() string                  { return "" }
+func (i *checkInput) Description() string                   { return "" }
+func (i *checkInput) Gather(acc telegraf.Accumulator) error { return nil }
+
+func TestConfig_Check(t *testing.T) {
+	inputs.Add("check_test", func() telegraf.Input { return &checkInput{} })
+	defer delete(inputs.Inputs, "check_test")
+
+	c := NewConfig()
+	c.Check = true
+	require.NoError(t, c.LoadConfig("./testdata/check.toml"))
+
+	messages := make(map[string]int)
+	for _, p := range c.Problems {
+		assert.Equal(t, "./testdata/check.toml", p.File)
+		messages[p.Message] = p.Line
+	}
+	assert.Len(t, c.Problems, 6)
+	assert.Equal(t, 3, messages[`agent: unknown option "metric_batchsize"`])
+	assert.Equal(t, 7, messages[`inputs.memcached: unknown option "unix_socket"`])
+	assert.Equal(t, 11, messages[`inputs.check_test: option "server" is deprecated since 1.2: use address`])
+	assert.Equal(t, 15, messages[`inputs.check_test: unknown option "endpoint.methods"`])
+	assert.Equal(t, 20, messages["Undefined but requested input: undefined"])
+	assert.Equal(t, 17, messages["Error compiling 'namepass', unexpected end of input"])
+
+	// The remaining configuration is still loaded.
+	assert.Equal(t, 10*time.Second, c.Agent.Interval.Duration)
+	assert.Len(t, c.Inputs, 2)
+}
+
+func TestConfig_CheckDisabled(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfig("./testdata/check.toml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "metric_batchsize")
+	assert.Empty(t, c.Problems)
+}
@@ -2,12 +2,18 @@ package config
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
 	"io/ioutil"
 	"log"
 	"math"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -20,7 +26,9 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/cron"
 	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/logger"
 	"github.com/influxdata/telegraf/plugins/aggregators"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/outputs"
@@ -62,6 +70,32 @@ type Config struct {
 	Aggregators []*models.RunningAggregator
 	// Processors have a slice wrapper type because they need to be sorted
 	Processors models.RunningProcessors
+
+	// Check makes loading record configuration problems, such as unknown
+	// or deprecated options, in Problems instead of stopping at the first
+	// error.
+	Check    bool
+	Problems []Problem
+
+	// Remotes are the configuration files loaded from URLs.
+	Remotes []*RemoteConfig
+
+	// Pipeline is the name of a pipeline configuration, it is empty for the
+	// main configuration.
+	Pipeline string
+
+	// Pipelines are the named pipelines, each with its own plugins and
+	// agent settings.
+	Pipelines []*Config
+
+	// secretStores by id, for resolving secret references.
+	secretStores map[string]SecretStore
+
+	// hash of the contents of the loaded configuration files.
+	hash hash.Hash64
+
+	// loading is the path of the file being loaded.
+	loading string
 }
 
 func NewConfig() *Config {
@@ -79,10 +113,20 @@ func NewConfig() *Config {
 		Processors:    make([]*models.RunningProcessor, 0),
 		InputFilters:  make([]string, 0),
 		OutputFilters: make([]string, 0),
+
+		hash: fnv.New64a(),
 	}
 	return c
 }
 
+// Hash returns a hash of the contents of the loaded configuration files.
+func (c *Config) Hash() int64 {
+	if c.hash == nil {
+		return 0
+	}
+	return int64(c.hash.Sum64())
+}
+
 type AgentConfig struct {
 	// Interval at which to gather information
 	Interval internal.Duration
@@ -141,10 +185,17 @@ type AgentConfig struct {
 	// Logfile specifies the file to send logs to
 	Logfile string
 
+	// LogFormat is the format of log messages, either "text" or "json"
+	LogFormat string `toml:"log_format"`
+
 	// Quiet is the option for running in quiet mode
 	Quiet        bool
 	Hostname     string
 	OmitHostname bool
+
+	// APIAddress is the address the agent management HTTP API listens on,
+	// the API is disabled when empty.
+	APIAddress string `toml:"api_address"`
 }
 
 // Inputs returns a list of strings of the configured inputs.
@@ -270,12 +321,18 @@ var header = `# Telegraf Configuration
   quiet = false
   ## Specify the log file name. The empty string means to log to stderr.
   logfile = ""
+  ## Log message format, either "text" or "json".
+  # log_format = "text"
 
   ## Override default hostname, if empty use os.Hostname()
   hostname = ""
   ## If set to true, do no set the "host" tag in the telegraf agent.
   omit_hostname = false
 
+  ## Address of the agent management HTTP API, the API is disabled when empty.
+  ##   ie, api_address = "localhost:8089"
+  # api_address = ""
+
 
 ###############################################################################
 #                            OUTPUT PLUGINS                                   #
@@ -546,8 +603,7 @@ func (c *Config) LoadDirectory(path string) error {
 
 			return nil
 		}
-		name := info.Name()
-		if len(name) < 6 || name[len(name)-5:] != ".conf" {
+		if !sliceContains(strings.ToLower(filepath.Ext(info.Name())), configExtensions) {
 			return nil
 		}
 		err := c.LoadConfig(thispath)
@@ -591,16 +647,57 @@ func (c *Config) LoadConfig(path string) error {
 			return err
 		}
 	}
-	data, err := loadConfig(path)
+	c.loading = path
+
+	data, remote, err := loadConfig(path)
 	if err != nil {
 		return fmt.Errorf("Error loading %s, %s", path, err)
 	}
+	if remote != nil {
+		c.Remotes = append(c.Remotes, remote)
+	}
+	if c.hash != nil {
+		c.hash.Write(data)
+	}
 
-	tbl, err := parseConfig(data)
+	tbl, err := parseConfigFormat(configFormat(path), data)
 	if err != nil {
 		return fmt.Errorf("Error parsing %s, %s", path, err)
 	}
 
+	// Secret stores are loaded first, so that secrets can be referenced from
+	// any other table:
+	if val, ok := tbl.Fields["secretstores"]; ok {
+		subTable, ok := val.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("%s: invalid configuration", path)
+		}
+		for storeName, storeVal := range subTable.Fields {
+			storeSubTables, ok := storeVal.([]*ast.Table)
+			if !ok {
+				return fmt.Errorf("Unsupported config format: %s, file %s",
+					storeName, path)
+			}
+			for _, t := range storeSubTables {
+				if err = c.addSecretStore(storeName, t); err != nil {
+					return fmt.Errorf("Error parsing %s, %s", path, err)
+				}
+			}
+		}
+		delete(tbl.Fields, "secretstores")
+	}
+	if err = c.resolveSecrets(tbl); err != nil {
+		return fmt.Errorf("Error parsing %s, %s", path, err)
+	}
+
+	return c.loadTable(path, tbl)
+}
+
+// loadTable loads the tags, agent settings, plugins and pipelines of a
+// configuration table.
+func (c *Config) loadTable(path string, tbl *ast.Table) error {
+	var err error
+
 	// Parse tags tables first:
 	for _, tableName := range []string{"tags", "global_tags"} {
 		if val, ok := tbl.Fields[tableName]; ok {
@@ -621,6 +718,7 @@ func (c *Config) LoadConfig(path string) error {
 		if !ok {
 			return fmt.Errorf("%s: invalid configuration", path)
 		}
+		c.checkOptions("agent", subTable, c.Agent)
 		if err = toml.UnmarshalTable(subTable, c.Agent); err != nil {
 			log.Printf("E! Could not parse [agent] config\n")
 			return fmt.Errorf("Error parsing %s, %s", path, err)
@@ -649,18 +747,39 @@ func (c *Config) LoadConfig(path string) error {
 
 		switch name {
 		case "agent", "global_tags", "tags":
+		case "pipelines":
+			if c.Pipeline != "" {
+				return fmt.Errorf("%s: pipelines cannot be nested", path)
+			}
+			names := make([]string, 0, len(subTable.Fields))
+			for pipelineName := range subTable.Fields {
+				names = append(names, pipelineName)
+			}
+			sort.Strings(names)
+			for _, pipelineName := range names {
+				pipelineTable, ok := subTable.Fields[pipelineName].(*ast.Table)
+				if !ok {
+					return fmt.Errorf("Unsupported config format: pipeline %s, file %s",
+						pipelineName, path)
+				}
+				if err = c.loadPipeline(path, pipelineName, pipelineTable); err != nil {
+					return err
+				}
+			}
 		case "outputs":
 			for pluginName, pluginVal := range subTable.Fields {
 				switch pluginSubTable := pluginVal.(type) {
 				// legacy [outputs.influxdb] support
 				case *ast.Table:
-					if err = c.addOutput(pluginName, pluginSubTable); err != nil {
-						return fmt.Errorf("Error parsing %s, %s", path, err)
+					err = c.pluginError(path, pluginSubTable, c.addOutput(pluginName, pluginSubTable))
+					if err != nil {
+						return err
 					}
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addOutput(pluginName, t); err != nil {
-							return fmt.Errorf("Error parsing %s, %s", path, err)
+						err = c.pluginError(path, t, c.addOutput(pluginName, t))
+						if err != nil {
+							return err
 						}
 					}
 				default:
@@ -673,13 +792,15 @@ func (c *Config) LoadConfig(path string) error {
 				switch pluginSubTable := pluginVal.(type) {
 				// legacy [inputs.cpu] support
 				case *ast.Table:
-					if err = c.addInput(pluginName, pluginSubTable); err != nil {
-						return fmt.Errorf("Error parsing %s, %s", path, err)
+					err = c.pluginError(path, pluginSubTable, c.addInput(pluginName, pluginSubTable))
+					if err != nil {
+						return err
 					}
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addInput(pluginName, t); err != nil {
-							return fmt.Errorf("Error parsing %s, %s", path, err)
+						err = c.pluginError(path, t, c.addInput(pluginName, t))
+						if err != nil {
+							return err
 						}
 					}
 				default:
@@ -692,8 +813,9 @@ func (c *Config) LoadConfig(path string) error {
 				switch pluginSubTable := pluginVal.(type) {
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addProcessor(pluginName, t); err != nil {
-							return fmt.Errorf("Error parsing %s, %s", path, err)
+						err = c.pluginError(path, t, c.addProcessor(pluginName, t))
+						if err != nil {
+							return err
 						}
 					}
 				default:
@@ -706,8 +828,9 @@ func (c *Config) LoadConfig(path string) error {
 				switch pluginSubTable := pluginVal.(type) {
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addAggregator(pluginName, t); err != nil {
-							return fmt.Errorf("Error parsing %s, %s", path, err)
+						err = c.pluginError(path, t, c.addAggregator(pluginName, t))
+						if err != nil {
+							return err
 						}
 					}
 				default:
@@ -718,8 +841,9 @@ func (c *Config) LoadConfig(path string) error {
 		// Assume it's an input input for legacy config file support if no other
 		// identifiers are present
 		default:
-			if err = c.addInput(name, subTable); err != nil {
-				return fmt.Errorf("Error parsing %s, %s", path, err)
+			err = c.pluginError(path, subTable, c.addInput(name, subTable))
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -731,6 +855,47 @@ func (c *Config) LoadConfig(path string) error {
 	return nil
 }
 
+// loadPipeline loads the table of a named pipeline.  A pipeline starts with
+// the agent settings and global tags of the main configuration, as loaded so
+// far, which its own agent and global_tags tables override.
+func (c *Config) loadPipeline(path, name string, tbl *ast.Table) error {
+	var p *Config
+	for _, pipeline := range c.Pipelines {
+		if pipeline.Pipeline == name {
+			p = pipeline
+		}
+	}
+	if p == nil {
+		p = NewConfig()
+		p.Pipeline = name
+		p.InputFilters = c.InputFilters
+		p.OutputFilters = c.OutputFilters
+		p.Check = c.Check
+		p.secretStores = c.secretStores
+		p.hash = nil
+
+		agent := *c.Agent
+		p.Agent = &agent
+		for k, v := range c.Tags {
+			// The host tag is set again unless the pipeline omits it.
+			if k == "host" && !c.Agent.OmitHostname {
+				continue
+			}
+			p.Tags[k] = v
+		}
+		c.Pipelines = append(c.Pipelines, p)
+	}
+
+	p.loading = c.loading
+	err := p.loadTable(path, tbl)
+	c.Problems = append(c.Problems, p.Problems...)
+	p.Problems = nil
+	if err != nil {
+		return fmt.Errorf("pipeline %s: %s", name, err)
+	}
+	return nil
+}
+
 // trimBOM trims the Byte-Order-Marks from the beginning of the file.
 // this is for Windows compatibility only.
 // see https://github.com/influxdata/telegraf/issues/1378
@@ -743,42 +908,29 @@ func escapeEnv(value string) string {
 	return envVarEscaper.Replace(value)
 }
 
-func loadConfig(config string) ([]byte, error) {
+// loadConfig reads a configuration file, or requests it if config is an
+// http(s) URL in which case the RemoteConfig is also returned.
+func loadConfig(config string) ([]byte, *RemoteConfig, error) {
 	u, err := url.Parse(config)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	switch u.Scheme {
 	case "https", "http":
-		return fetchConfig(u)
+		remote := &RemoteConfig{URL: u.String()}
+		data, err := remote.fetch(false)
+		if err != nil {
+			return nil, nil, err
+		}
+		return data, remote, nil
 	default:
 		// If it isn't a https scheme, try it as a file.
 	}
-	return ioutil.ReadFile(config)
-
-}
-
-func fetchConfig(u *url.URL) ([]byte, error) {
-	v := os.Getenv("INFLUX_TOKEN")
-
-	req, err := http.NewRequest("GET", u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Authorization", "Token "+v)
-	req.Header.Add("Accept", "application/toml")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	return ioutil.ReadAll(resp.Body)
+	data, err := ioutil.ReadFile(config)
+	return data, nil, err
 }
 
-// parseConfig loads a TOML configuration from a provided path and
-// returns the AST produced from the TOML parser. When loading the file, it
-// will find environment variables and replace them.
 func parseConfig(contents []byte) (*ast.Table, error) {
 	contents = trimBOM(contents)
 
@@ -794,6 +946,69 @@ func parseConfig(contents []byte) (*ast.Table, error) {
 	return toml.Parse(contents)
 }
 
+// checksumKey keys the table checksums, so that the checksums cannot be used
+// to guess the secrets they cover.  Checksums are only compared within the
+// process.
+var checksumKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// tableChecksum returns a checksum of the plugin table, it is used to tell
+// which plugins changed when the configuration is reloaded.  It must be
+// computed before any fields are removed from the table.
+func tableChecksum(tbl *ast.Table) string {
+	h := hmac.New(sha256.New, checksumKey)
+	writeTable(h, tbl)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeValue writes the value of a field, strings are written using their
+// value as it may differ from the source once secrets are resolved.
+func writeValue(w io.Writer, value ast.Value) {
+	switch value := value.(type) {
+	case *ast.String:
+		fmt.Fprintf(w, "%q", value.Value)
+	case *ast.Array:
+		fmt.Fprint(w, "[")
+		for _, v := range value.Value {
+			writeValue(w, v)
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "]")
+	default:
+		fmt.Fprint(w, value.Source())
+	}
+}
+
+func writeTable(w io.Writer, tbl *ast.Table) {
+	keys := make([]string, 0, len(tbl.Fields))
+	for key := range tbl.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch node := tbl.Fields[key].(type) {
+		case *ast.KeyValue:
+			fmt.Fprintf(w, "%q=", key)
+			writeValue(w, node.Value)
+			fmt.Fprintln(w)
+		case *ast.Table:
+			fmt.Fprintf(w, "[%q]\n", key)
+			writeTable(w, node)
+		case []*ast.Table:
+			for _, t := range node {
+				fmt.Fprintf(w, "[[%q]]\n", key)
+				writeTable(w, t)
+			}
+		}
+	}
+}
+
 func (c *Config) addAggregator(name string, table *ast.Table) error {
 	creator, ok := aggregators.Aggregators[name]
 	if !ok {
@@ -801,16 +1016,26 @@ func (c *Config) addAggregator(name string, table *ast.Table) error {
 	}
 	aggregator := creator()
 
+	checksum := tableChecksum(table)
+
 	conf, err := buildAggregator(name, table)
 	if err != nil {
 		return err
 	}
+	conf.Pipeline = c.Pipeline
+
+	if err := setLogger(aggregator, "aggregators."+name, table); err != nil {
+		return err
+	}
 
+	c.checkOptions("aggregators."+name, table, aggregator)
 	if err := toml.UnmarshalTable(table, aggregator); err != nil {
 		return err
 	}
 
-	c.Aggregators = append(c.Aggregators, models.NewRunningAggregator(aggregator, conf))
+	ra := models.NewRunningAggregator(aggregator, conf)
+	ra.Checksum = checksum
+	c.Aggregators = append(c.Aggregators, ra)
 	return nil
 }
 
@@ -821,11 +1046,48 @@ func (c *Config) addProcessor(name string, table *ast.Table) error {
 	}
 	processor := creator()
 
+	checksum := tableChecksum(table)
+
+	// Processors exchanging metrics with another program read them with a
+	// parser and write them with a serializer, both of the data_format.
+	dataFormat, hasDataFormat := table.Fields["data_format"]
+	switch t := processor.(type) {
+	case parsers.ParserInput:
+		parser, err := buildParser(name, table)
+		if err != nil {
+			return err
+		}
+		t.SetParser(parser)
+	}
+	switch t := processor.(type) {
+	case serializers.SerializerOutput:
+		if hasDataFormat {
+			table.Fields["data_format"] = dataFormat
+		}
+		serializer, err := buildSerializer(name, table)
+		if err != nil {
+			return err
+		}
+		t.SetSerializer(serializer)
+	}
+
 	processorConfig, err := buildProcessor(name, table)
 	if err != nil {
 		return err
 	}
 
+	// The default stage applies a processor to two streams of metrics, a
+	// service could not tell them apart when returning its output.
+	if _, ok := processor.(telegraf.ServiceProcessor); ok && processorConfig.Stage == models.StageDefault {
+		return fmt.Errorf("no stage set for processor %s, it must be one of %q, %q or %q",
+			name, models.StageInputs, models.StageAggregators, models.StageOutputs)
+	}
+
+	if err := setLogger(processor, "processors."+name, table); err != nil {
+		return err
+	}
+
+	c.checkOptions("processors."+name, table, processor)
 	if err := toml.UnmarshalTable(table, processor); err != nil {
 		return err
 	}
@@ -834,6 +1096,7 @@ func (c *Config) addProcessor(name string, table *ast.Table) error {
 		Name:      name,
 		Processor: processor,
 		Config:    processorConfig,
+		Checksum:  checksum,
 	}
 
 	c.Processors = append(c.Processors, rf)
@@ -850,6 +1113,8 @@ func (c *Config) addOutput(name string, table *ast.Table) error {
 	}
 	output := creator()
 
+	checksum := tableChecksum(table)
+
 	// If the output has a SetSerializer function, then this means it can write
 	// arbitrary types of output, so build the serializer and set it.
 	switch t := output.(type) {
@@ -865,13 +1130,20 @@ func (c *Config) addOutput(name string, table *ast.Table) error {
 	if err != nil {
 		return err
 	}
+	outputConfig.Pipeline = c.Pipeline
+
+	if err := setLogger(output, "outputs."+name, table); err != nil {
+		return err
+	}
 
+	c.checkOptions("outputs."+name, table, output)
 	if err := toml.UnmarshalTable(table, output); err != nil {
 		return err
 	}
 
 	ro := models.NewRunningOutput(name, output, outputConfig,
 		c.Agent.MetricBatchSize, c.Agent.MetricBufferLimit)
+	ro.Checksum = checksum
 	c.Outputs = append(c.Outputs, ro)
 	return nil
 }
@@ -891,6 +1163,8 @@ func (c *Config) addInput(name string, table *ast.Table) error {
 	}
 	input := creator()
 
+	checksum := tableChecksum(table)
+
 	// If the input has a SetParser function, then this means it can accept
 	// arbitrary types of input, so build the parser and set it.
 	switch t := input.(type) {
@@ -917,13 +1191,20 @@ func (c *Config) addInput(name string, table *ast.Table) error {
 	if err != nil {
 		return err
 	}
+	pluginConfig.Pipeline = c.Pipeline
+
+	if err := setLogger(input, "inputs."+name, table); err != nil {
+		return err
+	}
 
+	c.checkOptions("inputs."+name, table, input)
 	if err := toml.UnmarshalTable(table, input); err != nil {
 		return err
 	}
 
 	rp := models.NewRunningInput(input, pluginConfig)
 	rp.SetDefaultTags(c.Tags)
+	rp.Checksum = checksum
 	c.Inputs = append(c.Inputs, rp)
 	return nil
 }
@@ -1042,7 +1323,23 @@ func buildProcessor(name string, tbl *ast.Table) (*models.ProcessorConfig, error
 		}
 	}
 
+	if node, ok := tbl.Fields["stage"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				switch str.Value {
+				case models.StageInputs, models.StageAggregators, models.StageOutputs:
+					conf.Stage = str.Value
+				default:
+					return nil, fmt.Errorf("invalid stage %q, must be one of %q, %q or %q",
+						str.Value, models.StageInputs, models.StageAggregators,
+						models.StageOutputs)
+				}
+			}
+		}
+	}
+
 	delete(tbl.Fields, "order")
+	delete(tbl.Fields, "stage")
 	var err error
 	conf.Filter, err = buildFilter(tbl)
 	if err != nil {
@@ -1206,6 +1503,35 @@ func buildInput(name string, tbl *ast.Table) (*models.InputConfig, error) {
 		}
 	}
 
+	if node, ok := tbl.Fields["schedule"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				schedule, err := cron.Parse(str.Value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid schedule: %s", err)
+				}
+
+				cp.Schedule = schedule
+			}
+		}
+	}
+	if cp.Schedule != nil && cp.Interval != 0 {
+		return nil, errors.New("interval and schedule cannot both be set")
+	}
+
+	if node, ok := tbl.Fields["gather_timeout"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				dur, err := time.ParseDuration(str.Value)
+				if err != nil {
+					return nil, err
+				}
+
+				cp.GatherTimeout = dur
+			}
+		}
+	}
+
 	if node, ok := tbl.Fields["name_prefix"]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
 			if str, ok := kv.Value.(*ast.String); ok {
@@ -1243,6 +1569,8 @@ func buildInput(name string, tbl *ast.Table) (*models.InputConfig, error) {
 	delete(tbl.Fields, "name_suffix")
 	delete(tbl.Fields, "name_override")
 	delete(tbl.Fields, "interval")
+	delete(tbl.Fields, "schedule")
+	delete(tbl.Fields, "gather_timeout")
 	delete(tbl.Fields, "tags")
 	var err error
 	cp.Filter, err = buildFilter(tbl)
@@ -1786,6 +2114,40 @@ func buildSerializer(name string, tbl *ast.Table) (serializers.Serializer, error
 	return serializers.NewSerializer(c)
 }
 
+// setLogger creates the logger of a plugin from the alias and log_level
+// settings, and passes it to the plugin if it supports logging.
+func setLogger(plugin interface{}, source string, tbl *ast.Table) error {
+	var alias, level string
+	if node, ok := tbl.Fields["alias"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				alias = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["log_level"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				level = str.Value
+			}
+		}
+	}
+
+	delete(tbl.Fields, "alias")
+	delete(tbl.Fields, "log_level")
+
+	l, err := logger.NewLogger(source, alias, level)
+	if err != nil {
+		return fmt.Errorf("%s: %s", source, err)
+	}
+
+	if t, ok := plugin.(telegraf.LoggerPlugin); ok {
+		t.SetLogger(l)
+	}
+	return nil
+}
+
 // buildOutput parses output specific items from the ast.Table,
 // builds the filter and returns an
 // models.OutputConfig to be inserted into models.RunningInput
@@ -1846,9 +2208,93 @@ func buildOutput(name string, tbl *ast.Table) (*models.OutputConfig, error) {
 		}
 	}
 
+	if node, ok := tbl.Fields["buffer_directory"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				oc.BufferDirectory = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["metric_buffer_limit_bytes"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			var size internal.Size
+			if err := size.UnmarshalTOML([]byte(kv.Value.Source())); err != nil {
+				return nil, fmt.Errorf("metric_buffer_limit_bytes: %s", err)
+			}
+			oc.MetricBufferLimitBytes = size.Size
+		}
+	}
+
+	if node, ok := tbl.Fields["buffer_drop_policy"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				switch str.Value {
+				case models.DropOldest, models.DropNewest, models.DropBlock:
+					oc.BufferDropPolicy = str.Value
+				default:
+					return nil, fmt.Errorf("invalid buffer_drop_policy %q", str.Value)
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["retry_interval"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				dur, err := time.ParseDuration(str.Value)
+				if err != nil {
+					return nil, err
+				}
+
+				oc.RetryInterval = dur
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["retry_max_interval"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				dur, err := time.ParseDuration(str.Value)
+				if err != nil {
+					return nil, err
+				}
+
+				oc.RetryMaxInterval = dur
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["retry_failure_threshold"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if integer, ok := kv.Value.(*ast.Integer); ok {
+				v, err := integer.Int()
+				if err != nil {
+					return nil, err
+				}
+				oc.RetryFailureThreshold = int(v)
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["failover_group"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				oc.FailoverGroup = str.Value
+			}
+		}
+	}
+
 	delete(tbl.Fields, "flush_interval")
 	delete(tbl.Fields, "metric_buffer_limit")
 	delete(tbl.Fields, "metric_batch_size")
+	delete(tbl.Fields, "buffer_directory")
+	delete(tbl.Fields, "metric_buffer_limit_bytes")
+	delete(tbl.Fields, "buffer_drop_policy")
+	delete(tbl.Fields, "retry_interval")
+	delete(tbl.Fields, "retry_max_interval")
+	delete(tbl.Fields, "retry_failure_threshold")
+	delete(tbl.Fields, "failover_group")
 
 	return oc, nil
 }
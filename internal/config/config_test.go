@@ -1,16 +1,26 @@
 package config
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/logger"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/inputs/exec"
 	"github.com/influxdata/telegraf/plugins/inputs/memcached"
 	"github.com/influxdata/telegraf/plugins/inputs/procstat"
 	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/plugins/parsers/json"
+	"github.com/influxdata/telegraf/plugins/processors"
+	_ "github.com/influxdata/telegraf/plugins/processors/converter"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	jsonserializer "github.com/influxdata/telegraf/plugins/serializers/json"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/influxdata/wlog"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -177,3 +187,218 @@ func TestConfig_LoadDirectory(t *testing.T) {
 	assert.Equal(t, pConfig, c.Inputs[3].Config,
 		"Merged Testdata did not produce correct procstat metadata.")
 }
+
+func TestConfig_TableChecksum(t *testing.T) {
+	parse := func(s string) string {
+		tbl, err := parseConfig([]byte(s))
+		assert.NoError(t, err)
+		return tableChecksum(tbl)
+	}
+
+	a := parse("servers = [\"localhost\"]\ninterval = \"5s\"\n[tags]\n  dc = \"us-east\"\n")
+	b := parse("interval = \"5s\"\nservers = [\"localhost\"]\n[tags]\n  dc = \"us-east\"\n")
+	c := parse("interval = \"5s\"\nservers = [\"localhost\"]\n[tags]\n  dc = \"us-west\"\n")
+
+	assert.Equal(t, a, b, "Field order should not change the checksum.")
+	assert.NotEqual(t, a, c, "Changed tags should change the checksum.")
+}
+
+type loggingInput struct {
+	Log telegraf.Logger
+}
+
+func (i *loggingInput) SampleConfig() string                  { return "" }
+func (i *loggingInput) Description() string                   { return "" }
+func (i *loggingInput) Gather(acc telegraf.Accumulator) error { return nil }
+func (i *loggingInput) SetLogger(l telegraf.Logger)           { i.Log = l }
+
+func TestConfig_SetLogger(t *testing.T) {
+	inputs.Add("logging_test", func() telegraf.Input { return &loggingInput{} })
+	defer delete(inputs.Inputs, "logging_test")
+
+	tbl, err := parseConfig([]byte("alias = \"primary\"\nlog_level = \"debug\"\n"))
+	assert.NoError(t, err)
+
+	c := NewConfig()
+	assert.NoError(t, c.addInput("logging_test", tbl))
+	assert.Len(t, c.Inputs, 1)
+	assert.NotNil(t, c.Inputs[0].Input.(*loggingInput).Log)
+
+	tbl, err = parseConfig([]byte("log_level = \"verbose\"\n"))
+	assert.NoError(t, err)
+	assert.Error(t, c.addInput("logging_test", tbl))
+}
+
+func TestConfig_LogLevelOverride(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	assert.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+
+	wlog.SetLevel(wlog.INFO)
+	logger.SetupLogging(false, false, tmpfile.Name(), "")
+	defer logger.SetupLogging(false, false, "", "")
+
+	c := NewConfig()
+	for _, conf := range []string{
+		"alias = \"debug\"\nlog_level = \"debug\"\n[fields]\n  integer = [\"value\"]\n",
+		"alias = \"default\"\n[fields]\n  integer = [\"value\"]\n",
+	} {
+		tbl, err := parseConfig([]byte(conf))
+		assert.NoError(t, err)
+		assert.NoError(t, c.addProcessor("converter", tbl))
+	}
+	assert.Len(t, c.Processors, 2)
+
+	// The value cannot be converted, which the converter logs in debug mode.
+	for _, p := range c.Processors {
+		p.Processor.Apply(testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": "x"},
+			time.Unix(0, 0)))
+	}
+
+	b, err := ioutil.ReadFile(tmpfile.Name())
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "D! [processors.converter::debug] error converting to integer")
+	assert.NotContains(t, string(b), "processors.converter::default")
+}
+
+type formatProcessor struct {
+	parser     parsers.Parser
+	serializer serializers.Serializer
+}
+
+func (p *formatProcessor) SampleConfig() string                            { return "" }
+func (p *formatProcessor) Description() string                             { return "" }
+func (p *formatProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric   { return in }
+func (p *formatProcessor) SetParser(parser parsers.Parser)                 { p.parser = parser }
+func (p *formatProcessor) SetSerializer(serializer serializers.Serializer) { p.serializer = serializer }
+
+func TestConfig_ProcessorDataFormat(t *testing.T) {
+	processors.Add("format_test", func() telegraf.Processor { return &formatProcessor{} })
+	defer delete(processors.Processors, "format_test")
+
+	tbl, err := parseConfig([]byte("data_format = \"json\"\n"))
+	assert.NoError(t, err)
+
+	c := NewConfig()
+	assert.NoError(t, c.addProcessor("format_test", tbl))
+	assert.Len(t, c.Processors, 1)
+	p := c.Processors[0].Processor.(*formatProcessor)
+	assert.IsType(t, &json.JSONParser{}, p.parser)
+	serializer, err := jsonserializer.NewSerializer(0)
+	assert.NoError(t, err)
+	assert.IsType(t, serializer, p.serializer)
+	assert.NotContains(t, tbl.Fields, "data_format")
+}
+
+type serviceProcessor struct{}
+
+func (p *serviceProcessor) SampleConfig() string                          { return "" }
+func (p *serviceProcessor) Description() string                           { return "" }
+func (p *serviceProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric { return in }
+func (p *serviceProcessor) Flush() []telegraf.Metric                      { return nil }
+func (p *serviceProcessor) Stop() []telegraf.Metric                       { return nil }
+
+func TestConfig_ServiceProcessorStage(t *testing.T) {
+	processors.Add("service_test", func() telegraf.Processor { return &serviceProcessor{} })
+	defer delete(processors.Processors, "service_test")
+
+	tbl, err := parseConfig([]byte("order = 1\n"))
+	assert.NoError(t, err)
+
+	c := NewConfig()
+	assert.Error(t, c.addProcessor("service_test", tbl))
+	assert.Len(t, c.Processors, 0)
+
+	tbl, err = parseConfig([]byte("stage = \"outputs\"\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, c.addProcessor("service_test", tbl))
+	assert.Len(t, c.Processors, 1)
+	assert.Equal(t, models.StageOutputs, c.Processors[0].Config.Stage)
+}
+
+func TestConfig_BuildInputSchedule(t *testing.T) {
+	tbl, err := parseConfig([]byte("schedule = \"CRON_TZ=UTC 0 */6 * * *\"\n"))
+	assert.NoError(t, err)
+	cp, err := buildInput("test", tbl)
+	assert.NoError(t, err)
+	assert.NotNil(t, cp.Schedule)
+	assert.Equal(t, time.UTC, cp.Schedule.Location())
+	assert.NotContains(t, tbl.Fields, "schedule")
+
+	tbl, err = parseConfig([]byte("schedule = \"0 */6 * *\"\n"))
+	assert.NoError(t, err)
+	_, err = buildInput("test", tbl)
+	assert.Error(t, err)
+
+	tbl, err = parseConfig([]byte("interval = \"10s\"\nschedule = \"@hourly\"\n"))
+	assert.NoError(t, err)
+	_, err = buildInput("test", tbl)
+	assert.Error(t, err)
+}
+
+func TestConfig_BuildProcessorStage(t *testing.T) {
+	tbl, err := parseConfig([]byte("stage = \"aggregators\"\n"))
+	assert.NoError(t, err)
+	conf, err := buildProcessor("test", tbl)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StageAggregators, conf.Stage)
+	assert.NotContains(t, tbl.Fields, "stage")
+
+	tbl, err = parseConfig([]byte("stage = \"later\"\n"))
+	assert.NoError(t, err)
+	_, err = buildProcessor("test", tbl)
+	assert.Error(t, err)
+}
+
+func TestConfig_LoadPipelines(t *testing.T) {
+	tbl, err := parseConfig([]byte(`
+[agent]
+  interval = "10s"
+  omit_hostname = true
+
+[global_tags]
+  dc = "us-east"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[pipelines.team_a.agent]
+  interval = "1m"
+
+[pipelines.team_a.global_tags]
+  team = "a"
+
+[[pipelines.team_a.inputs.memcached]]
+  servers = ["192.168.1.1"]
+`))
+	assert.NoError(t, err)
+
+	c := NewConfig()
+	assert.NoError(t, c.loadTable("telegraf.conf", tbl))
+	assert.Len(t, c.Inputs, 1)
+	assert.Equal(t, 10*time.Second, c.Agent.Interval.Duration)
+	assert.Equal(t, map[string]string{"dc": "us-east"}, c.Tags)
+
+	assert.Len(t, c.Pipelines, 1)
+	p := c.Pipelines[0]
+	assert.Equal(t, "team_a", p.Pipeline)
+	assert.Equal(t, time.Minute, p.Agent.Interval.Duration)
+	assert.True(t, p.Agent.OmitHostname)
+	assert.Equal(t, map[string]string{"dc": "us-east", "team": "a"}, p.Tags)
+	assert.Len(t, p.Inputs, 1)
+	assert.Equal(t, []string{"192.168.1.1"},
+		p.Inputs[0].Input.(*memcached.Memcached).Servers)
+	assert.Equal(t, "", c.Inputs[0].Config.Pipeline)
+	assert.Equal(t, "team_a", p.Inputs[0].Config.Pipeline)
+	assert.Equal(t, map[string]string{"input": "memcached", "pipeline": "team_a"},
+		p.Inputs[0].MetricsGathered.Tags())
+
+	tbl, err = parseConfig([]byte(`
+[pipelines.outer.pipelines.inner.agent]
+  interval = "1m"
+`))
+	assert.NoError(t, err)
+	assert.Error(t, NewConfig().loadTable("telegraf.conf", tbl))
+}
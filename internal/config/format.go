@@ -0,0 +1,247 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/toml/ast"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// configExtensions are the file extensions of the supported configuration
+// formats.
+var configExtensions = []string{".conf", ".yaml", ".yml", ".json"}
+
+// configFormat returns the format of a configuration file or URL from its
+// extension, files without a known extension are TOML.
+func configFormat(config string) string {
+	p := config
+	if u, err := url.Parse(config); err == nil && u.Path != "" {
+		p = u.Path
+	}
+	switch strings.ToLower(path.Ext(p)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	default:
+		return "toml"
+	}
+}
+
+// parseConfigFormat parses the contents of a configuration file in the given
+// format.  YAML and JSON documents are converted into the table a TOML file
+// with the same structure would have, so that plugins are configured the same
+// way regardless of the format.
+func parseConfigFormat(format string, contents []byte) (*ast.Table, error) {
+	var doc interface{}
+	switch format {
+	case "yaml":
+		if err := yaml.Unmarshal(expandScalarEnv(trimBOM(contents)), &doc); err != nil {
+			return nil, err
+		}
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(expandScalarEnv(trimBOM(contents))))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+	default:
+		return parseConfig(contents)
+	}
+
+	if doc == nil {
+		return &ast.Table{Type: ast.TableTypeNormal, Fields: map[string]interface{}{}}, nil
+	}
+	return toTable("", doc)
+}
+
+// toTable converts a decoded mapping into a table.
+func toTable(name string, doc interface{}) (*ast.Table, error) {
+	fields, err := toFields(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", tableName(name), err)
+	}
+
+	tbl := &ast.Table{
+		Name:   name,
+		Type:   ast.TableTypeNormal,
+		Fields: make(map[string]interface{}, len(fields)),
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := fields[key]
+		if value == nil {
+			// TOML has no null value, the option is left unset.
+			continue
+		}
+
+		if isTable(value) {
+			sub, err := toTable(key, value)
+			if err != nil {
+				return nil, err
+			}
+			tbl.Fields[key] = sub
+			continue
+		}
+
+		if list, ok := value.([]interface{}); ok && isTableArray(list) {
+			tables := make([]*ast.Table, 0, len(list))
+			for _, elem := range list {
+				sub, err := toTable(key, elem)
+				if err != nil {
+					return nil, err
+				}
+				sub.Type = ast.TableTypeArray
+				tables = append(tables, sub)
+			}
+			tbl.Fields[key] = tables
+			continue
+		}
+
+		v, err := toValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %s", tableName(name), key, err)
+		}
+		tbl.Fields[key] = &ast.KeyValue{Key: key, Value: v}
+	}
+	return tbl, nil
+}
+
+// toFields returns the entries of a mapping, YAML mappings may have
+// non-string keys.
+func toFields(doc interface{}) (map[string]interface{}, error) {
+	switch doc := doc.(type) {
+	case map[string]interface{}:
+		return doc, nil
+	case map[interface{}]interface{}:
+		fields := make(map[string]interface{}, len(doc))
+		for k, v := range doc {
+			fields[fmt.Sprint(k)] = v
+		}
+		return fields, nil
+	default:
+		return nil, fmt.Errorf("expected a mapping, found %T", doc)
+	}
+}
+
+// isTableArray returns true if the list is a non empty list of mappings.
+func isTableArray(list []interface{}) bool {
+	if len(list) == 0 {
+		return false
+	}
+	for _, elem := range list {
+		if !isTable(elem) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTable(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, map[interface{}]interface{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// toValue converts a decoded scalar or list into a TOML value.  The value
+// source is set as TOML would have it, since it is used by the types
+// implementing toml.Unmarshaler.  Environment variables are replaced in
+// string values.
+func toValue(value interface{}) (ast.Value, error) {
+	switch value := value.(type) {
+	case string:
+		s := expandEnv(value)
+		return &ast.String{Value: s, Data: []rune(strconv.Quote(s))}, nil
+	case bool:
+		s := strconv.FormatBool(value)
+		return &ast.Boolean{Value: s, Data: []rune(s)}, nil
+	case int, int64, uint64:
+		s := fmt.Sprint(value)
+		return &ast.Integer{Value: s, Data: []rune(s)}, nil
+	case float64:
+		s := strconv.FormatFloat(value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEn") {
+			s += ".0"
+		}
+		return &ast.Float{Value: s, Data: []rune(s)}, nil
+	case json.Number:
+		s := value.String()
+		if _, err := value.Int64(); err == nil {
+			return &ast.Integer{Value: s, Data: []rune(s)}, nil
+		}
+		return &ast.Float{Value: s, Data: []rune(s)}, nil
+	case time.Time:
+		s := value.Format(time.RFC3339Nano)
+		return &ast.Datetime{Value: s, Data: []rune(s)}, nil
+	case []interface{}:
+		ary := &ast.Array{Value: make([]ast.Value, 0, len(value))}
+		sources := make([]string, 0, len(value))
+		for _, elem := range value {
+			v, err := toValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			ary.Value = append(ary.Value, v)
+			sources = append(sources, v.Source())
+		}
+		ary.Data = []rune("[" + strings.Join(sources, ", ") + "]")
+		return ary, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %T", value)
+	}
+}
+
+// scalarEnvRe matches the TOML integers, floats and booleans.
+var scalarEnvRe = regexp.MustCompile(`^([+-]?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?|true|false)$`)
+
+// expandScalarEnv replaces the environment variables set to an integer, a
+// float or a boolean before the document is parsed, so that they are typed
+// as with the textual replacement done for TOML: an unquoted $PORT is an
+// integer while "$PORT" stays a string.  These values contain no character
+// with a meaning in YAML or JSON, the others are replaced in string values
+// once parsed.
+func expandScalarEnv(contents []byte) []byte {
+	return envVarRe.ReplaceAllFunc(contents, func(envVar []byte) []byte {
+		val, ok := os.LookupEnv(string(envVar[1:]))
+		if ok && scalarEnvRe.MatchString(val) {
+			return []byte(val)
+		}
+		return envVar
+	})
+}
+
+// expandEnv replaces the environment variables, ie $HOME, which are set.
+func expandEnv(s string) string {
+	return envVarRe.ReplaceAllStringFunc(s, func(envVar string) string {
+		if val, ok := os.LookupEnv(strings.TrimPrefix(envVar, "$")); ok {
+			return val
+		}
+		return envVar
+	})
+}
+
+func tableName(name string) string {
+	if name == "" {
+		return "document"
+	}
+	return name
+}
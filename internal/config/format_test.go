@@ -0,0 +1,119 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/inputs/memcached"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_LoadFormats(t *testing.T) {
+	expected := NewConfig()
+	require.NoError(t, expected.LoadConfig("./testdata/single_plugin.toml"))
+
+	for _, path := range []string{
+		"./testdata/single_plugin.yaml",
+		"./testdata/single_plugin.json",
+	} {
+		c := NewConfig()
+		require.NoError(t, c.LoadConfig(path), path)
+		require.Len(t, c.Inputs, 1, path)
+		assert.Equal(t, expected.Inputs[0].Input, c.Inputs[0].Input, path)
+		assert.Equal(t, expected.Inputs[0].Config, c.Inputs[0].Config, path)
+		assert.Equal(t, expected.Inputs[0].Checksum, c.Inputs[0].Checksum, path)
+	}
+}
+
+func TestConfig_ParseYAMLValues(t *testing.T) {
+	os.Setenv("MY_TEST_SERVER", "192.168.1.1")
+	defer os.Unsetenv("MY_TEST_SERVER")
+
+	dir, err := ioutil.TempDir("", "format")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "telegraf.yml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+agent:
+  interval: 10s
+  metric_batch_size: 500
+  round_interval: true
+  omit_hostname: true
+global_tags:
+  dc: us-east-1
+inputs:
+  memcached:
+    servers: ["$MY_TEST_SERVER:11211"]
+    port: ~
+`), 0600))
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig(path))
+	assert.Equal(t, 10*time.Second, c.Agent.Interval.Duration)
+	assert.Equal(t, 500, c.Agent.MetricBatchSize)
+	assert.True(t, c.Agent.RoundInterval)
+	assert.Equal(t, map[string]string{"dc": "us-east-1"}, c.Tags)
+	require.Len(t, c.Inputs, 1)
+	assert.Equal(t, []string{"192.168.1.1:11211"},
+		c.Inputs[0].Input.(*memcached.Memcached).Servers)
+}
+
+func TestConfig_ParseFormatEnvTypes(t *testing.T) {
+	os.Setenv("MY_TEST_BATCH", "500")
+	os.Setenv("MY_TEST_OMIT", "true")
+	defer os.Unsetenv("MY_TEST_BATCH")
+	defer os.Unsetenv("MY_TEST_OMIT")
+
+	for _, tt := range []struct {
+		format   string
+		contents string
+	}{
+		{
+			format: "yaml",
+			contents: `
+agent:
+  metric_batch_size: $MY_TEST_BATCH
+  omit_hostname: $MY_TEST_OMIT
+global_tags:
+  batch: "$MY_TEST_BATCH"
+`,
+		},
+		{
+			format: "json",
+			contents: `{
+  "agent": {"metric_batch_size": $MY_TEST_BATCH, "omit_hostname": $MY_TEST_OMIT},
+  "global_tags": {"batch": "$MY_TEST_BATCH"}
+}`,
+		},
+	} {
+		tbl, err := parseConfigFormat(tt.format, []byte(tt.contents))
+		require.NoError(t, err, tt.format)
+
+		c := NewConfig()
+		require.NoError(t, c.loadTable(tt.format, tbl), tt.format)
+		assert.Equal(t, 500, c.Agent.MetricBatchSize, tt.format)
+		assert.True(t, c.Agent.OmitHostname, tt.format)
+		assert.Equal(t, map[string]string{"batch": "500"}, c.Tags, tt.format)
+	}
+}
+
+func TestConfig_ParseFormatErrors(t *testing.T) {
+	_, err := parseConfigFormat("yaml", []byte("- a\n- b\n"))
+	assert.Error(t, err)
+
+	_, err = parseConfigFormat("json", []byte(`{"inputs": {"memcached": [{"servers": [{"a": 1}, 2]}]}}`))
+	assert.Error(t, err)
+}
+
+func TestConfig_ConfigFormat(t *testing.T) {
+	assert.Equal(t, "toml", configFormat("/etc/telegraf/telegraf.conf"))
+	assert.Equal(t, "yaml", configFormat("/etc/telegraf/telegraf.yml"))
+	assert.Equal(t, "yaml", configFormat("https://example.org/telegraf.YAML?token=abc"))
+	assert.Equal(t, "json", configFormat("telegraf.json"))
+	assert.Equal(t, "toml", configFormat("https://example.org/api/config"))
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// remoteClient is used to request configuration files from URLs.
+var remoteClient = &http.Client{Timeout: 30 * time.Second}
+
+// RemoteConfig is a configuration file loaded from a URL.  It keeps the
+// validators of the loaded version, so that the URL can be polled for
+// changes without downloading an unchanged configuration.
+type RemoteConfig struct {
+	URL string
+
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	sum          [sha256.Size]byte
+}
+
+// Changed requests the configuration, returning true if its content changed
+// since it was last requested.  Servers supporting ETag or Last-Modified
+// headers only send the configuration when it was modified.
+func (r *RemoteConfig) Changed() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.sum
+	data, err := r.fetch(true)
+	if err != nil || data == nil {
+		return false, err
+	}
+	return r.sum != previous, nil
+}
+
+// fetch requests the configuration and records its validators.  When
+// conditional is set and the server reports the configuration was not
+// modified, nil is returned.
+func (r *RemoteConfig) fetch(conditional bool) ([]byte, error) {
+	req, err := http.NewRequest("GET", r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Token "+os.Getenv("INFLUX_TOKEN"))
+	req.Header.Add("Accept", "application/toml")
+	if conditional {
+		if r.etag != "" {
+			req.Header.Set("If-None-Match", r.etag)
+		}
+		if r.lastModified != "" {
+			req.Header.Set("If-Modified-Since", r.lastModified)
+		}
+	}
+
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		if conditional {
+			return nil, nil
+		}
+		fallthrough
+	default:
+		return nil, fmt.Errorf("%s returned %s", r.URL, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.etag = resp.Header.Get("ETag")
+	r.lastModified = resp.Header.Get("Last-Modified")
+	r.sum = sha256.Sum256(bytes.TrimSpace(data))
+	return data, nil
+}
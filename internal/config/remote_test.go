@@ -0,0 +1,114 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// remoteServer serves a configuration, supporting conditional requests
+// when etag is set.
+type remoteServer struct {
+	sync.Mutex
+	config string
+	etag   string
+	sent   int
+}
+
+func (s *remoteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	if s.etag != "" {
+		if r.Header.Get("If-None-Match") == s.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", s.etag)
+	}
+	s.sent++
+	w.Write([]byte(s.config))
+}
+
+func (s *remoteServer) set(config, etag string) {
+	s.Lock()
+	defer s.Unlock()
+	s.config, s.etag = config, etag
+}
+
+const remoteConfig = `
+[[inputs.memcached]]
+  servers = ["localhost"]
+`
+
+func TestRemoteConfig_ETag(t *testing.T) {
+	server := &remoteServer{config: remoteConfig, etag: `"1"`}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig(ts.URL))
+	require.Len(t, c.Remotes, 1)
+	remote := c.Remotes[0]
+
+	changed, err := remote.Changed()
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, 1, server.sent, "Unmodified config should not be sent.")
+
+	server.set(remoteConfig+"  unix_sockets = []\n", `"2"`)
+	changed, err = remote.Changed()
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	changed, err = remote.Changed()
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, 2, server.sent)
+}
+
+func TestRemoteConfig_Content(t *testing.T) {
+	server := &remoteServer{config: remoteConfig}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig(ts.URL))
+	remote := c.Remotes[0]
+
+	// Without validators the content is compared.
+	changed, err := remote.Changed()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	server.set(remoteConfig+"  unix_sockets = []\n", "")
+	changed, err = remote.Changed()
+	require.NoError(t, err)
+	assert.True(t, changed)
+}
+
+func TestRemoteConfig_Error(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	c := NewConfig()
+	assert.Error(t, c.LoadConfig(ts.URL))
+
+	remote := &RemoteConfig{URL: ts.URL}
+	_, err := remote.Changed()
+	assert.Error(t, err)
+}
+
+func TestConfig_Hash(t *testing.T) {
+	a := NewConfig()
+	require.NoError(t, a.LoadConfig("./testdata/single_plugin.toml"))
+	b := NewConfig()
+	require.NoError(t, b.LoadConfig("./testdata/single_plugin.toml"))
+	assert.Equal(t, a.Hash(), b.Hash())
+
+	require.NoError(t, b.LoadDirectory("./testdata/subconfig"))
+	assert.NotEqual(t, a.Hash(), b.Hash())
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func init() {
+	AddSecretStore("directory", func() SecretStore { return &directoryStore{} })
+	AddSecretStore("keyring", func() SecretStore { return &keyringStore{} })
+	AddSecretStore("command", func() SecretStore {
+		return &commandStore{Timeout: internal.Duration{Duration: 10 * time.Second}}
+	})
+}
+
+// directoryStore reads each secret from a file named after its key, as used
+// by Docker and Kubernetes secrets.
+type directoryStore struct {
+	Path string `toml:"path"`
+}
+
+func (s *directoryStore) Init() error {
+	if s.Path == "" {
+		return errors.New("path is required")
+	}
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.Path)
+	}
+	return nil
+}
+
+func (s *directoryStore) Get(key string) (string, error) {
+	if key != filepath.Base(key) || key == "." || key == ".." {
+		return "", errors.New("invalid key")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(s.Path, key))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// keyringStore reads secrets from a keyring file encrypted with a password.
+type keyringStore struct {
+	Path     string `toml:"path"`
+	Password string `toml:"password"`
+
+	secrets map[string]string
+}
+
+func (s *keyringStore) Init() error {
+	if s.Path == "" {
+		return errors.New("path is required")
+	}
+
+	var err error
+	s.secrets, err = ReadKeyring(s.Path, s.Password)
+	return err
+}
+
+func (s *keyringStore) Get(key string) (string, error) {
+	secret, ok := s.secrets[key]
+	if !ok {
+		return "", errors.New("key not found")
+	}
+	return secret, nil
+}
+
+// commandStore runs a helper command for each secret, with the key as its
+// last argument, and uses the output of the command as the secret.
+type commandStore struct {
+	Command []string          `toml:"command"`
+	Timeout internal.Duration `toml:"timeout"`
+}
+
+func (s *commandStore) Init() error {
+	if len(s.Command) == 0 {
+		return errors.New("command is required")
+	}
+	return nil
+}
+
+func (s *commandStore) Get(key string) (string, error) {
+	args := append(append([]string{}, s.Command[1:]...), key)
+	cmd := exec.Command(s.Command[0], args...)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := internal.RunTimeout(cmd, s.Timeout.Duration); err != nil {
+		return "", fmt.Errorf("command failed: %s", err)
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+// keyringFile is the format of a keyring file.  The secrets are stored as a
+// JSON object encrypted with AES-GCM, using a key derived from the password.
+type keyringFile struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+const keyringIterations = 100000
+
+func keyringCipher(password string, salt []byte) (cipher.AEAD, error) {
+	key := pbkdf2.Key([]byte(password), salt, keyringIterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ReadKeyring returns the secrets of a keyring file.
+func ReadKeyring(path, password string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid keyring %s: %s", path, err)
+	}
+
+	aead, err := keyringCipher(password, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt keyring %s, wrong password?", path)
+	}
+
+	// The error is not returned, it may contain part of a secret.
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("invalid keyring %s", path)
+	}
+	return secrets, nil
+}
+
+// WriteKeyring encrypts the secrets and writes them to a keyring file.
+func WriteKeyring(path, password string, secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	file := keyringFile{
+		Salt: make([]byte, 16),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+
+	aead, err := keyringCipher(password, file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = aead.Seal(nil, file.Nonce, plaintext, nil)
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
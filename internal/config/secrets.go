@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/influxdata/telegraf/logger"
+	"github.com/influxdata/toml"
+	"github.com/influxdata/toml/ast"
+)
+
+// secretRe is a regex to find secret references, ie @{store:key}, in string
+// values of the config file.
+var secretRe = regexp.MustCompile(`@\{([\w-]+):([^{}]+)\}`)
+
+// secretStoreIDRe is a regex for valid secret store ids.
+var secretStoreIDRe = regexp.MustCompile(`^[\w-]+$`)
+
+// SecretStore provides the secrets referenced in the configuration.
+type SecretStore interface {
+	// Init is called once the store configuration has been loaded.
+	Init() error
+
+	// Get returns the secret with the given key.
+	Get(key string) (string, error)
+}
+
+// SecretStoreCreator returns a new, unconfigured, SecretStore.
+type SecretStoreCreator func() SecretStore
+
+// SecretStores contains the available secret store types.
+var SecretStores = map[string]SecretStoreCreator{}
+
+// AddSecretStore registers a secret store type, it is used in the
+// [[secretstores.<name>]] tables.
+func AddSecretStore(name string, creator SecretStoreCreator) {
+	SecretStores[name] = creator
+}
+
+// addSecretStore creates a secret store from its table.  Each store has an id
+// which is used to reference it.
+func (c *Config) addSecretStore(name string, table *ast.Table) error {
+	creator, ok := SecretStores[name]
+	if !ok {
+		return fmt.Errorf("Undefined but requested secret store: %s", name)
+	}
+	store := creator()
+
+	var id string
+	if node, ok := table.Fields["id"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				id = str.Value
+			}
+		}
+	}
+	delete(table.Fields, "id")
+
+	if !secretStoreIDRe.MatchString(id) {
+		return fmt.Errorf("secretstores.%s: invalid id %q", name, id)
+	}
+	if _, ok := c.secretStores[id]; ok {
+		return fmt.Errorf("secretstores.%s: duplicate id %q", name, id)
+	}
+
+	if err := toml.UnmarshalTable(table, store); err != nil {
+		return err
+	}
+	if err := store.Init(); err != nil {
+		return fmt.Errorf("secretstores.%s %q: %s", name, id, err)
+	}
+
+	if c.secretStores == nil {
+		c.secretStores = make(map[string]SecretStore)
+	}
+	c.secretStores[id] = store
+	return nil
+}
+
+// resolveSecrets replaces the secret references in all string values of the
+// table.  Errors never include the value of a secret.
+func (c *Config) resolveSecrets(tbl *ast.Table) error {
+	for _, node := range tbl.Fields {
+		switch node := node.(type) {
+		case *ast.KeyValue:
+			if err := c.resolveValue(node.Value); err != nil {
+				return fmt.Errorf("line %d: %s", node.Line, err)
+			}
+		case *ast.Table:
+			if err := c.resolveSecrets(node); err != nil {
+				return err
+			}
+		case []*ast.Table:
+			for _, t := range node {
+				if err := c.resolveSecrets(t); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) resolveValue(value ast.Value) error {
+	switch value := value.(type) {
+	case *ast.String:
+		resolved, err := c.resolveString(value.Value)
+		if err != nil {
+			return err
+		}
+		value.Value = resolved
+	case *ast.Array:
+		for _, v := range value.Value {
+			if err := c.resolveValue(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) resolveString(s string) (string, error) {
+	var err error
+	resolved := secretRe.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+
+		match := secretRe.FindStringSubmatch(ref)
+		store, ok := c.secretStores[match[1]]
+		if !ok {
+			err = fmt.Errorf("secret %s: unknown secret store %q", ref, match[1])
+			return ""
+		}
+
+		secret, gerr := store.Get(match[2])
+		if gerr != nil {
+			err = fmt.Errorf("secret %s: %s", ref, gerr)
+			return ""
+		}
+		logger.RegisterSecret(secret)
+		return secret
+	})
+	return resolved, err
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/influxdata/telegraf/logger"
+	"github.com/influxdata/telegraf/plugins/inputs/memcached"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSecretConfig(t *testing.T, dir, contents string) string {
+	path := filepath.Join(dir, "telegraf.conf")
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func TestConfig_SecretDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	secrets := filepath.Join(dir, "secrets")
+	require.NoError(t, os.Mkdir(secrets, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(secrets, "server"),
+		[]byte("192.168.1.1\n"), 0600))
+
+	path := writeSecretConfig(t, dir, fmt.Sprintf(`
+[[secretstores.directory]]
+  id = "files"
+  path = %q
+
+[[inputs.memcached]]
+  servers = ["@{files:server}:11211", "localhost"]
+`, secrets))
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig(path))
+	require.Len(t, c.Inputs, 1)
+	assert.Equal(t, []string{"192.168.1.1:11211", "localhost"},
+		c.Inputs[0].Input.(*memcached.Memcached).Servers)
+	assert.Equal(t, "server ******", logger.Redact("server 192.168.1.1"))
+
+	// A changed secret changes the checksum of the plugin.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(secrets, "server"),
+		[]byte("192.168.1.2\n"), 0600))
+	c2 := NewConfig()
+	require.NoError(t, c2.LoadConfig(path))
+	assert.NotEqual(t, c.Inputs[0].Checksum, c2.Inputs[0].Checksum)
+}
+
+func TestConfig_SecretErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{
+			name: "unknown store",
+			contents: `
+[[inputs.memcached]]
+  servers = ["@{missing:server}"]
+`,
+		},
+		{
+			name: "missing key",
+			contents: fmt.Sprintf(`
+[[secretstores.directory]]
+  id = "files"
+  path = %q
+
+[[inputs.memcached]]
+  servers = ["@{files:missing}"]
+`, dir),
+		},
+		{
+			name: "invalid key",
+			contents: fmt.Sprintf(`
+[[secretstores.directory]]
+  id = "files"
+  path = %q
+
+[[inputs.memcached]]
+  servers = ["@{files:../telegraf.conf}"]
+`, dir),
+		},
+		{
+			name: "unknown store type",
+			contents: `
+[[secretstores.vault]]
+  id = "vault"
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeSecretConfig(t, dir, tt.contents)
+			c := NewConfig()
+			assert.Error(t, c.LoadConfig(path))
+		})
+	}
+}
+
+func TestConfig_SecretKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyring := filepath.Join(dir, "secrets.keyring")
+	require.NoError(t, WriteKeyring(keyring, "password",
+		map[string]string{"server": "192.168.1.1"}))
+
+	_, err = ReadKeyring(keyring, "wrong")
+	require.Error(t, err)
+
+	path := writeSecretConfig(t, dir, fmt.Sprintf(`
+[[secretstores.keyring]]
+  id = "keyring"
+  path = %q
+  password = "password"
+
+[[inputs.memcached]]
+  servers = ["@{keyring:server}"]
+`, keyring))
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig(path))
+	require.Len(t, c.Inputs, 1)
+	assert.Equal(t, []string{"192.168.1.1"},
+		c.Inputs[0].Input.(*memcached.Memcached).Servers)
+}
+
+func TestConfig_SecretCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping test on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeSecretConfig(t, dir, `
+[[secretstores.command]]
+  id = "helper"
+  command = ["echo", "host"]
+
+[[inputs.memcached]]
+  servers = ["@{helper:server}"]
+`)
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig(path))
+	require.Len(t, c.Inputs, 1)
+	assert.Equal(t, []string{"host server"},
+		c.Inputs[0].Input.(*memcached.Memcached).Servers)
+}
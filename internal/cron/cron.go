@@ -0,0 +1,319 @@
+// Package cron parses cron schedules and computes their run times.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron schedule.  Times are matched against the wall
+// clock of its location.
+type Schedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	// When both day fields are restricted a day matching either of them
+	// matches, otherwise both must match.
+	domStar bool
+	dowStar bool
+
+	location *time.Location
+}
+
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	doms    = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Sunday is both 0 and 7.
+	dows = bounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a schedule in the standard five field format:
+//
+//   minute hour day-of-month month day-of-week
+//
+// Fields are lists of values, ranges and steps, ie "1,15", "9-17" or "*/10";
+// months and days of the week may be given by name.  The descriptors @yearly,
+// @monthly, @weekly, @daily and @hourly are also accepted.  The schedule is
+// in the local time zone unless it is prefixed with "CRON_TZ=<zone>" or
+// "TZ=<zone>", ie "CRON_TZ=Europe/Paris 0 6 * * *".
+func Parse(spec string) (*Schedule, error) {
+	s := &Schedule{location: time.Local}
+
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		i := strings.IndexAny(spec, " \t")
+		if i == -1 {
+			return nil, fmt.Errorf("missing schedule after time zone in %q", spec)
+		}
+		zone := spec[strings.Index(spec, "=")+1 : i]
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %v", zone, err)
+		}
+		s.location = loc
+		spec = strings.TrimSpace(spec[i:])
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		expanded, ok := descriptors[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("unknown descriptor %q", spec)
+		}
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, found %d in %q", len(fields), spec)
+	}
+
+	var err error
+	if s.minute, err = parseField(fields[0], minutes); err != nil {
+		return nil, fmt.Errorf("minute: %v", err)
+	}
+	if s.hour, err = parseField(fields[1], hours); err != nil {
+		return nil, fmt.Errorf("hour: %v", err)
+	}
+	if s.dom, err = parseField(fields[2], doms); err != nil {
+		return nil, fmt.Errorf("day of month: %v", err)
+	}
+	if s.month, err = parseField(fields[3], months); err != nil {
+		return nil, fmt.Errorf("month: %v", err)
+	}
+	if s.dow, err = parseField(fields[4], dows); err != nil {
+		return nil, fmt.Errorf("day of week: %v", err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = isStar(fields[2])
+	s.dowStar = isStar(fields[4])
+	return s, nil
+}
+
+func isStar(field string) bool {
+	return field == "*" || field == "?"
+}
+
+// parseField returns the bitset of the values matched by a field.
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		r, err := parseRange(expr, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= r
+	}
+	return bits, nil
+}
+
+// parseRange parses a single value, range or step.
+func parseRange(expr string, b bounds) (uint64, error) {
+	step := 1
+	if i := strings.Index(expr, "/"); i != -1 {
+		var err error
+		step, err = strconv.Atoi(expr[i+1:])
+		if err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid step in %q", expr)
+		}
+		expr = expr[:i]
+	}
+
+	var start, end int
+	switch {
+	case expr == "*" || expr == "?":
+		start, end = b.min, b.max
+	case strings.Contains(expr, "-"):
+		parts := strings.SplitN(expr, "-", 2)
+		var err error
+		if start, err = parseValue(parts[0], b); err != nil {
+			return 0, err
+		}
+		if end, err = parseValue(parts[1], b); err != nil {
+			return 0, err
+		}
+		if start > end {
+			return 0, fmt.Errorf("invalid range %q", expr)
+		}
+	default:
+		var err error
+		if start, err = parseValue(expr, b); err != nil {
+			return 0, err
+		}
+		end = start
+		if step > 1 {
+			// "N/step" starts at N and runs to the maximum.
+			end = b.max
+		}
+	}
+
+	var bits uint64
+	for v := start; v <= end; v += step {
+		bits |= 1 << uint(v)
+	}
+	return bits, nil
+}
+
+func parseValue(s string, b bounds) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, b.min, b.max)
+	}
+	return v, nil
+}
+
+// Location returns the time zone of the schedule.
+func (s *Schedule) Location() *time.Location {
+	return s.location
+}
+
+// Next returns the first time after t matching the schedule, or the zero time
+// if there is none within five years.
+//
+// When clocks are set forward, times in the skipped hour run at the time of
+// the change.  When clocks are set back, times in the repeated hour only run
+// once, unless the schedule runs every hour.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := s.location
+	t = t.In(loc)
+
+	// Start at the next whole minute.
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second -
+		time.Duration(t.Nanosecond()))
+	limit := t.Year() + 5
+
+wrap:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+
+	for s.month&(1<<uint(t.Month())) == 0 {
+		t = midnight(t.Year(), t.Month()+1, 1, loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		month := t.Month()
+		t = midnight(t.Year(), t.Month(), t.Day()+1, loc)
+		if t.Month() != month {
+			goto wrap
+		}
+	}
+
+	// When midnight was skipped, earlier hours run at the start of the day.
+	if t.Hour() > 0 && t.Add(-time.Minute).Day() != t.Day() &&
+		s.hour&(1<<uint(t.Hour())-1) != 0 {
+		return t
+	}
+
+	// Hours are advanced in absolute time, as time.Date may normalize a time
+	// skipped by a clock change backwards.
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		day := t.Day()
+		next := t.Add(time.Hour - time.Duration(t.Minute())*time.Minute)
+		if s.skipped(t, next) {
+			return next
+		}
+		t = next
+		if t.Day() != day {
+			goto wrap
+		}
+	}
+
+	for s.minute&(1<<uint(t.Minute())) == 0 || s.repeated(t) {
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	return t
+}
+
+// midnight returns the start of the day, normalizing the date like time.Date.
+// When midnight is skipped by a clock change the day starts at the change.
+func midnight(year int, month time.Month, day int, loc *time.Location) time.Time {
+	noon := time.Date(year, month, day, 12, 0, 0, 0, loc)
+	t := time.Date(noon.Year(), noon.Month(), noon.Day(), 0, 0, 0, 0, loc)
+	for t.Day() != noon.Day() {
+		t = t.Add(time.Hour)
+	}
+	return t
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// skipped returns true if the clocks were set forward between t and next,
+// skipping an hour matching the schedule.
+func (s *Schedule) skipped(t, next time.Time) bool {
+	if next.Day() != t.Day() {
+		return false
+	}
+	for h := t.Hour() + 1; h < next.Hour(); h++ {
+		if s.hour&(1<<uint(h)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// repeated returns true if the wall clock time of t already occurred, as the
+// clocks were set back, and the schedule does not run every hour.
+func (s *Schedule) repeated(t time.Time) bool {
+	if s.hour == 1<<24-1 {
+		return false
+	}
+
+	_, offset := t.Zone()
+	_, before := t.Add(-3 * time.Hour).Zone()
+	if before <= offset {
+		return false
+	}
+	earlier := t.Add(-time.Duration(before-offset) * time.Second)
+	return earlier.Day() == t.Day() &&
+		earlier.Hour() == t.Hour() &&
+		earlier.Minute() == t.Minute()
+}
@@ -0,0 +1,146 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@fortnightly",
+		"CRON_TZ=Nowhere/Special 0 * * * *",
+		"TZ=UTC",
+	}
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			_, err := Parse(spec)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		spec     string
+		from     string
+		expected string
+	}{
+		{"* * * * *", "2019-03-01T10:15:30Z", "2019-03-01T10:16:00Z"},
+		{"0 */6 * * *", "2019-03-01T10:15:00Z", "2019-03-01T12:00:00Z"},
+		{"0 */6 * * *", "2019-03-01T12:00:00Z", "2019-03-01T18:00:00Z"},
+		{"0 */6 * * *", "2019-03-01T18:00:00Z", "2019-03-02T00:00:00Z"},
+		{"30 9-17/4 * * *", "2019-03-01T13:30:00Z", "2019-03-01T17:30:00Z"},
+		{"0 0 1 * *", "2019-01-31T00:00:00Z", "2019-02-01T00:00:00Z"},
+		{"0 0 31 * *", "2019-04-01T00:00:00Z", "2019-05-31T00:00:00Z"},
+		{"0 0 29 2 *", "2019-01-01T00:00:00Z", "2020-02-29T00:00:00Z"},
+		{"0 0 * * mon-fri", "2019-03-01T12:00:00Z", "2019-03-04T00:00:00Z"},
+		{"0 0 * * 7", "2019-03-01T12:00:00Z", "2019-03-03T00:00:00Z"},
+		{"0 0 1 jan,jul *", "2019-03-01T00:00:00Z", "2019-07-01T00:00:00Z"},
+		// Restricted day of month and day of week match either.
+		{"0 0 15 * sun", "2019-03-04T00:00:00Z", "2019-03-10T00:00:00Z"},
+		{"0 0 15 * sun", "2019-03-11T00:00:00Z", "2019-03-15T00:00:00Z"},
+		{"@hourly", "2019-03-01T10:15:00Z", "2019-03-01T11:00:00Z"},
+		{"@weekly", "2019-03-01T10:15:00Z", "2019-03-03T00:00:00Z"},
+		{"CRON_TZ=Asia/Tokyo 0 9 * * *", "2019-03-01T01:00:00Z", "2019-03-02T00:00:00Z"},
+		{"TZ=America/New_York 0 9 * * *", "2019-03-01T15:00:00Z", "2019-03-02T14:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+" from "+tt.from, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			require.NoError(t, err)
+			if s.Location() == time.Local {
+				s.location = time.UTC
+			}
+
+			from, err := time.Parse(time.RFC3339, tt.from)
+			require.NoError(t, err)
+			expected, err := time.Parse(time.RFC3339, tt.expected)
+			require.NoError(t, err)
+
+			require.True(t, expected.Equal(s.Next(from)),
+				"expected %s, got %s", expected, s.Next(from))
+		})
+	}
+}
+
+func TestNextNoMatch(t *testing.T) {
+	s, err := Parse("0 0 31 2 *")
+	require.NoError(t, err)
+	require.True(t, s.Next(time.Now()).IsZero())
+}
+
+// runs returns the run times of the schedule between from and to.
+func runs(s *Schedule, from, to time.Time) []string {
+	var result []string
+	for t := s.Next(from); !t.IsZero() && t.Before(to); t = s.Next(t) {
+		result = append(result, t.Format("2006-01-02 15:04 MST"))
+	}
+	return result
+}
+
+func TestNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/Chicago")
+	require.NoError(t, err)
+
+	// Clocks were set forward from 02:00 to 03:00 on 2019-03-10, the 02:30
+	// run happens at the time of the change.
+	s, err := Parse("CRON_TZ=America/Chicago 30 2 * * *")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"2019-03-09 02:30 CST",
+		"2019-03-10 03:00 CDT",
+		"2019-03-11 02:30 CDT",
+	}, runs(s,
+		time.Date(2019, 3, 9, 0, 0, 0, 0, loc),
+		time.Date(2019, 3, 12, 0, 0, 0, 0, loc)))
+
+	// Clocks were set back from 02:00 to 01:00 on 2019-11-03, the 01:30 run
+	// happens once.
+	s, err = Parse("CRON_TZ=America/Chicago 30 1 * * *")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"2019-11-02 01:30 CDT",
+		"2019-11-03 01:30 CDT",
+		"2019-11-04 01:30 CST",
+	}, runs(s,
+		time.Date(2019, 11, 2, 0, 0, 0, 0, loc),
+		time.Date(2019, 11, 5, 0, 0, 0, 0, loc)))
+
+	// Schedules running every hour run through the repeated hour.
+	s, err = Parse("CRON_TZ=America/Chicago 0 * * * *")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"2019-11-03 00:00 CDT",
+		"2019-11-03 01:00 CDT",
+		"2019-11-03 01:00 CST",
+		"2019-11-03 02:00 CST",
+	}, runs(s,
+		time.Date(2019, 11, 2, 23, 30, 0, 0, loc),
+		time.Date(2019, 11, 3, 2, 30, 0, 0, loc)))
+
+	// Clocks were set forward from 00:00 to 01:00 on 2018-11-04 in Sao Paulo,
+	// the day starts at 01:00.
+	s, err = Parse("CRON_TZ=America/Sao_Paulo 0 0 * * *")
+	require.NoError(t, err)
+	saoPaulo := s.Location()
+	require.Equal(t, []string{
+		"2018-11-03 00:00 -03",
+		"2018-11-04 01:00 -02",
+		"2018-11-05 00:00 -02",
+	}, runs(s,
+		time.Date(2018, 11, 2, 12, 0, 0, 0, saoPaulo),
+		time.Date(2018, 11, 5, 12, 0, 0, 0, saoPaulo)))
+}
@@ -12,6 +12,30 @@ var (
 	AgentMetricsDropped = selfstat.Register("agent", "metrics_dropped", map[string]string{})
 )
 
+// Drop policies, selecting which metrics are dropped when a buffer is full.
+const (
+	// DropOldest drops the oldest metrics in the buffer to make room.
+	DropOldest = "oldest"
+
+	// DropNewest drops the metrics being added.
+	DropNewest = "newest"
+
+	// DropBlock blocks adding metrics until there is room in the buffer,
+	// see RunningOutput.WaitBuffer.  The buffer drops the oldest metrics
+	// when it overflows nonetheless.
+	DropBlock = "block"
+)
+
+// metricBuffer is the interface implemented by the in memory Buffer and the
+// DiskBuffer.
+type metricBuffer interface {
+	Len() int
+	Add(metrics ...telegraf.Metric)
+	Batch(batchSize int) []telegraf.Metric
+	Accept(batch []telegraf.Metric)
+	Reject(batch []telegraf.Metric)
+}
+
 // Buffer stores metrics in a circular buffer.
 type Buffer struct {
 	sync.Mutex
@@ -24,15 +48,28 @@ type Buffer struct {
 	batchFirst int // index of the first metric in the batch
 	batchSize  int // number of metrics currently in the batch
 
-	MetricsAdded   selfstat.Stat
-	MetricsWritten selfstat.Stat
-	MetricsDropped selfstat.Stat
+	sizes    []int64 // estimated memory used by each metric in buf
+	bytes    int64   // estimated memory used by the metrics in the buffer
+	maxBytes int64   // limit of the estimated memory, no limit when zero
+	policy   string  // drop policy when the buffer is full
+
+	MetricsAdded    selfstat.Stat
+	MetricsWritten  selfstat.Stat
+	MetricsDropped  selfstat.Stat
+	BufferSizeBytes selfstat.Stat
 }
 
 // NewBuffer returns a new empty Buffer with the given capacity.
 func NewBuffer(name string, capacity int) *Buffer {
+	return newMemoryBuffer(map[string]string{"output": name}, capacity)
+}
+
+// newMemoryBuffer returns a new empty Buffer with its stats registered with
+// the given tags.
+func newMemoryBuffer(tags map[string]string, capacity int) *Buffer {
 	b := &Buffer{
 		buf:   make([]telegraf.Metric, capacity),
+		sizes: make([]int64, capacity),
 		first: 0,
 		last:  0,
 		size:  0,
@@ -41,22 +78,44 @@ func NewBuffer(name string, capacity int) *Buffer {
 		MetricsAdded: selfstat.Register(
 			"write",
 			"metrics_added",
-			map[string]string{"output": name},
+			tags,
 		),
 		MetricsWritten: selfstat.Register(
 			"write",
 			"metrics_written",
-			map[string]string{"output": name},
+			tags,
 		),
 		MetricsDropped: selfstat.Register(
 			"write",
 			"metrics_dropped",
-			map[string]string{"output": name},
+			tags,
+		),
+		BufferSizeBytes: selfstat.Register(
+			"write",
+			"buffer_size_bytes",
+			tags,
 		),
 	}
 	return b
 }
 
+// SetLimitBytes limits the estimated memory used by the metrics in the
+// buffer, a limit of zero disables it.
+func (b *Buffer) SetLimitBytes(limit int64) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.maxBytes = limit
+}
+
+// SetDropPolicy sets which metrics are dropped when the buffer is full.
+func (b *Buffer) SetDropPolicy(policy string) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.policy = policy
+}
+
 // Len returns the number of metrics currently in the buffer.
 func (b *Buffer) Len() int {
 	b.Lock()
@@ -65,6 +124,14 @@ func (b *Buffer) Len() int {
 	return b.size
 }
 
+// Bytes returns the estimated memory used by the metrics in the buffer.
+func (b *Buffer) Bytes() int64 {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.bytes
+}
+
 func (b *Buffer) metricAdded() {
 	b.MetricsAdded.Incr(1)
 }
@@ -82,9 +149,25 @@ func (b *Buffer) metricDropped(metric telegraf.Metric) {
 }
 
 func (b *Buffer) add(m telegraf.Metric) {
+	size := metricSize(m)
+
+	if b.policy == DropNewest && (b.size == b.cap || b.exceeds(size)) {
+		b.metricAdded()
+		b.metricDropped(m)
+		return
+	}
+
+	// With the block policy the memory limit is enforced by the output
+	// waiting for room, so that metrics are not dropped.
+	if b.policy != DropBlock {
+		for b.size > 0 && b.exceeds(size) {
+			b.dropOldest()
+		}
+	}
+
 	// Check if Buffer is full
 	if b.size == b.cap {
-		b.metricDropped(b.buf[b.last])
+		b.metricDropped(b.take(b.last))
 
 		if b.last == b.batchFirst && b.batchSize > 0 {
 			b.batchSize--
@@ -94,7 +177,7 @@ func (b *Buffer) add(m telegraf.Metric) {
 
 	b.metricAdded()
 
-	b.buf[b.last] = m
+	b.put(b.last, m, size)
 	b.last = b.next(b.last)
 
 	if b.size == b.cap {
@@ -112,6 +195,7 @@ func (b *Buffer) Add(metrics ...telegraf.Metric) {
 	for i := range metrics {
 		b.add(metrics[i])
 	}
+	b.BufferSizeBytes.Set(b.bytes)
 }
 
 // Batch returns a slice containing up to batchSize of the most recently added
@@ -133,13 +217,13 @@ func (b *Buffer) Batch(batchSize int) []telegraf.Metric {
 
 	batchIndex := b.batchFirst
 	for i := range out {
-		out[len(out)-1-i] = b.buf[batchIndex]
-		b.buf[batchIndex] = nil
+		out[len(out)-1-i] = b.take(batchIndex)
 		batchIndex = b.next(batchIndex)
 	}
 
 	b.last = b.batchFirst
 	b.size -= outLen
+	b.BufferSizeBytes.Set(b.bytes)
 	return out
 }
 
@@ -175,11 +259,11 @@ func (b *Buffer) Reject(batch []telegraf.Metric) {
 		re = b.prev(re)
 
 		if b.buf[re] != nil {
-			b.metricDropped(b.buf[re])
+			b.metricDropped(b.take(re))
 		}
 
-		b.buf[re] = b.buf[rp]
-		b.buf[rp] = nil
+		b.buf[re], b.sizes[re] = b.buf[rp], b.sizes[rp]
+		b.buf[rp], b.sizes[rp] = nil, 0
 	}
 
 	// Copy metrics from the batch back into the buffer; recall that the
@@ -187,7 +271,7 @@ func (b *Buffer) Reject(batch []telegraf.Metric) {
 	for i := range batch {
 		if i < restore {
 			re = b.prev(re)
-			b.buf[re] = batch[i]
+			b.put(re, batch[i], metricSize(batch[i]))
 			b.size++
 		} else {
 			b.metricDropped(batch[i])
@@ -195,6 +279,43 @@ func (b *Buffer) Reject(batch []telegraf.Metric) {
 	}
 
 	b.resetBatch()
+
+	if b.policy != DropBlock {
+		for b.size > 0 && b.exceeds(0) {
+			b.dropOldest()
+		}
+	}
+	b.BufferSizeBytes.Set(b.bytes)
+}
+
+// put stores a metric of the given estimated size at index.
+func (b *Buffer) put(index int, m telegraf.Metric, size int64) {
+	b.buf[index] = m
+	b.sizes[index] = size
+	b.bytes += size
+}
+
+// take removes and returns the metric at index.
+func (b *Buffer) take(index int) telegraf.Metric {
+	m := b.buf[index]
+	b.bytes -= b.sizes[index]
+	b.buf[index] = nil
+	b.sizes[index] = 0
+	return m
+}
+
+// dropOldest drops the oldest metric in the buffer.
+func (b *Buffer) dropOldest() {
+	if m := b.take(b.first); m != nil {
+		b.metricDropped(m)
+	}
+	b.first = b.next(b.first)
+	b.size--
+}
+
+// exceeds returns true if adding size bytes would exceed the memory limit.
+func (b *Buffer) exceeds(size int64) bool {
+	return b.maxBytes > 0 && b.bytes+size > b.maxBytes
 }
 
 // dist returns the distance between two indexes.  Because this data structure
@@ -238,6 +359,27 @@ func (b *Buffer) resetBatch() {
 	b.batchSize = 0
 }
 
+// metricSize estimates the memory used by a metric from the length of its
+// name, tags and fields.
+func metricSize(m telegraf.Metric) int64 {
+	// Approximate overhead of the metric, and of each tag and field.
+	const metricOverhead, entryOverhead = 64, 32
+
+	size := int64(metricOverhead + len(m.Name()))
+	for _, tag := range m.TagList() {
+		size += int64(entryOverhead + len(tag.Key) + len(tag.Value))
+	}
+	for _, field := range m.FieldList() {
+		size += int64(entryOverhead + len(field.Key))
+		if s, ok := field.Value.(string); ok {
+			size += int64(len(s))
+		} else {
+			size += 8
+		}
+	}
+	return size
+}
+
 func min(a, b int) int {
 	if b < a {
 		return b
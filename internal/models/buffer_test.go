@@ -636,3 +636,81 @@ func TestBuffer_AddOverwriteAndRejectOffset(t *testing.T) {
 	require.Equal(t, 13, reject)
 	require.Equal(t, 5, accept)
 }
+
+func TestBuffer_LimitBytesDropsOldest(t *testing.T) {
+	size := metricSize(Metric())
+	b := setup(NewBuffer("test", 10))
+	b.SetLimitBytes(3 * size)
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4))
+
+	require.Equal(t, 3, b.Len())
+	require.Equal(t, 3*size, b.Bytes())
+	require.Equal(t, int64(1), b.MetricsDropped.Get())
+
+	batch := b.Batch(5)
+	require.Len(t, batch, 3)
+	require.Equal(t, MetricTime(4), batch[0])
+	require.Equal(t, MetricTime(2), batch[2])
+	require.Equal(t, int64(0), b.Bytes())
+}
+
+func TestBuffer_LimitBytesReject(t *testing.T) {
+	size := metricSize(Metric())
+	b := setup(NewBuffer("test", 10))
+	b.SetLimitBytes(3 * size)
+	b.Add(MetricTime(1), MetricTime(2))
+
+	batch := b.Batch(2)
+	b.Add(MetricTime(3), MetricTime(4))
+	b.Reject(batch)
+
+	require.Equal(t, 3, b.Len())
+	require.Equal(t, 3*size, b.Bytes())
+	require.Equal(t, int64(1), b.MetricsDropped.Get())
+}
+
+func TestBuffer_DropNewest(t *testing.T) {
+	b := setup(NewBuffer("test", 3))
+	b.SetDropPolicy(DropNewest)
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4))
+
+	require.Equal(t, 3, b.Len())
+	require.Equal(t, int64(1), b.MetricsDropped.Get())
+	batch := b.Batch(3)
+	require.Equal(t, MetricTime(3), batch[0])
+	require.Equal(t, MetricTime(1), batch[2])
+}
+
+func TestBuffer_DropNewestLimitBytes(t *testing.T) {
+	size := metricSize(Metric())
+	b := setup(NewBuffer("test", 10))
+	b.SetLimitBytes(2 * size)
+	b.SetDropPolicy(DropNewest)
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+
+	require.Equal(t, 2, b.Len())
+	require.Equal(t, int64(1), b.MetricsDropped.Get())
+	batch := b.Batch(3)
+	require.Equal(t, MetricTime(2), batch[0])
+}
+
+func TestBuffer_DropBlockExceedsLimitBytes(t *testing.T) {
+	size := metricSize(Metric())
+	b := setup(NewBuffer("test", 10))
+	b.SetLimitBytes(2 * size)
+	b.SetDropPolicy(DropBlock)
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+
+	require.Equal(t, 3, b.Len())
+	require.Equal(t, int64(0), b.MetricsDropped.Get())
+}
+
+func TestBuffer_MetricSize(t *testing.T) {
+	small := metricSize(Metric())
+	m, err := metric.New("cpu",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"value": 42.0, "message": "hello world"},
+		time.Unix(0, 0))
+	require.NoError(t, err)
+	require.True(t, metricSize(m) > small)
+}
@@ -0,0 +1,935 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+const (
+	// Default size in bytes after which a new segment file is started.
+	diskSegmentSize = 8 * 1024 * 1024
+
+	// Largest record that will be read back from a segment, anything bigger
+	// is assumed to be corrupt.
+	diskMaxRecordSize = 64 * 1024 * 1024
+
+	// Each record is prefixed by its length and CRC-32 checksum.
+	diskRecordHeaderSize = 8
+
+	diskSegmentSuffix  = ".seg"
+	diskCheckpointFile = "checkpoint"
+)
+
+var errCorruptRecord = errors.New("corrupt record")
+
+type diskSegment struct {
+	id   uint64
+	size int64
+}
+
+// diskPosition is the location of a record in the segment files.
+type diskPosition struct {
+	segment uint64
+	offset  int64
+}
+
+// DiskBuffer stores metrics in append-only segment files so that metrics
+// which have not been written survive a restart of the agent.
+//
+// Metrics are stored in the order they are added and batches are returned
+// oldest first.  The position of the oldest metric not yet accepted by the
+// output is kept in a checkpoint file, segments before the checkpoint are
+// removed.
+//
+// Metrics are accepted as soon as they have been stored on disk, metrics
+// returned by Batch() do not carry tracking information.
+type DiskBuffer struct {
+	sync.Mutex
+	name string
+	dir  string
+	cap  int // the maximum number of metrics in the buffer
+
+	segments    []*diskSegment // segments on disk ordered oldest to newest
+	segmentSize int64          // size after which a new segment is started
+	writer      *os.File       // the newest segment, opened for appending
+	loaded      bool           // the directory has been opened, see load
+
+	head  diskPosition // position of the oldest metric
+	size  int          // number of metrics currently in the buffer
+	bytes int64        // number of bytes currently used by the buffer
+
+	batchEnd   diskPosition // position after the last metric in the batch
+	batchSize  int          // number of metrics currently in the batch
+	batchBytes int64        // number of bytes used by the batch
+
+	policy string // drop policy when the buffer is full
+
+	MetricsAdded    selfstat.Stat
+	MetricsWritten  selfstat.Stat
+	MetricsDropped  selfstat.Stat
+	BufferSizeBytes selfstat.Stat
+}
+
+// NewDiskBuffer returns a DiskBuffer with the given capacity stored in dir.
+// Any metrics left in dir by a previous run are loaded into the buffer when
+// it is first used.
+func NewDiskBuffer(name string, dir string, capacity int) (*DiskBuffer, error) {
+	return newDiskBuffer(name, map[string]string{"output": name}, dir, capacity)
+}
+
+// newDiskBuffer returns a DiskBuffer with its stats registered with the given
+// tags.
+func newDiskBuffer(name string, tags map[string]string, dir string, capacity int) (*DiskBuffer, error) {
+	b := &DiskBuffer{
+		name: name,
+		dir:  dir,
+		cap:  capacity,
+
+		segmentSize: diskSegmentSize,
+
+		MetricsAdded: selfstat.Register(
+			"write",
+			"metrics_added",
+			tags,
+		),
+		MetricsWritten: selfstat.Register(
+			"write",
+			"metrics_written",
+			tags,
+		),
+		MetricsDropped: selfstat.Register(
+			"write",
+			"metrics_dropped",
+			tags,
+		),
+		BufferSizeBytes: selfstat.Register(
+			"write",
+			"buffer_size_bytes",
+			tags,
+		),
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// SetDropPolicy sets which metrics are dropped when the buffer is full.
+func (b *DiskBuffer) SetDropPolicy(policy string) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.policy = policy
+}
+
+// load opens the buffer directory on first use.  Opening is deferred so that
+// when the configuration is reloaded the buffer of a replacement output can
+// be created while the output it replaces is still writing to the directory.
+func (b *DiskBuffer) load() {
+	if b.loaded {
+		return
+	}
+	b.loaded = true
+
+	if err := b.open(); err != nil {
+		log.Printf("E! [outputs.%s] Unable to open buffer directory %s: %v",
+			b.name, b.dir, err)
+		b.segments = nil
+		b.head = diskPosition{}
+		b.size = 0
+		b.bytes = 0
+		return
+	}
+
+	if b.size > 0 {
+		log.Printf("I! [outputs.%s] Loaded %d metrics from buffer directory %s",
+			b.name, b.size, b.dir)
+	}
+}
+
+// Len returns the number of metrics currently in the buffer.
+func (b *DiskBuffer) Len() int {
+	b.Lock()
+	defer b.Unlock()
+	b.load()
+
+	return b.size - b.batchSize
+}
+
+// Bytes returns the number of bytes currently used by the buffer.
+func (b *DiskBuffer) Bytes() int64 {
+	b.Lock()
+	defer b.Unlock()
+	b.load()
+
+	return b.bytes
+}
+
+func (b *DiskBuffer) metricAdded() {
+	b.MetricsAdded.Incr(1)
+}
+
+func (b *DiskBuffer) metricWritten(metric telegraf.Metric) {
+	AgentMetricsWritten.Incr(1)
+	b.MetricsWritten.Incr(1)
+	metric.Accept()
+}
+
+func (b *DiskBuffer) metricDropped(metric telegraf.Metric) {
+	AgentMetricsDropped.Incr(1)
+	b.MetricsDropped.Incr(1)
+	metric.Reject()
+}
+
+// recordsDropped counts metrics removed from disk without being written.
+func (b *DiskBuffer) recordsDropped(count int) {
+	AgentMetricsDropped.Incr(int64(count))
+	b.MetricsDropped.Incr(int64(count))
+}
+
+// Add adds metrics to the buffer
+func (b *DiskBuffer) Add(metrics ...telegraf.Metric) {
+	b.Lock()
+	defer b.Unlock()
+	b.load()
+
+	if b.policy == DropNewest && b.size+len(metrics) > b.cap {
+		room := b.cap - b.size
+		if room < 0 {
+			room = 0
+		}
+		for _, m := range metrics[room:] {
+			b.metricAdded()
+			b.metricDropped(m)
+		}
+		metrics = metrics[:room]
+	}
+
+	if len(metrics) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		b.metricAdded()
+		appendRecord(&buf, m)
+	}
+
+	err := b.write(buf.Bytes())
+	if err != nil {
+		log.Printf("E! [outputs.%s] Unable to write metrics to buffer: %v",
+			b.name, err)
+		for _, m := range metrics {
+			b.metricDropped(m)
+		}
+		return
+	}
+
+	b.size += len(metrics)
+	b.bytes += int64(buf.Len())
+	for _, m := range metrics {
+		m.Accept()
+	}
+
+	b.trim()
+	b.BufferSizeBytes.Set(b.bytes)
+}
+
+// Batch returns a slice containing up to batchSize of the oldest metrics in
+// the buffer.  Metrics are ordered from oldest to newest in the batch.
+func (b *DiskBuffer) Batch(batchSize int) []telegraf.Metric {
+	b.Lock()
+	defer b.Unlock()
+	b.load()
+
+	outLen := min(b.size, batchSize)
+	out := make([]telegraf.Metric, 0, outLen)
+	if outLen == 0 {
+		return out
+	}
+
+	end, nbytes, err := b.read(b.head, outLen, func(payload []byte) error {
+		m, err := decodeRecord(payload)
+		if err != nil {
+			// The checksum matched, so retrying will not help; skip it.
+			log.Printf("E! [outputs.%s] Unable to decode metric from buffer: %v",
+				b.name, err)
+			b.recordsDropped(1)
+			return nil
+		}
+		out = append(out, m)
+		return nil
+	})
+	if err != nil {
+		log.Printf("E! [outputs.%s] Unable to read metrics from buffer: %v",
+			b.name, err)
+		b.recover()
+		return out[:0]
+	}
+
+	if len(out) == 0 {
+		// Every record was skipped, there is no batch to wait on.
+		b.head = end
+		b.size -= outLen
+		b.bytes -= nbytes
+		b.checkpoint()
+		b.BufferSizeBytes.Set(b.bytes)
+		return out
+	}
+
+	b.batchEnd = end
+	b.batchSize = outLen
+	b.batchBytes = nbytes
+	return out
+}
+
+// Accept marks the batch, acquired from Batch(), as successfully written.
+func (b *DiskBuffer) Accept(batch []telegraf.Metric) {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, m := range batch {
+		b.metricWritten(m)
+	}
+
+	b.head = b.batchEnd
+	b.size -= b.batchSize
+	b.bytes -= b.batchBytes
+	b.resetBatch()
+	b.checkpoint()
+
+	b.trim()
+	b.BufferSizeBytes.Set(b.bytes)
+}
+
+// Reject marks the batch, acquired from Batch(), as unsent.  The metrics
+// remain on disk and will be returned again by the next call to Batch().
+func (b *DiskBuffer) Reject(batch []telegraf.Metric) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.resetBatch()
+
+	b.trim()
+	b.BufferSizeBytes.Set(b.bytes)
+}
+
+// Close closes the segment currently being written.
+func (b *DiskBuffer) Close() error {
+	b.Lock()
+	defer b.Unlock()
+
+	b.loaded = true
+	if b.writer == nil {
+		return nil
+	}
+	err := b.writer.Close()
+	b.writer = nil
+	return err
+}
+
+func (b *DiskBuffer) resetBatch() {
+	b.batchEnd = diskPosition{}
+	b.batchSize = 0
+	b.batchBytes = 0
+}
+
+// trim drops the oldest metrics until the buffer is within its capacity.
+// While a batch is outstanding the buffer is allowed to grow past the
+// capacity, since the oldest metrics are part of the batch.
+func (b *DiskBuffer) trim() {
+	if b.batchSize > 0 || b.size <= b.cap {
+		return
+	}
+
+	count := b.size - b.cap
+	head, nbytes, err := b.read(b.head, count, nil)
+	if err != nil {
+		log.Printf("E! [outputs.%s] Unable to read metrics from buffer: %v",
+			b.name, err)
+		b.recover()
+		return
+	}
+
+	b.head = head
+	b.size -= count
+	b.bytes -= nbytes
+	b.recordsDropped(count)
+	b.checkpoint()
+}
+
+// write appends the encoded records to the newest segment, starting a new
+// segment if the current one is full.
+func (b *DiskBuffer) write(data []byte) error {
+	if b.writer == nil {
+		return errors.New("buffer is closed")
+	}
+
+	seg := b.segments[len(b.segments)-1]
+	if seg.size >= b.segmentSize {
+		if err := b.rotate(); err != nil {
+			return err
+		}
+		seg = b.segments[len(b.segments)-1]
+	}
+
+	_, err := b.writer.Write(data)
+	if err != nil {
+		// Remove any partially written records.
+		b.writer.Truncate(seg.size)
+		return err
+	}
+
+	err = b.writer.Sync()
+	if err != nil {
+		return err
+	}
+
+	seg.size += int64(len(data))
+	return nil
+}
+
+// rotate starts a new segment for writing.
+func (b *DiskBuffer) rotate() error {
+	last := b.segments[len(b.segments)-1]
+	seg := &diskSegment{id: last.id + 1}
+
+	f, err := b.openSegment(seg.id)
+	if err != nil {
+		return err
+	}
+
+	b.writer.Close()
+	b.writer = f
+	b.segments = append(b.segments, seg)
+	return nil
+}
+
+func (b *DiskBuffer) openSegment(id uint64) (*os.File, error) {
+	return os.OpenFile(b.segmentPath(id),
+		os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+}
+
+func (b *DiskBuffer) segmentPath(id uint64) string {
+	return filepath.Join(b.dir, fmt.Sprintf("%020d%s", id, diskSegmentSuffix))
+}
+
+func (b *DiskBuffer) segmentIndex(id uint64) int {
+	for i, seg := range b.segments {
+		if seg.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// read reads count records starting at pos, calling fn with each payload when
+// not nil.  It returns the position after the last record read and the number
+// of bytes read.
+func (b *DiskBuffer) read(
+	pos diskPosition,
+	count int,
+	fn func(payload []byte) error,
+) (diskPosition, int64, error) {
+	var nbytes int64
+	var f *os.File
+	var r *bufio.Reader
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	for count > 0 {
+		i := b.segmentIndex(pos.segment)
+		if i < 0 {
+			return pos, nbytes, fmt.Errorf("missing segment %d", pos.segment)
+		}
+
+		if pos.offset >= b.segments[i].size {
+			if i == len(b.segments)-1 {
+				return pos, nbytes, io.ErrUnexpectedEOF
+			}
+
+			pos = diskPosition{segment: b.segments[i+1].id}
+			if f != nil {
+				f.Close()
+				f = nil
+			}
+			continue
+		}
+
+		if f == nil {
+			var err error
+			f, err = os.Open(b.segmentPath(pos.segment))
+			if err != nil {
+				return pos, nbytes, err
+			}
+
+			_, err = f.Seek(pos.offset, io.SeekStart)
+			if err != nil {
+				return pos, nbytes, err
+			}
+			r = bufio.NewReader(f)
+		}
+
+		payload, err := readRecord(r)
+		if err != nil {
+			return pos, nbytes, err
+		}
+
+		if fn != nil {
+			if err := fn(payload); err != nil {
+				return pos, nbytes, err
+			}
+		}
+
+		size := int64(diskRecordHeaderSize + len(payload))
+		pos.offset += size
+		nbytes += size
+		count--
+	}
+
+	return pos, nbytes, nil
+}
+
+// open loads the segments and checkpoint found in the buffer directory.
+func (b *DiskBuffer) open() error {
+	files, err := ioutil.ReadDir(b.dir)
+	if err != nil {
+		return err
+	}
+
+	// ReadDir sorts by filename, which orders the zero padded segment ids.
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, diskSegmentSuffix) {
+			continue
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, diskSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		b.segments = append(b.segments, &diskSegment{id: id, size: file.Size()})
+	}
+
+	head, err := b.readCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	for len(b.segments) > 0 && b.segments[0].id < head.segment {
+		err := os.Remove(b.segmentPath(b.segments[0].id))
+		if err != nil {
+			return err
+		}
+		b.segments = b.segments[1:]
+	}
+
+	if len(b.segments) == 0 {
+		b.segments = append(b.segments, &diskSegment{id: head.segment})
+		head.offset = 0
+	} else if b.segments[0].id != head.segment || head.offset > b.segments[0].size {
+		log.Printf("W! [outputs.%s] Buffer checkpoint does not match segments, "+
+			"reading from the oldest segment", b.name)
+		head = diskPosition{segment: b.segments[0].id}
+	}
+	b.head = head
+
+	err = b.scan()
+	if err != nil {
+		return err
+	}
+
+	b.writer, err = b.openSegment(b.segments[len(b.segments)-1].id)
+	if err != nil {
+		return err
+	}
+
+	b.trim()
+	b.BufferSizeBytes.Set(b.bytes)
+	return nil
+}
+
+// recover rescans the segments after a read error so that the buffer
+// continues past the damaged records.
+func (b *DiskBuffer) recover() {
+	err := b.scan()
+	if err != nil {
+		log.Printf("E! [outputs.%s] Unable to scan buffer: %v", b.name, err)
+	}
+	b.BufferSizeBytes.Set(b.bytes)
+}
+
+// scan counts the records after the head, truncating each segment at the
+// first partially written or corrupt record.
+func (b *DiskBuffer) scan() error {
+	b.size = 0
+	b.bytes = 0
+
+	for _, seg := range b.segments {
+		if seg.id < b.head.segment {
+			continue
+		}
+
+		var offset int64
+		if seg.id == b.head.segment {
+			offset = b.head.offset
+		}
+
+		count, end, err := scanSegment(b.segmentPath(seg.id), offset)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("W! [outputs.%s] Truncating buffer segment %d at offset %d: %v",
+				b.name, seg.id, end, err)
+
+			err = os.Truncate(b.segmentPath(seg.id), end)
+			if err != nil {
+				return err
+			}
+		}
+
+		seg.size = end
+		b.size += count
+		b.bytes += end - offset
+	}
+
+	return nil
+}
+
+// scanSegment reads the records in a segment starting at offset.  It returns
+// the number of valid records and the offset after the last valid record.
+func scanSegment(path string, offset int64) (int, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, offset, err
+	}
+	defer f.Close()
+
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return 0, offset, err
+	}
+
+	count := 0
+	r := bufio.NewReader(f)
+	for {
+		payload, err := readRecord(r)
+		if err == io.EOF {
+			return count, offset, nil
+		}
+		if err != nil {
+			return count, offset, err
+		}
+
+		count++
+		offset += int64(diskRecordHeaderSize + len(payload))
+	}
+}
+
+// checkpoint saves the head position, then removes the segments that no
+// longer contain any metrics.
+func (b *DiskBuffer) checkpoint() {
+	i := b.segmentIndex(b.head.segment)
+	for i >= 0 && i < len(b.segments)-1 && b.head.offset >= b.segments[i].size {
+		i++
+		b.head = diskPosition{segment: b.segments[i].id}
+	}
+
+	err := b.writeCheckpoint()
+	if err != nil {
+		log.Printf("E! [outputs.%s] Unable to write buffer checkpoint: %v",
+			b.name, err)
+		return
+	}
+
+	for len(b.segments) > 1 && b.segments[0].id < b.head.segment {
+		err := os.Remove(b.segmentPath(b.segments[0].id))
+		if err != nil {
+			log.Printf("E! [outputs.%s] Unable to remove buffer segment: %v",
+				b.name, err)
+			return
+		}
+		b.segments = b.segments[1:]
+	}
+}
+
+func (b *DiskBuffer) writeCheckpoint() error {
+	path := filepath.Join(b.dir, diskCheckpointFile)
+	tmp := path + ".tmp"
+
+	data := fmt.Sprintf("%d %d\n", b.head.segment, b.head.offset)
+	err := ioutil.WriteFile(tmp, []byte(data), 0640)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readCheckpoint returns the saved head position, or the start of the first
+// segment if there is no checkpoint.
+func (b *DiskBuffer) readCheckpoint() (diskPosition, error) {
+	var pos diskPosition
+	if len(b.segments) > 0 {
+		pos.segment = b.segments[0].id
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(b.dir, diskCheckpointFile))
+	if os.IsNotExist(err) {
+		return pos, nil
+	}
+	if err != nil {
+		return pos, err
+	}
+
+	_, err = fmt.Sscanf(string(data), "%d %d", &pos.segment, &pos.offset)
+	if err != nil {
+		return pos, fmt.Errorf("invalid buffer checkpoint: %v", err)
+	}
+	return pos, nil
+}
+
+// Value types used in the record encoding.
+const (
+	_ byte = iota
+	diskTypeFloat
+	diskTypeInt
+	diskTypeUint
+	diskTypeString
+	diskTypeBool
+)
+
+// appendRecord encodes the metric and appends it to buf along with its
+// record header.
+func appendRecord(buf *bytes.Buffer, m telegraf.Metric) {
+	start := buf.Len()
+	buf.Write(make([]byte, diskRecordHeaderSize))
+
+	var scratch [binary.MaxVarintLen64]byte
+	putUvarint := func(v uint64) {
+		n := binary.PutUvarint(scratch[:], v)
+		buf.Write(scratch[:n])
+	}
+	putString := func(s string) {
+		putUvarint(uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	putString(m.Name())
+	n := binary.PutVarint(scratch[:], m.Time().UnixNano())
+	buf.Write(scratch[:n])
+	buf.WriteByte(byte(m.Type()))
+	if m.IsAggregate() {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+
+	putUvarint(uint64(len(m.TagList())))
+	for _, tag := range m.TagList() {
+		putString(tag.Key)
+		putString(tag.Value)
+	}
+
+	fields := m.FieldList()
+	putUvarint(uint64(len(fields)))
+	for _, field := range fields {
+		putString(field.Key)
+		switch v := field.Value.(type) {
+		case float64:
+			buf.WriteByte(diskTypeFloat)
+			binary.BigEndian.PutUint64(scratch[:8], math.Float64bits(v))
+			buf.Write(scratch[:8])
+		case int64:
+			buf.WriteByte(diskTypeInt)
+			n := binary.PutVarint(scratch[:], v)
+			buf.Write(scratch[:n])
+		case uint64:
+			buf.WriteByte(diskTypeUint)
+			putUvarint(v)
+		case string:
+			buf.WriteByte(diskTypeString)
+			putString(v)
+		case bool:
+			buf.WriteByte(diskTypeBool)
+			if v {
+				buf.WriteByte(1)
+			} else {
+				buf.WriteByte(0)
+			}
+		default:
+			// Unreachable for metrics created by the metric package, store
+			// something that decodes rather than corrupting the record.
+			buf.WriteByte(diskTypeString)
+			putString(fmt.Sprintf("%v", v))
+		}
+	}
+
+	record := buf.Bytes()[start:]
+	payload := record[diskRecordHeaderSize:]
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+}
+
+// readRecord reads a single record and verifies its checksum.  io.EOF is
+// returned only if there are no more records.
+func readRecord(r io.Reader) ([]byte, error) {
+	var header [diskRecordHeaderSize]byte
+	_, err := io.ReadFull(r, header[:])
+	if err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > diskMaxRecordSize {
+		return nil, errCorruptRecord
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errCorruptRecord
+	}
+	return payload, nil
+}
+
+// recordDecoder reads the fields of a record, remembering the first error.
+type recordDecoder struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (d *recordDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.err = err
+	return v
+}
+
+func (d *recordDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d.r)
+	d.err = err
+	return v
+}
+
+func (d *recordDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	v, err := d.r.ReadByte()
+	d.err = err
+	return v
+}
+
+func (d *recordDecoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n > uint64(d.r.Len()) {
+		d.err = errCorruptRecord
+		return ""
+	}
+
+	buf := make([]byte, n)
+	_, d.err = io.ReadFull(d.r, buf)
+	return string(buf)
+}
+
+func (d *recordDecoder) float() float64 {
+	if d.err != nil {
+		return 0
+	}
+	var buf [8]byte
+	_, d.err = io.ReadFull(d.r, buf[:])
+	return math.Float64frombits(binary.BigEndian.Uint64(buf[:]))
+}
+
+// decodeRecord creates a metric from a record payload.
+func decodeRecord(payload []byte) (telegraf.Metric, error) {
+	d := &recordDecoder{r: bytes.NewReader(payload)}
+
+	name := d.string()
+	tm := time.Unix(0, d.varint())
+	tp := telegraf.ValueType(d.byte())
+	aggregate := d.byte() == 1
+
+	ntags := d.uvarint()
+	if ntags > uint64(len(payload)) {
+		return nil, errCorruptRecord
+	}
+	tags := make(map[string]string, ntags)
+	for i := uint64(0); i < ntags && d.err == nil; i++ {
+		key := d.string()
+		tags[key] = d.string()
+	}
+
+	nfields := d.uvarint()
+	if nfields > uint64(len(payload)) {
+		return nil, errCorruptRecord
+	}
+	fields := make(map[string]interface{}, nfields)
+	for i := uint64(0); i < nfields && d.err == nil; i++ {
+		key := d.string()
+		switch d.byte() {
+		case diskTypeFloat:
+			fields[key] = d.float()
+		case diskTypeInt:
+			fields[key] = d.varint()
+		case diskTypeUint:
+			fields[key] = d.uvarint()
+		case diskTypeString:
+			fields[key] = d.string()
+		case diskTypeBool:
+			fields[key] = d.byte() == 1
+		default:
+			if d.err == nil {
+				d.err = errCorruptRecord
+			}
+		}
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	m, err := metric.New(name, tags, fields, tm, tp)
+	if err != nil {
+		return nil, err
+	}
+	if aggregate {
+		m.SetAggregate(true)
+	}
+	return m, nil
+}
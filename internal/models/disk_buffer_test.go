@@ -0,0 +1,261 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDiskBuffer(t *testing.T, dir string, capacity int) *DiskBuffer {
+	b, err := NewDiskBuffer("test", dir, capacity)
+	require.NoError(t, err)
+	b.MetricsAdded.Set(0)
+	b.MetricsWritten.Set(0)
+	b.MetricsDropped.Set(0)
+	return b
+}
+
+func tempBufferDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "telegraf-buffer")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestDiskBuffer_LenEmpty(t *testing.T) {
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 5)
+	defer b.Close()
+
+	require.Equal(t, 0, b.Len())
+	require.Equal(t, int64(0), b.Bytes())
+}
+
+func TestDiskBuffer_LenOverfill(t *testing.T) {
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 5)
+	defer b.Close()
+
+	m := Metric()
+	b.Add(m, m, m, m, m, m)
+
+	require.Equal(t, 5, b.Len())
+	require.Equal(t, int64(1), b.MetricsDropped.Get())
+}
+
+func TestDiskBuffer_BatchOldest(t *testing.T) {
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 4)
+	defer b.Close()
+
+	b.Add(MetricTime(1))
+	b.Add(MetricTime(2))
+	b.Add(MetricTime(3))
+	b.Add(MetricTime(4))
+	b.Add(MetricTime(5))
+	batch := b.Batch(2)
+
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(2),
+			MetricTime(3),
+		}, batch)
+}
+
+func TestDiskBuffer_AcceptRemovesBatch(t *testing.T) {
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 5)
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	batch := b.Batch(2)
+	require.Equal(t, 1, b.Len())
+	b.Accept(batch)
+
+	require.Equal(t, 1, b.Len())
+	require.Equal(t, int64(2), b.MetricsWritten.Get())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+		}, b.Batch(2))
+}
+
+func TestDiskBuffer_RejectKeepsBatch(t *testing.T) {
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 5)
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	batch := b.Batch(2)
+	b.Reject(batch)
+
+	require.Equal(t, 3, b.Len())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(2),
+		}, b.Batch(2))
+}
+
+func TestDiskBuffer_RejectDropsOverfill(t *testing.T) {
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 3)
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	batch := b.Batch(2)
+	b.Add(MetricTime(4), MetricTime(5))
+	b.Reject(batch)
+
+	require.Equal(t, 3, b.Len())
+	require.Equal(t, int64(2), b.MetricsDropped.Get())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+			MetricTime(4),
+			MetricTime(5),
+		}, b.Batch(5))
+}
+
+func TestDiskBuffer_AddAcceptsMetric(t *testing.T) {
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 5)
+	defer b.Close()
+
+	var accept int
+	mm := &MockMetric{
+		Metric: Metric(),
+		AcceptF: func() {
+			accept++
+		},
+	}
+	b.Add(mm)
+
+	require.Equal(t, 1, accept)
+}
+
+func TestDiskBuffer_Reopen(t *testing.T) {
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 5)
+
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	b.Accept(b.Batch(1))
+	b.Reject(b.Batch(1))
+	require.NoError(t, b.Close())
+
+	b = newTestDiskBuffer(t, dir, 5)
+	defer b.Close()
+
+	require.Equal(t, 2, b.Len())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(2),
+			MetricTime(3),
+		}, b.Batch(5))
+}
+
+func TestDiskBuffer_ReopenBeforeClose(t *testing.T) {
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 5)
+
+	b.Add(MetricTime(1))
+	next := newTestDiskBuffer(t, dir, 5)
+	defer next.Close()
+
+	b.Add(MetricTime(2))
+	require.NoError(t, b.Close())
+
+	require.Equal(t, 2, next.Len())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(2),
+		}, next.Batch(5))
+}
+
+func TestDiskBuffer_ReopenTruncatesPartialRecord(t *testing.T) {
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 5)
+
+	b.Add(MetricTime(1), MetricTime(2))
+	require.NoError(t, b.Close())
+
+	path := filepath.Join(dir, "00000000000000000000.seg")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-1))
+
+	b = newTestDiskBuffer(t, dir, 5)
+	defer b.Close()
+
+	require.Equal(t, 1, b.Len())
+	b.Add(MetricTime(3))
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(3),
+		}, b.Batch(5))
+}
+
+func TestDiskBuffer_RemovesWrittenSegments(t *testing.T) {
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 5)
+	defer b.Close()
+
+	b.segmentSize = 1
+	b.Add(MetricTime(1))
+	b.Add(MetricTime(2))
+	require.Len(t, b.segments, 2)
+
+	b.Accept(b.Batch(1))
+	require.Len(t, b.segments, 1)
+
+	_, err := os.Stat(filepath.Join(dir, "00000000000000000000.seg"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestDiskBuffer_RecordRoundTrip(t *testing.T) {
+	m, err := metric.New(
+		"cpu",
+		map[string]string{
+			"host": "localhost",
+		},
+		map[string]interface{}{
+			"float":  42.0,
+			"int":    int64(-42),
+			"uint":   uint64(42),
+			"string": "foo",
+			"bool":   true,
+		},
+		time.Unix(42, 42),
+		telegraf.Counter,
+	)
+	require.NoError(t, err)
+
+	dir := tempBufferDir(t)
+	defer os.RemoveAll(dir)
+	b := newTestDiskBuffer(t, dir, 5)
+	defer b.Close()
+
+	b.Add(m)
+	batch := b.Batch(1)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{m}, batch)
+	require.Equal(t, telegraf.Counter, batch[0].Type())
+}
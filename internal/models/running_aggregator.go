@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/selfstat"
 )
 
@@ -15,38 +16,51 @@ type RunningAggregator struct {
 	periodStart time.Time
 	periodEnd   time.Time
 
+	// derivation holds the tracking of the metrics added during the period,
+	// the aggregates pushed at the end of the period inherit it.
+	derivation *metric.Derivation
+
 	MetricsPushed   selfstat.Stat
 	MetricsFiltered selfstat.Stat
 	MetricsDropped  selfstat.Stat
 	PushTime        selfstat.Stat
+
+	// Checksum of the configuration table, used when reloading.
+	Checksum string
 }
 
 func NewRunningAggregator(
 	aggregator telegraf.Aggregator,
 	config *AggregatorConfig,
 ) *RunningAggregator {
+	tags := map[string]string{"aggregator": config.Name}
+	if config.Pipeline != "" {
+		tags["pipeline"] = config.Pipeline
+	}
+
 	return &RunningAggregator{
 		Aggregator: aggregator,
 		Config:     config,
+		derivation: metric.NewDerivation(),
 		MetricsPushed: selfstat.Register(
 			"aggregate",
 			"metrics_pushed",
-			map[string]string{"aggregator": config.Name},
+			tags,
 		),
 		MetricsFiltered: selfstat.Register(
 			"aggregate",
 			"metrics_filtered",
-			map[string]string{"aggregator": config.Name},
+			tags,
 		),
 		MetricsDropped: selfstat.Register(
 			"aggregate",
 			"metrics_dropped",
-			map[string]string{"aggregator": config.Name},
+			tags,
 		),
 		PushTime: selfstat.Register(
 			"aggregate",
 			"push_time_ns",
-			map[string]string{"aggregator": config.Name},
+			tags,
 		),
 	}
 }
@@ -58,6 +72,10 @@ type AggregatorConfig struct {
 	Period       time.Duration
 	Delay        time.Duration
 
+	// Pipeline is the name of the pipeline of the aggregator, it is empty in
+	// the main configuration.
+	Pipeline string
+
 	NameOverride      string
 	MeasurementPrefix string
 	MeasurementSuffix string
@@ -78,9 +96,9 @@ func (r *RunningAggregator) SetPeriodStart(start time.Time) {
 	r.periodEnd = r.periodStart.Add(r.Config.Period).Add(r.Config.Delay)
 }
 
-func (r *RunningAggregator) MakeMetric(metric telegraf.Metric) telegraf.Metric {
-	m := makemetric(
-		metric,
+func (r *RunningAggregator) MakeMetric(m telegraf.Metric) telegraf.Metric {
+	m = makemetric(
+		m,
 		r.Config.NameOverride,
 		r.Config.MeasurementPrefix,
 		r.Config.MeasurementSuffix,
@@ -89,6 +107,7 @@ func (r *RunningAggregator) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 
 	if m != nil {
 		m.SetAggregate(true)
+		m = r.derivation.Track(m)
 	}
 
 	r.MetricsPushed.Incr(1)
@@ -96,39 +115,44 @@ func (r *RunningAggregator) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 	return m
 }
 
-func (r *RunningAggregator) metricFiltered(metric telegraf.Metric) {
+func (r *RunningAggregator) metricFiltered(m telegraf.Metric) {
 	r.MetricsFiltered.Incr(1)
-	metric.Accept()
+	m.Accept()
 }
 
-func (r *RunningAggregator) metricDropped(metric telegraf.Metric) {
+func (r *RunningAggregator) metricDropped(m telegraf.Metric) {
 	r.MetricsDropped.Incr(1)
-	metric.Accept()
+	m.Accept()
 }
 
 // Add a metric to the aggregator and return true if the original metric
-// should be dropped.
-func (r *RunningAggregator) Add(metric telegraf.Metric) bool {
-	if ok := r.Config.Filter.Select(metric); !ok {
+// should be dropped.  The caller remains responsible for the delivery of the
+// original metric, the aggregator holds its own reference until the
+// aggregates of the period are delivered.
+func (r *RunningAggregator) Add(m telegraf.Metric) bool {
+	if ok := r.Config.Filter.Select(m); !ok {
 		return false
 	}
 
-	metric = metric.Copy()
+	m = m.Copy()
 
-	r.Config.Filter.Modify(metric)
-	if len(metric.FieldList()) == 0 {
+	r.Config.Filter.Modify(m)
+	if len(m.FieldList()) == 0 {
+		m.Drop()
 		return r.Config.DropOriginal
 	}
 
 	r.Lock()
 	defer r.Unlock()
 
-	if r.periodStart.IsZero() || metric.Time().After(r.periodEnd) {
-		r.metricDropped(metric)
+	if r.periodStart.IsZero() || m.Time().After(r.periodEnd) {
+		r.metricDropped(m)
 		return r.Config.DropOriginal
 	}
 
-	r.Aggregator.Add(metric)
+	r.Aggregator.Add(m)
+	r.derivation.Add(m)
+	m.Drop()
 	return r.Config.DropOriginal
 }
 
@@ -140,6 +164,9 @@ func (r *RunningAggregator) Push(acc telegraf.Accumulator) {
 	r.periodEnd = r.periodStart.Add(r.Config.Period).Add(r.Config.Delay)
 	r.push(acc)
 	r.Aggregator.Reset()
+
+	r.derivation.Done()
+	r.derivation = metric.NewDerivation()
 }
 
 func (r *RunningAggregator) push(acc telegraf.Accumulator) {
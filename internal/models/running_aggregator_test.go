@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/require"
 )
@@ -201,3 +202,61 @@ func (t *TestAggregator) Add(in telegraf.Metric) {
 		}
 	}
 }
+
+func TestAggregateTracking(t *testing.T) {
+	for _, reject := range []bool{false, true} {
+		a := &TestAggregator{}
+		ra := NewRunningAggregator(a, &AggregatorConfig{
+			Name:         "TestRunningAggregator",
+			DropOriginal: true,
+			Period:       time.Millisecond * 500,
+		})
+		require.NoError(t, ra.Config.Filter.Compile())
+		ra.SetPeriodStart(time.Now())
+
+		var delivered []telegraf.DeliveryInfo
+		m, _ := metric.WithTracking(testutil.MustMetric("RITest",
+			map[string]string{},
+			map[string]interface{}{
+				"value": int64(101),
+			},
+			time.Now().Add(time.Millisecond*150)),
+			func(info telegraf.DeliveryInfo) {
+				delivered = append(delivered, info)
+			})
+		require.True(t, ra.Add(m))
+		m.Drop()
+		require.Len(t, delivered, 0)
+
+		acc := &makerAccumulator{maker: ra}
+		ra.Push(acc)
+		require.Len(t, acc.metrics, 1)
+		require.Len(t, delivered, 0)
+
+		if reject {
+			acc.metrics[0].Reject()
+		} else {
+			acc.metrics[0].Accept()
+		}
+		require.Len(t, delivered, 1)
+		require.Equal(t, !reject, delivered[0].Delivered())
+	}
+}
+
+// makerAccumulator passes the metrics through MakeMetric, as the agent
+// accumulator does.
+type makerAccumulator struct {
+	testutil.Accumulator
+	maker   *RunningAggregator
+	metrics []telegraf.Metric
+}
+
+func (a *makerAccumulator) AddFields(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	m, _ := metric.New(measurement, tags, fields, time.Now())
+	a.metrics = append(a.metrics, a.maker.MakeMetric(m))
+}
@@ -1,9 +1,11 @@
 package models
 
 import (
+	"context"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/cron"
 	"github.com/influxdata/telegraf/selfstat"
 )
 
@@ -13,25 +15,40 @@ type RunningInput struct {
 	Input  telegraf.Input
 	Config *InputConfig
 
+	// Checksum of the plugin's configuration table, used to detect changed
+	// plugins when the configuration is reloaded.
+	Checksum string
+
 	defaultTags map[string]string
 
 	MetricsGathered selfstat.Stat
 	GatherTime      selfstat.Stat
+	GatherTimeouts  selfstat.Stat
 }
 
 func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
+	tags := map[string]string{"input": config.Name}
+	if config.Pipeline != "" {
+		tags["pipeline"] = config.Pipeline
+	}
+
 	return &RunningInput{
 		Input:  input,
 		Config: config,
 		MetricsGathered: selfstat.Register(
 			"gather",
 			"metrics_gathered",
-			map[string]string{"input": config.Name},
+			tags,
 		),
 		GatherTime: selfstat.RegisterTiming(
 			"gather",
 			"gather_time_ns",
-			map[string]string{"input": config.Name},
+			tags,
+		),
+		GatherTimeouts: selfstat.Register(
+			"gather",
+			"gather_timeouts",
+			tags,
 		),
 	}
 }
@@ -41,6 +58,18 @@ type InputConfig struct {
 	Name     string
 	Interval time.Duration
 
+	// Pipeline is the name of the pipeline of the input, it is empty in the
+	// main configuration.
+	Pipeline string
+
+	// Schedule runs the gather at the times of a cron schedule instead of
+	// every interval.
+	Schedule *cron.Schedule
+
+	// GatherTimeout is the longest a gather may take before it is abandoned,
+	// there is no limit when zero.
+	GatherTimeout time.Duration
+
 	NameOverride      string
 	MeasurementPrefix string
 	MeasurementSuffix string
@@ -82,8 +111,19 @@ func (r *RunningInput) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 }
 
 func (r *RunningInput) Gather(acc telegraf.Accumulator) error {
+	return r.GatherContext(context.Background(), acc)
+}
+
+// GatherContext gathers the input, passing the context to inputs
+// implementing telegraf.ContextInput.
+func (r *RunningInput) GatherContext(ctx context.Context, acc telegraf.Accumulator) error {
 	start := time.Now()
-	err := r.Input.Gather(acc)
+	var err error
+	if input, ok := r.Input.(telegraf.ContextInput); ok {
+		err = input.GatherContext(ctx, acc)
+	} else {
+		err = r.Input.Gather(acc)
+	}
 	elapsed := time.Since(start)
 	r.GatherTime.Incr(elapsed.Nanoseconds())
 	return err
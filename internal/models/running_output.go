@@ -1,11 +1,15 @@
 package models
 
 import (
+	"context"
+	"io"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/selfstat"
 )
 
@@ -15,6 +19,16 @@ const (
 
 	// Default number of metrics kept. It should be a multiple of batch size.
 	DEFAULT_METRIC_BUFFER_LIMIT = 10000
+
+	// Default maximum delay between write attempts of a failing output.
+	DEFAULT_RETRY_MAX_INTERVAL = 5 * time.Minute
+)
+
+// Circuit states reported in the circuit_state field.
+const (
+	CircuitClosed   = 0
+	CircuitOpen     = 1
+	CircuitHalfOpen = 2
 )
 
 // OutputConfig containing name and filter
@@ -22,30 +36,90 @@ type OutputConfig struct {
 	Name   string
 	Filter Filter
 
+	// Pipeline is the name of the pipeline of the output, it is empty in the
+	// main configuration.
+	Pipeline string
+
 	FlushInterval     time.Duration
 	MetricBufferLimit int
 	MetricBatchSize   int
+
+	// BufferDirectory enables the disk buffer when set.
+	BufferDirectory string
+
+	// MetricBufferLimitBytes limits the estimated memory used by the metrics
+	// in the buffer, there is no limit when zero.  It does not apply to the
+	// disk buffer.
+	MetricBufferLimitBytes int64
+
+	// BufferDropPolicy selects the metrics dropped when the buffer is full,
+	// one of DropOldest, the default, DropNewest or DropBlock.
+	BufferDropPolicy string
+
+	// RetryInterval is the delay before the first retry once writes are
+	// suspended, the delay doubles on each failure up to RetryMaxInterval.
+	// Writes are never suspended when zero.
+	RetryInterval    time.Duration
+	RetryMaxInterval time.Duration
+
+	// RetryFailureThreshold is the number of consecutive failed writes
+	// before writes are suspended and the output is considered unhealthy.
+	RetryFailureThreshold int
+
+	// FailoverGroup is the name of the failover group of the output.  Only
+	// the first healthy output of a group receives metrics.
+	FailoverGroup string
 }
 
 // RunningOutput contains the output configuration
 type RunningOutput struct {
+	// lastWrite is the time of the last successful write in unix
+	// nanoseconds, it is first to be aligned for atomic access.
+	lastWrite int64
+
 	Name              string
 	Output            telegraf.Output
 	Config            *OutputConfig
 	MetricBufferLimit int
 	MetricBatchSize   int
 
+	// Checksum of the configuration table, used when reloading.
+	Checksum string
+
 	MetricsFiltered selfstat.Stat
 	BufferSize      selfstat.Stat
 	BufferLimit     selfstat.Stat
 	WriteTime       selfstat.Stat
 
+	CircuitState        selfstat.Stat
+	ConsecutiveFailures selfstat.Stat
+
 	batch      []telegraf.Metric
-	buffer     *Buffer
+	batchBytes int64 // estimated memory used by the batch, if limited
+	buffer     metricBuffer
 	BatchReady chan time.Time
 
+	// space is signaled after writes, and closed is closed when the output
+	// is closed, to wake callers of WaitBuffer.
+	space     chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// Circuit breaker state, guarded by the writeMutex.
+	failures   int
+	circuit    int
+	retryAfter time.Time
+
+	// Set to 1 while the output is unhealthy, read without the writeMutex.
+	unhealthy int32
+
+	// Set to 1 while the last attempt to connect failed, the output is then
+	// not closed.
+	disconnected int32
+
 	aggMutex   sync.Mutex
 	batchMutex sync.Mutex
+	writeMutex sync.Mutex
 }
 
 func NewRunningOutput(
@@ -67,11 +141,18 @@ func NewRunningOutput(
 	if batchSize == 0 {
 		batchSize = DEFAULT_METRIC_BATCH_SIZE
 	}
+	tags := map[string]string{"output": name}
+	if conf.Pipeline != "" {
+		tags["pipeline"] = conf.Pipeline
+	}
+
 	ro := &RunningOutput{
 		Name:              name,
 		batch:             make([]telegraf.Metric, 0, batchSize),
-		buffer:            NewBuffer(name, bufferLimit),
+		buffer:            newBuffer(name, tags, conf, bufferLimit),
 		BatchReady:        make(chan time.Time, 1),
+		space:             make(chan struct{}, 1),
+		closed:            make(chan struct{}),
 		Output:            output,
 		Config:            conf,
 		MetricBufferLimit: bufferLimit,
@@ -79,29 +160,59 @@ func NewRunningOutput(
 		MetricsFiltered: selfstat.Register(
 			"write",
 			"metrics_filtered",
-			map[string]string{"output": name},
+			tags,
 		),
 		BufferSize: selfstat.Register(
 			"write",
 			"buffer_size",
-			map[string]string{"output": name},
+			tags,
 		),
 		BufferLimit: selfstat.Register(
 			"write",
 			"buffer_limit",
-			map[string]string{"output": name},
+			tags,
 		),
 		WriteTime: selfstat.RegisterTiming(
 			"write",
 			"write_time_ns",
-			map[string]string{"output": name},
+			tags,
+		),
+		CircuitState: selfstat.Register(
+			"write",
+			"circuit_state",
+			tags,
+		),
+		ConsecutiveFailures: selfstat.Register(
+			"write",
+			"consecutive_failures",
+			tags,
 		),
 	}
 
 	ro.BufferLimit.Set(int64(ro.MetricBufferLimit))
+	ro.lastWrite = time.Now().UnixNano()
 	return ro
 }
 
+// newBuffer returns a DiskBuffer if a directory is set, otherwise or if the
+// directory cannot be used, an in memory Buffer.
+func newBuffer(name string, tags map[string]string, conf *OutputConfig, capacity int) metricBuffer {
+	if dir := conf.BufferDirectory; dir != "" {
+		buffer, err := newDiskBuffer(name, tags, dir, capacity)
+		if err == nil {
+			buffer.SetDropPolicy(conf.BufferDropPolicy)
+			return buffer
+		}
+		log.Printf("E! [outputs.%s] Unable to use buffer directory %s, "+
+			"falling back to memory buffer: %v", name, dir, err)
+	}
+
+	buffer := newMemoryBuffer(tags, capacity)
+	buffer.SetLimitBytes(conf.MetricBufferLimitBytes)
+	buffer.SetDropPolicy(conf.BufferDropPolicy)
+	return buffer
+}
+
 func (ro *RunningOutput) metricFiltered(metric telegraf.Metric) {
 	ro.MetricsFiltered.Incr(1)
 	metric.Drop()
@@ -132,6 +243,9 @@ func (ro *RunningOutput) AddMetric(metric telegraf.Metric) {
 	ro.batchMutex.Lock()
 
 	ro.batch = append(ro.batch, metric)
+	if ro.Config.MetricBufferLimitBytes > 0 {
+		ro.batchBytes += metricSize(metric)
+	}
 	if len(ro.batch) == ro.MetricBatchSize {
 		ro.addBatchToBuffer()
 
@@ -151,11 +265,73 @@ func (ro *RunningOutput) AddMetric(metric telegraf.Metric) {
 func (ro *RunningOutput) addBatchToBuffer() {
 	ro.buffer.Add(ro.batch...)
 	ro.batch = ro.batch[:0]
+	ro.batchBytes = 0
+}
+
+// WaitBuffer blocks while the buffer of an output using the DropBlock policy
+// is full, until metrics are written, the output is closed or the context is
+// done.  Waiting before AddMetric applies backpressure to the inputs instead
+// of dropping metrics.
+func (ro *RunningOutput) WaitBuffer(ctx context.Context) {
+	if ro.Config.BufferDropPolicy != DropBlock {
+		return
+	}
+
+	for ro.full() {
+		select {
+		case <-ro.space:
+		case <-ro.closed:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// full returns true if the buffer has no room for more metrics, counting
+// the metrics in the batch.
+func (ro *RunningOutput) full() bool {
+	ro.batchMutex.Lock()
+	defer ro.batchMutex.Unlock()
+
+	if ro.buffer.Len()+len(ro.batch) >= ro.MetricBufferLimit {
+		return true
+	}
+	limit := ro.Config.MetricBufferLimitBytes
+	if buffer, ok := ro.buffer.(*Buffer); ok && limit > 0 {
+		return buffer.Bytes()+ro.batchBytes >= limit
+	}
+	return false
+}
+
+// notifySpace wakes a caller of WaitBuffer to check the buffer again.
+func (ro *RunningOutput) notifySpace() {
+	select {
+	case ro.space <- struct{}{}:
+	default:
+	}
 }
 
 // Write writes all metrics to the output, stopping when all have been sent on
 // or error.
 func (ro *RunningOutput) Write() error {
+	return ro.writeAll(false)
+}
+
+// WriteFinal writes all metrics to the output like Write, but attempts the
+// write even while the circuit is open.  It is used for the last flush before
+// the output is closed, when no retry will follow.
+func (ro *RunningOutput) WriteFinal() error {
+	return ro.writeAll(true)
+}
+
+func (ro *RunningOutput) writeAll(force bool) error {
+	// Writes may be requested outside of the flush interval, the buffer only
+	// supports one batch at a time.
+	ro.writeMutex.Lock()
+	defer ro.writeMutex.Unlock()
+	defer ro.notifySpace()
+
 	if output, ok := ro.Output.(telegraf.AggregatingOutput); ok {
 		ro.aggMutex.Lock()
 		metrics := output.Push()
@@ -169,6 +345,10 @@ func (ro *RunningOutput) Write() error {
 	ro.batchMutex.Unlock()
 
 	nBuffer := ro.buffer.Len()
+	if !force && !ro.allowWrite() {
+		ro.BufferSize.Set(int64(nBuffer))
+		return nil
+	}
 
 	// Only process the metrics in the buffer now.  Metrics added while we are
 	// writing will be sent on the next call.
@@ -186,11 +366,20 @@ func (ro *RunningOutput) Write() error {
 		}
 		ro.buffer.Accept(batch)
 	}
+	ro.BufferSize.Set(int64(ro.buffer.Len()))
 	return nil
 }
 
 // WriteBatch writes only the batch metrics to the output.
 func (ro *RunningOutput) WriteBatch() error {
+	ro.writeMutex.Lock()
+	defer ro.writeMutex.Unlock()
+	defer ro.notifySpace()
+
+	if !ro.allowWrite() {
+		return nil
+	}
+
 	batch := ro.buffer.Batch(ro.MetricBatchSize)
 	if len(batch) == 0 {
 		return nil
@@ -202,23 +391,157 @@ func (ro *RunningOutput) WriteBatch() error {
 		return err
 	}
 	ro.buffer.Accept(batch)
+	ro.BufferSize.Set(int64(ro.buffer.Len()))
+
+	return nil
+}
 
+// Connect connects the output.
+func (ro *RunningOutput) Connect() error {
+	if err := ro.Output.Connect(); err != nil {
+		atomic.StoreInt32(&ro.disconnected, 1)
+		return err
+	}
+	atomic.StoreInt32(&ro.disconnected, 0)
 	return nil
 }
 
+// Close closes the output and releases the buffer.  Metrics remaining in a
+// disk buffer are kept for the next run.  An output which failed to connect
+// is not closed.
+func (ro *RunningOutput) Close() error {
+	ro.closeOnce.Do(func() { close(ro.closed) })
+
+	var err error
+	if atomic.LoadInt32(&ro.disconnected) == 0 {
+		err = ro.Output.Close()
+	}
+	if closer, ok := ro.buffer.(io.Closer); ok {
+		if cerr := closer.Close(); cerr != nil {
+			log.Printf("E! [outputs.%s] Error closing buffer: %v", ro.Name, cerr)
+		}
+	}
+	return err
+}
+
 func (ro *RunningOutput) write(metrics []telegraf.Metric) error {
 	start := time.Now()
 	err := ro.Output.Write(metrics)
 	elapsed := time.Since(start)
 	ro.WriteTime.Incr(elapsed.Nanoseconds())
+	ro.updateCircuit(err)
 
 	if err == nil {
+		atomic.StoreInt64(&ro.lastWrite, time.Now().UnixNano())
 		log.Printf("D! [outputs.%s] wrote batch of %d metrics in %s\n",
 			ro.Name, len(metrics), elapsed)
 	}
 	return err
 }
 
+// allowWrite returns true if a write may be attempted.  Once the retry delay
+// of an open circuit has passed, it becomes half-open and a single write is
+// attempted.
+func (ro *RunningOutput) allowWrite() bool {
+	if ro.circuit != CircuitOpen {
+		return true
+	}
+	if time.Now().Before(ro.retryAfter) {
+		log.Printf("D! [outputs.%s] writes suspended until %s",
+			ro.Name, ro.retryAfter.Format(time.RFC3339))
+		return false
+	}
+
+	ro.setCircuit(CircuitHalfOpen)
+	return true
+}
+
+// updateCircuit records the result of a write, suspending writes after too
+// many consecutive failures.
+func (ro *RunningOutput) updateCircuit(err error) {
+	if err == nil {
+		if ro.circuit != CircuitClosed {
+			log.Printf("I! [outputs.%s] Resuming writes after %d failures",
+				ro.Name, ro.failures)
+		}
+		ro.failures = 0
+		ro.ConsecutiveFailures.Set(0)
+		ro.setCircuit(CircuitClosed)
+		atomic.StoreInt32(&ro.unhealthy, 0)
+		return
+	}
+
+	ro.failures++
+	ro.ConsecutiveFailures.Set(int64(ro.failures))
+
+	threshold := ro.Config.RetryFailureThreshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+	if ro.failures < threshold {
+		return
+	}
+
+	atomic.StoreInt32(&ro.unhealthy, 1)
+	if ro.Config.RetryInterval <= 0 {
+		return
+	}
+
+	delay := ro.retryDelay(ro.failures - threshold)
+	ro.retryAfter = time.Now().Add(delay)
+	ro.setCircuit(CircuitOpen)
+	log.Printf("W! [outputs.%s] Suspending writes for %s after %d consecutive failures",
+		ro.Name, delay, ro.failures)
+}
+
+// retryDelay returns the exponential backoff delay for the given number of
+// retries.  The delay is randomized between half and the full value so that
+// agents that failed together do not retry together.
+func (ro *RunningOutput) retryDelay(retries int) time.Duration {
+	max := ro.Config.RetryMaxInterval
+	if max <= 0 {
+		max = DEFAULT_RETRY_MAX_INTERVAL
+	}
+
+	delay := ro.Config.RetryInterval
+	for i := 0; i < retries && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay/2 + internal.RandomDuration(delay/2)
+}
+
+// Healthy returns false once RetryFailureThreshold writes have failed in a
+// row, until the next successful write.
+func (ro *RunningOutput) Healthy() bool {
+	return atomic.LoadInt32(&ro.unhealthy) == 0
+}
+
+func (ro *RunningOutput) setCircuit(state int) {
+	ro.circuit = state
+	ro.CircuitState.Set(int64(state))
+}
+
+// BufferLength returns the number of metrics waiting to be written,
+// including metrics not yet added to the buffer as a batch.
+func (ro *RunningOutput) BufferLength() int {
+	ro.batchMutex.Lock()
+	defer ro.batchMutex.Unlock()
+	return ro.buffer.Len() + len(ro.batch)
+}
+
+// Status returns the current state of the output.
+func (ro *RunningOutput) Status() telegraf.OutputStatus {
+	return telegraf.OutputStatus{
+		Name:        ro.Name,
+		BufferSize:  ro.BufferLength(),
+		BufferLimit: ro.MetricBufferLimit,
+		LastWrite:   time.Unix(0, atomic.LoadInt64(&ro.lastWrite)),
+	}
+}
+
 func (ro *RunningOutput) LogBufferStatus() {
 	nBuffer := ro.buffer.Len()
 	log.Printf("D! [outputs.%s] buffer fullness: %d / %d metrics. ",
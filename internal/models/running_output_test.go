@@ -1,9 +1,13 @@
 package models
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/testutil"
@@ -412,6 +416,239 @@ func TestRunningOutputWriteFailOrder3(t *testing.T) {
 	assert.Equal(t, expected, m.Metrics())
 }
 
+// Verify that metrics in a disk buffer are written after the output restarts.
+func TestRunningOutputDiskBufferRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-buffer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	conf := &OutputConfig{
+		Filter:          Filter{},
+		BufferDirectory: dir,
+	}
+
+	m := &mockOutput{}
+	m.failWrite = true
+	ro := NewRunningOutput("test", m, conf, 1000, 10000)
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	err = ro.Write()
+	require.Error(t, err)
+	require.NoError(t, ro.Close())
+
+	m = &mockOutput{}
+	ro = NewRunningOutput("test", m, conf, 1000, 10000)
+	defer ro.Close()
+
+	err = ro.Write()
+	require.NoError(t, err)
+
+	testutil.RequireMetricsEqual(t, first5, m.Metrics())
+}
+
+func TestRunningOutputCircuitBreaker(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:                Filter{},
+		RetryInterval:         time.Hour,
+		RetryFailureThreshold: 2,
+	}
+
+	m := &mockOutput{}
+	m.failWrite = true
+	ro := NewRunningOutput("test", m, conf, 1000, 10000)
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	// Writes continue until the threshold is reached.
+	require.Error(t, ro.Write())
+	require.Equal(t, int64(CircuitClosed), ro.CircuitState.Get())
+	require.Equal(t, int64(1), ro.ConsecutiveFailures.Get())
+
+	require.Error(t, ro.Write())
+	require.Equal(t, int64(CircuitOpen), ro.CircuitState.Get())
+	require.Equal(t, int64(2), ro.ConsecutiveFailures.Get())
+
+	// Writes are skipped while the circuit is open.
+	m.failWrite = false
+	require.NoError(t, ro.Write())
+	require.NoError(t, ro.WriteBatch())
+	require.Len(t, m.Metrics(), 0)
+	require.Equal(t, 5, ro.BufferLength())
+
+	// Once the delay has passed a write is attempted.
+	ro.retryAfter = time.Now()
+	require.NoError(t, ro.Write())
+	require.Len(t, m.Metrics(), 5)
+	require.Equal(t, int64(CircuitClosed), ro.CircuitState.Get())
+	require.Equal(t, int64(0), ro.ConsecutiveFailures.Get())
+}
+
+func TestRunningOutputCircuitBreakerWriteFinal(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:        Filter{},
+		RetryInterval: time.Hour,
+	}
+
+	m := &mockOutput{}
+	m.failWrite = true
+	ro := NewRunningOutput("test", m, conf, 1000, 10000)
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	require.Error(t, ro.Write())
+	require.Equal(t, int64(CircuitOpen), ro.CircuitState.Get())
+
+	// The final write ignores the open circuit.
+	m.failWrite = false
+	require.NoError(t, ro.Write())
+	require.Len(t, m.Metrics(), 0)
+	require.NoError(t, ro.WriteFinal())
+	require.Len(t, m.Metrics(), 5)
+	require.Equal(t, 0, ro.BufferLength())
+}
+
+func TestRunningOutputPipelineTags(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:   Filter{},
+		Pipeline: "other",
+	}
+
+	ro := NewRunningOutput("test", &mockOutput{}, conf, 1000, 10000)
+	tags := map[string]string{"output": "test", "pipeline": "other"}
+	require.Equal(t, tags, ro.BufferSize.Tags())
+	require.Equal(t, tags, ro.buffer.(*Buffer).MetricsAdded.Tags())
+
+	ro = NewRunningOutput("test", &mockOutput{}, &OutputConfig{}, 1000, 10000)
+	require.Equal(t, map[string]string{"output": "test"}, ro.BufferSize.Tags())
+}
+
+func TestRunningOutputCircuitBreakerDisabled(t *testing.T) {
+	conf := &OutputConfig{
+		Filter: Filter{},
+	}
+
+	m := &mockOutput{}
+	m.failWrite = true
+	ro := NewRunningOutput("test", m, conf, 1000, 10000)
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	for i := 0; i < 3; i++ {
+		require.Error(t, ro.Write())
+	}
+	require.Equal(t, int64(CircuitClosed), ro.CircuitState.Get())
+	require.Equal(t, int64(3), ro.ConsecutiveFailures.Get())
+}
+
+func TestRunningOutputRetryDelay(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:           Filter{},
+		RetryInterval:    10 * time.Second,
+		RetryMaxInterval: time.Minute,
+	}
+	ro := NewRunningOutput("test", &mockOutput{}, conf, 1000, 10000)
+
+	tests := []struct {
+		retries int
+		max     time.Duration
+	}{
+		{0, 10 * time.Second},
+		{1, 20 * time.Second},
+		{2, 40 * time.Second},
+		{3, time.Minute},
+		{100, time.Minute},
+	}
+	for _, tt := range tests {
+		delay := ro.retryDelay(tt.retries)
+		require.True(t, delay >= tt.max/2 && delay <= tt.max,
+			"retries %d: delay %s not within %s", tt.retries, delay, tt.max)
+	}
+}
+
+func TestRunningOutputDropBlock(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:           Filter{},
+		BufferDropPolicy: DropBlock,
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput("test", m, conf, 1000, 5)
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ro.WaitBuffer(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("WaitBuffer returned while the buffer is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, ro.Write())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitBuffer did not return after the buffer was written")
+	}
+	require.Len(t, m.Metrics(), 5)
+}
+
+func TestRunningOutputDropBlockLimitBytes(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:                 Filter{},
+		BufferDropPolicy:       DropBlock,
+		MetricBufferLimitBytes: 2 * metricSize(first5[0]),
+	}
+
+	ro := NewRunningOutput("test", &mockOutput{}, conf, 1000, 10000)
+	ro.AddMetric(first5[0])
+	require.False(t, ro.full())
+	ro.AddMetric(first5[1])
+	require.True(t, ro.full())
+
+	// Waiting stops once the context is done or the output is closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ro.WaitBuffer(ctx)
+
+	ro.Close()
+	ro.WaitBuffer(context.Background())
+}
+
+func TestRunningOutputDropNewest(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:           Filter{},
+		BufferDropPolicy: DropNewest,
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput("test", m, conf, 5, 5)
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	for _, metric := range next5 {
+		ro.AddMetric(metric)
+	}
+	ro.WaitBuffer(context.Background())
+
+	require.NoError(t, ro.Write())
+	testutil.RequireMetricsEqual(t, reverse(first5), m.Metrics())
+}
+
 type mockOutput struct {
 	sync.Mutex
 
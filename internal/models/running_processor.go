@@ -12,6 +12,9 @@ type RunningProcessor struct {
 	sync.Mutex
 	Processor telegraf.Processor
 	Config    *ProcessorConfig
+
+	// Checksum of the configuration table, used when reloading.
+	Checksum string
 }
 
 type RunningProcessors []*RunningProcessor
@@ -20,13 +23,38 @@ func (rp RunningProcessors) Len() int           { return len(rp) }
 func (rp RunningProcessors) Swap(i, j int)      { rp[i], rp[j] = rp[j], rp[i] }
 func (rp RunningProcessors) Less(i, j int) bool { return rp[i].Config.Order < rp[j].Config.Order }
 
+// Processor stages select the metrics a processor is applied to.
+const (
+	// StageDefault processors are applied to the metrics of inputs, before
+	// they are added to the aggregators, and to the metrics of aggregators.
+	StageDefault = ""
+	// StageInputs processors are only applied to the metrics of inputs.
+	StageInputs = "inputs"
+	// StageAggregators processors are only applied to the metrics of
+	// aggregators.
+	StageAggregators = "aggregators"
+	// StageOutputs processors are applied to all metrics sent to the
+	// outputs, after the aggregators.
+	StageOutputs = "outputs"
+)
+
 // FilterConfig containing a name and filter
 type ProcessorConfig struct {
 	Name   string
 	Order  int64
+	Stage  string
 	Filter Filter
 }
 
+// InStage returns true if the processor is applied in the stage, which is one
+// of StageInputs, StageAggregators or StageOutputs.
+func (rp *RunningProcessor) InStage(stage string) bool {
+	if rp.Config.Stage == StageDefault {
+		return stage == StageInputs || stage == StageAggregators
+	}
+	return rp.Config.Stage == stage
+}
+
 func (rp *RunningProcessor) metricFiltered(metric telegraf.Metric) {
 	metric.Drop()
 }
@@ -67,3 +95,29 @@ func (rp *RunningProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
 
 	return ret
 }
+
+// Flush returns the metrics output by the service of the processor since the
+// last call to Apply or Flush, if it has one.
+func (rp *RunningProcessor) Flush() []telegraf.Metric {
+	sp, ok := rp.Processor.(telegraf.ServiceProcessor)
+	if !ok {
+		return nil
+	}
+
+	rp.Lock()
+	defer rp.Unlock()
+	return sp.Flush()
+}
+
+// Stop stops the service of the processor, if it has one, and returns the
+// metrics it output until it stopped.
+func (rp *RunningProcessor) Stop() []telegraf.Metric {
+	sp, ok := rp.Processor.(telegraf.ServiceProcessor)
+	if !ok {
+		return nil
+	}
+
+	rp.Lock()
+	defer rp.Unlock()
+	return sp.Stop()
+}
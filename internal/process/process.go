@@ -0,0 +1,329 @@
+// Package process runs a long-running external command, restarting it with a
+// backoff whenever it exits.
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+)
+
+const (
+	// maxRestartDelay caps the doubling of the restart delay, a process
+	// running for this long is restarted with the initial delay again.
+	maxRestartDelay = 5 * time.Minute
+
+	// stopTimeout is how long a process has to exit after being asked to
+	// stop, before it is killed.
+	stopTimeout = 5 * time.Second
+)
+
+// Process is a command which is kept running until Stop is called.
+type Process struct {
+	// Log receives the messages about the command, such as its restarts.
+	Log telegraf.Logger
+
+	// ReadStdout and ReadStderr are called with the output of each run of the
+	// command, the remaining output is discarded when they return.
+	ReadStdout func(io.Reader)
+	ReadStderr func(io.Reader)
+
+	// RestartDelay is the delay before the first restart, it is doubled for
+	// every restart of a process which exits quickly.
+	RestartDelay time.Duration
+
+	// ExitTimeout is how long the command has to exit on its own once its
+	// input is closed by Stop, before it is asked to exit.
+	ExitTimeout time.Duration
+
+	command string
+	args    []string
+
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	// outputs are the read ends of the stdout and stderr pipes.
+	outputs []*os.File
+	readers sync.WaitGroup
+	restart chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// New returns a process running the command, the first element is the
+// executable and the others its arguments.
+func New(command []string) (*Process, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, errors.New("no command specified")
+	}
+	return &Process{
+		RestartDelay: 10 * time.Second,
+		command:      command[0],
+		args:         command[1:],
+		restart:      make(chan struct{}, 1),
+	}, nil
+}
+
+// Start starts the command, returning an error if it cannot be started.  The
+// command is restarted whenever it exits until Stop is called.
+func (p *Process) Start() error {
+	if err := p.start(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		p.run(ctx)
+	}()
+	return nil
+}
+
+// Stop stops the command, closing its stdin and asking it to exit before
+// killing it after a timeout.
+func (p *Process) Stop() {
+	if p.cancel == nil {
+		return
+	}
+	p.cancel()
+	<-p.done
+}
+
+// Restart stops the running command the same way as Stop, and starts it again
+// without waiting for the restart delay.  Pending writes to the stopped
+// command fail.
+func (p *Process) Restart() {
+	select {
+	case p.restart <- struct{}{}:
+	default:
+	}
+}
+
+// Write writes to the stdin of the running command.
+func (p *Process) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	stdin := p.stdin
+	p.mu.Unlock()
+
+	if stdin == nil {
+		return 0, errors.New("process is not running")
+	}
+	return stdin.Write(b)
+}
+
+// Signal sends a signal to the running command.
+func (p *Process) Signal(sig os.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		return errors.New("process is not running")
+	}
+	return p.cmd.Process.Signal(sig)
+}
+
+func (p *Process) start() error {
+	cmd := exec.Command(p.command, p.args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("error opening stdin pipe: %v", err)
+	}
+
+	// The output pipes are created here rather than by the command, so that
+	// its exit can be detected while a child process keeps them open.
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("error opening stdout pipe: %v", err)
+	}
+	stderr, stderrW, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutW.Close()
+		return fmt.Errorf("error opening stderr pipe: %v", err)
+	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+
+	err = cmd.Start()
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdin.Close()
+		stdout.Close()
+		stderr.Close()
+		return fmt.Errorf("error starting %s: %v", p.command, err)
+	}
+	p.Log.Debugf("started %s with pid %d", p.command, cmd.Process.Pid)
+
+	p.mu.Lock()
+	p.cmd = cmd
+	p.stdin = stdin
+	p.mu.Unlock()
+
+	// A restart requested while the command was not running is done.
+	select {
+	case <-p.restart:
+	default:
+	}
+
+	p.outputs = []*os.File{stdout, stderr}
+	p.readers.Add(2)
+	go p.read(stdout, p.ReadStdout)
+	go p.read(stderr, p.ReadStderr)
+	return nil
+}
+
+// read passes the output to the read function, and discards what it leaves
+// so that the command does not block on a full pipe.
+func (p *Process) read(r io.Reader, fn func(io.Reader)) {
+	defer p.readers.Done()
+	if fn != nil {
+		fn(r)
+	}
+	io.Copy(ioutil.Discard, r)
+}
+
+// run waits for the command to exit and restarts it, until the context is
+// done.
+func (p *Process) run(ctx context.Context) {
+	delay := p.RestartDelay
+	for {
+		started := time.Now()
+		restarted, err := p.wait(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if restarted {
+			p.Log.Infof("restarting %s", p.command)
+			err := p.start()
+			if err == nil {
+				continue
+			}
+			p.Log.Error(err)
+		} else if err != nil {
+			p.Log.Errorf("%s exited: %v", p.command, err)
+		} else {
+			p.Log.Errorf("%s exited", p.command)
+		}
+
+		if time.Since(started) >= maxRestartDelay {
+			delay = p.RestartDelay
+		}
+		for {
+			p.Log.Infof("restarting %s in %s", p.command, delay)
+			if internal.SleepContext(ctx, delay) != nil {
+				return
+			}
+			delay = nextDelay(delay)
+
+			err := p.start()
+			if err == nil {
+				break
+			}
+			p.Log.Error(err)
+		}
+	}
+}
+
+func nextDelay(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay > maxRestartDelay {
+		delay = maxRestartDelay
+	}
+	return delay
+}
+
+// wait waits for the command to exit, stopping it if the context is done or a
+// restart is requested first, and for its output to be read.
+func (p *Process) wait(ctx context.Context) (restarted bool, err error) {
+	p.mu.Lock()
+	cmd := p.cmd
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.cmd = nil
+		p.stdin = nil
+		p.mu.Unlock()
+		p.closeOutputs()
+	}()
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		return false, err
+	case <-ctx.Done():
+	case <-p.restart:
+		restarted = true
+	}
+
+	// Many commands exit once their input is closed, the others are asked to
+	// exit and killed if they do not.
+	p.mu.Lock()
+	p.stdin.Close()
+	p.mu.Unlock()
+	if p.ExitTimeout > 0 {
+		timer := time.NewTimer(p.ExitTimeout)
+		select {
+		case err := <-exited:
+			timer.Stop()
+			return restarted, err
+		case <-timer.C:
+		}
+	}
+	if err := terminate(cmd.Process); err != nil {
+		p.Log.Debugf("could not terminate %s: %v", p.command, err)
+	}
+
+	timer := time.NewTimer(stopTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-exited:
+		return restarted, err
+	case <-timer.C:
+		p.Log.Warnf("%s did not exit after %s, killing it", p.command, stopTimeout)
+		cmd.Process.Kill()
+		return restarted, <-exited
+	}
+}
+
+// closeOutputs waits for the remaining output to be read, and closes the
+// pipes.  Pipes still held open by a child of the command are closed after a
+// timeout.
+func (p *Process) closeOutputs() {
+	read := make(chan struct{})
+	go func() {
+		p.readers.Wait()
+		close(read)
+	}()
+
+	timer := time.NewTimer(stopTimeout)
+	defer timer.Stop()
+	select {
+	case <-read:
+	case <-timer.C:
+		p.Log.Warnf("output of %s is still open after it exited, closing it", p.command)
+	}
+
+	for _, f := range p.outputs {
+		f.Close()
+	}
+	<-read
+	p.outputs = nil
+}
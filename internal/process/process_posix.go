@@ -0,0 +1,13 @@
+// +build !windows
+
+package process
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminate asks the process to exit.
+func terminate(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
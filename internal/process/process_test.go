@@ -0,0 +1,158 @@
+// +build !windows
+
+package process
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// lines collects the lines read from the process.
+type lines struct {
+	sync.Mutex
+	lines []string
+	c     chan struct{}
+}
+
+func newLines() *lines {
+	return &lines{c: make(chan struct{}, 100)}
+}
+
+func (l *lines) read(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		l.Lock()
+		l.lines = append(l.lines, scanner.Text())
+		l.Unlock()
+		l.c <- struct{}{}
+	}
+}
+
+func (l *lines) wait(t *testing.T, n int) []string {
+	for i := 0; i < n; i++ {
+		select {
+		case <-l.c:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for line %d", i+1)
+		}
+	}
+	l.Lock()
+	defer l.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+func TestWriteAndStop(t *testing.T) {
+	p, err := New([]string{"cat"})
+	require.NoError(t, err)
+	p.Log = testutil.Logger{}
+	out := newLines()
+	p.ReadStdout = out.read
+	require.NoError(t, p.Start())
+
+	_, err = p.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"hello"}, out.wait(t, 1))
+
+	// cat exits once its input is closed.
+	start := time.Now()
+	p.Stop()
+	require.True(t, time.Since(start) < stopTimeout)
+
+	_, err = p.Write([]byte("hello\n"))
+	require.Error(t, err)
+}
+
+func TestStopIgnoringInput(t *testing.T) {
+	p, err := New([]string{"sh", "-c", "echo started; exec sleep 60"})
+	require.NoError(t, err)
+	p.Log = testutil.Logger{}
+	out := newLines()
+	p.ReadStdout = out.read
+	require.NoError(t, p.Start())
+	out.wait(t, 1)
+
+	start := time.Now()
+	p.Stop()
+	require.True(t, time.Since(start) < stopTimeout)
+}
+
+func TestExitTimeout(t *testing.T) {
+	p, err := New([]string{"sh", "-c", "cat >/dev/null; sleep 0.2; echo flushed"})
+	require.NoError(t, err)
+	p.Log = testutil.Logger{}
+	out := newLines()
+	p.ReadStdout = out.read
+	p.ExitTimeout = stopTimeout
+	require.NoError(t, p.Start())
+
+	// The output written after the input is closed is read before Stop
+	// returns, instead of the command being terminated.
+	p.Stop()
+	require.Equal(t, []string{"flushed"}, out.wait(t, 1))
+}
+
+func TestRestart(t *testing.T) {
+	p, err := New([]string{"sh", "-c", "echo out; echo err >&2"})
+	require.NoError(t, err)
+	p.Log = testutil.Logger{}
+	out := newLines()
+	errs := newLines()
+	p.ReadStdout = out.read
+	p.ReadStderr = errs.read
+	p.RestartDelay = time.Millisecond
+	require.NoError(t, p.Start())
+	defer p.Stop()
+
+	require.Equal(t, []string{"out", "out", "out"}, out.wait(t, 3)[:3])
+	require.Equal(t, []string{"err", "err", "err"}, errs.wait(t, 3)[:3])
+}
+
+func TestRestartRequested(t *testing.T) {
+	p, err := New([]string{"sh", "-c", "echo started; exec sleep 60"})
+	require.NoError(t, err)
+	p.Log = testutil.Logger{}
+	out := newLines()
+	p.ReadStdout = out.read
+	p.RestartDelay = time.Hour
+	require.NoError(t, p.Start())
+	defer p.Stop()
+	out.wait(t, 1)
+
+	// Without a reader the write blocks once the pipe is full, until the
+	// command is stopped.
+	written := make(chan error, 1)
+	go func() {
+		_, err := p.Write(make([]byte, 1<<20))
+		written <- err
+	}()
+
+	p.Restart()
+	select {
+	case err := <-written:
+		require.Error(t, err)
+	case <-time.After(2 * stopTimeout):
+		t.Fatal("timeout waiting for write to fail")
+	}
+	require.Equal(t, []string{"started", "started"}, out.wait(t, 1))
+}
+
+func TestInvalidCommand(t *testing.T) {
+	_, err := New(nil)
+	require.Error(t, err)
+
+	p, err := New([]string{"/nonexistent"})
+	require.NoError(t, err)
+	p.Log = testutil.Logger{}
+	require.Error(t, p.Start())
+}
+
+func TestNextDelay(t *testing.T) {
+	require.Equal(t, 20*time.Second, nextDelay(10*time.Second))
+	require.Equal(t, maxRestartDelay, nextDelay(4*time.Minute))
+}
@@ -0,0 +1,13 @@
+// +build windows
+
+package process
+
+import (
+	"os"
+)
+
+// terminate kills the process, as it cannot be asked to exit with a signal on
+// Windows.
+func terminate(p *os.Process) error {
+	return p.Kill()
+}
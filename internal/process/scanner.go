@@ -0,0 +1,72 @@
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// MaxLineSize is the size of the longest line returned by a LineScanner.
+const MaxLineSize = 16 * 1024 * 1024
+
+// LineScanner reads the lines of the output of a process.  Unlike
+// bufio.Scanner it does not stop on a line which is too long, the line is
+// skipped and reading continues with the next one, so that the process is
+// not blocked writing to a pipe which is no longer read.
+type LineScanner struct {
+	reader  *bufio.Reader
+	maxSize int
+	line    []byte
+	tooLong bool
+	err     error
+}
+
+// NewLineScanner returns a LineScanner reading from r.
+func NewLineScanner(r io.Reader) *LineScanner {
+	return &LineScanner{
+		reader:  bufio.NewReader(r),
+		maxSize: MaxLineSize,
+	}
+}
+
+// Scan advances to the next line, it returns false at the end of the output
+// or on a read error.
+func (s *LineScanner) Scan() bool {
+	s.line = s.line[:0]
+	s.tooLong = false
+	for {
+		part, isPrefix, err := s.reader.ReadLine()
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			return false
+		}
+
+		if len(s.line)+len(part) > s.maxSize {
+			s.line = s.line[:0]
+			s.tooLong = true
+		}
+		if !s.tooLong {
+			s.line = append(s.line, part...)
+		}
+		if !isPrefix {
+			return true
+		}
+	}
+}
+
+// Line returns the current line without the line ending, or an error if the
+// line was longer than the maximum size and skipped.  The line is only valid
+// until the next call to Scan.
+func (s *LineScanner) Line() ([]byte, error) {
+	if s.tooLong {
+		return nil, fmt.Errorf("skipped line longer than %d bytes", s.maxSize)
+	}
+	return s.line, nil
+}
+
+// Err returns the read error which ended the scan, if any.
+func (s *LineScanner) Err() error {
+	return s.err
+}
@@ -0,0 +1,48 @@
+package process
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineScanner(t *testing.T) {
+	input := "first\r\n" + strings.Repeat("x", 100) + "\nsecond\n" +
+		strings.Repeat("y", 64) + "\nlast"
+
+	s := NewLineScanner(strings.NewReader(input))
+	s.maxSize = 64
+
+	var lines []string
+	var skipped int
+	for s.Scan() {
+		line, err := s.Line()
+		if err != nil {
+			require.EqualError(t, err, "skipped line longer than 64 bytes")
+			skipped++
+			continue
+		}
+		lines = append(lines, string(line))
+	}
+	require.NoError(t, s.Err())
+	require.Equal(t, 1, skipped)
+	require.Equal(t, []string{"first", "second", strings.Repeat("y", 64), "last"}, lines)
+}
+
+func TestLineScannerLongLine(t *testing.T) {
+	// Lines are not limited by the size of the read buffer.
+	long := strings.Repeat("x", 1024*1024)
+	s := NewLineScanner(strings.NewReader(long + "\nnext\n"))
+
+	require.True(t, s.Scan())
+	line, err := s.Line()
+	require.NoError(t, err)
+	require.Equal(t, long, string(line))
+
+	require.True(t, s.Scan())
+	line, err = s.Line()
+	require.NoError(t, err)
+	require.Equal(t, "next", string(line))
+	require.False(t, s.Scan())
+}
@@ -11,11 +11,16 @@ Usage:
 The commands & flags are:
 
   config              print out full sample configuration to stdout
+  config check        check the configuration for unknown or deprecated options
   version             print the version to stdout
+  keyring             set, delete or list the secrets of a keyring file
 
   --aggregator-filter <filter>   filter the aggregators to enable, separator is :
   --config <file>                configuration file to load
   --config-directory <directory> directory containing additional *.conf files
+  --config-poll-interval <duration>
+                                 poll a --config URL for changes, reloading
+                                 the configuration when it changed
   --debug                        turn on debug logging
   --input-filter <filter>        filter the inputs to enable, separator is :
   --input-list                   print available input plugins.
@@ -27,7 +32,11 @@ The commands & flags are:
   --quiet                        run in quiet mode
   --sample-config                print out full sample configuration
   --test                         gather metrics, print them out, and exit;
-                                 processors, aggregators, and outputs are not run
+                                 outputs are not run
+  --test-wait <duration>         wait for service inputs to collect metrics
+                                 before printing them, implies --test;
+                                 metrics are passed through processors
+                                 and aggregators, unlike with --test
   --usage <plugin>               print usage for a plugin, ie, 'telegraf --usage mysql'
   --version                      display the version and exit
 
@@ -46,6 +55,12 @@ Examples:
   # run a single telegraf collection, outputing metrics to stdout
   telegraf --config telegraf.conf --test
 
+  # run a telegraf collection, including service inputs listening for 30s
+  telegraf --config telegraf.conf --test-wait 30s
+
+  # check a config file, exiting with an error if there are problems
+  telegraf --config telegraf.conf config check
+
   # run telegraf with all plugins defined in config file
   telegraf --config telegraf.conf
 
@@ -55,6 +70,10 @@ Examples:
   # run telegraf with pprof
   telegraf --config telegraf.conf --pprof-addr localhost:6060
 
+  # store a secret read from stdin in a keyring file, the keyring password is
+  # read from the TELEGRAF_KEYRING_PASSWORD environment variable
+  telegraf keyring set secrets.keyring influxdb_password
+
   # run telegraf without service controller
   telegraf --console install --config "C:\Program Files\Telegraf\telegraf.conf"
 
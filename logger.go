@@ -0,0 +1,28 @@
+package telegraf
+
+// Logger defines an interface for logging from a plugin.  Messages are
+// tagged with the plugin instance and filtered by its log level.
+type Logger interface {
+	// Errorf logs an error message, patterned after log.Printf.
+	Errorf(format string, args ...interface{})
+	// Error logs an error message, patterned after log.Print.
+	Error(args ...interface{})
+	// Warnf logs a warning message, patterned after log.Printf.
+	Warnf(format string, args ...interface{})
+	// Warn logs a warning message, patterned after log.Print.
+	Warn(args ...interface{})
+	// Infof logs an information message, patterned after log.Printf.
+	Infof(format string, args ...interface{})
+	// Info logs an information message, patterned after log.Print.
+	Info(args ...interface{})
+	// Debugf logs a debug message, patterned after log.Printf.
+	Debugf(format string, args ...interface{})
+	// Debug logs a debug message, patterned after log.Print.
+	Debug(args ...interface{})
+}
+
+// LoggerPlugin is implemented by plugins that log through a Logger.
+// SetLogger is called once, before the plugin is started.
+type LoggerPlugin interface {
+	SetLogger(Logger)
+}
@@ -1,36 +1,152 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
 	"io"
 	"log"
 	"os"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/influxdata/wlog"
 )
 
+// Log formats supported by SetupLogging.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
 var prefixRegex = regexp.MustCompile("^[DIWE]!")
 
+var levelNames = map[byte]string{
+	'D': "debug",
+	'I': "info",
+	'W': "warn",
+	'E': "error",
+}
+
+// output receives the messages of plugin loggers, it is replaced by
+// SetupLogging.
+var (
+	outputMu sync.Mutex
+	output   = newTelegrafWriter(os.Stderr, FormatText)
+)
+
+// entry is a single log message.
+type entry struct {
+	time    time.Time
+	level   byte
+	source  string
+	alias   string
+	message string
+}
+
 // newTelegrafWriter returns a logging-wrapped writer.
-func newTelegrafWriter(w io.Writer) io.Writer {
+func newTelegrafWriter(w io.Writer, format string) *telegrafLog {
 	return &telegrafLog{
-		writer: wlog.NewWriter(w),
+		writer: w,
+		json:   format == FormatJSON,
 	}
 }
 
 type telegrafLog struct {
+	sync.Mutex
 	writer io.Writer
+	json   bool
 }
 
+// Write writes a message logged with the log package, filtering it by the
+// global log level.
 func (t *telegrafLog) Write(b []byte) (n int, err error) {
+	level := byte('I')
+	if prefixRegex.Match(b) {
+		level = b[0]
+	}
+	if wlog.Levels[level] < wlog.LogLevel() {
+		return len(b), nil
+	}
+
+	n = len(b)
+	b = []byte(Redact(string(b)))
+
+	now := time.Now().UTC()
+	if t.json {
+		return n, t.writeEntry(parseEntry(now, b))
+	}
+
 	var line []byte
 	if !prefixRegex.Match(b) {
-		line = append([]byte(time.Now().UTC().Format(time.RFC3339)+" I! "), b...)
+		line = append([]byte(now.Format(time.RFC3339)+" I! "), b...)
+	} else {
+		line = append([]byte(now.Format(time.RFC3339)+" "), b...)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if _, err = t.writer.Write(line); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// writeEntry writes an entry without filtering.
+func (t *telegrafLog) writeEntry(e entry) error {
+	var line []byte
+	if t.json {
+		fields := map[string]string{
+			"time":    e.time.Format(time.RFC3339Nano),
+			"level":   levelNames[e.level],
+			"message": e.message,
+		}
+		if e.source != "" {
+			fields["source"] = e.source
+		}
+		if e.alias != "" {
+			fields["alias"] = e.alias
+		}
+
+		var err error
+		line, err = json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
 	} else {
-		line = append([]byte(time.Now().UTC().Format(time.RFC3339)+" "), b...)
+		source := e.source
+		if e.alias != "" {
+			source += "::" + e.alias
+		}
+		line = []byte(e.time.Format(time.RFC3339) + " " + string(e.level) +
+			"! [" + source + "] " + e.message + "\n")
 	}
-	return t.writer.Write(line)
+
+	t.Lock()
+	defer t.Unlock()
+	_, err := t.writer.Write(line)
+	return err
+}
+
+// parseEntry splits a message logged with the log package into the level,
+// the source in brackets and the message.
+func parseEntry(now time.Time, b []byte) entry {
+	e := entry{time: now, level: 'I'}
+	if prefixRegex.Match(b) {
+		e.level = b[0]
+		b = b[2:]
+	}
+	b = bytes.TrimSpace(b)
+
+	if len(b) > 0 && b[0] == '[' {
+		if end := bytes.Index(b, []byte("] ")); end > 0 {
+			e.source = string(b[1:end])
+			b = b[end+2:]
+		}
+	}
+	e.message = string(b)
+	return e
 }
 
 // SetupLogging configures the logging output.
@@ -39,7 +155,8 @@ func (t *telegrafLog) Write(b []byte) (n int, err error) {
 //   logfile will direct the logging output to a file. Empty string is
 //           interpreted as stderr. If there is an error opening the file the
 //           logger will fallback to stderr.
-func SetupLogging(debug, quiet bool, logfile string) {
+//   format  is either "text" or "json", empty is interpreted as "text".
+func SetupLogging(debug, quiet bool, logfile string, format string) {
 	log.SetFlags(0)
 	if debug {
 		wlog.SetLevel(wlog.DEBUG)
@@ -65,5 +182,16 @@ func SetupLogging(debug, quiet bool, logfile string) {
 		oFile = os.Stderr
 	}
 
-	log.SetOutput(newTelegrafWriter(oFile))
+	switch format {
+	case "", FormatText, FormatJSON:
+	default:
+		log.Printf("E! Unknown log format %q, using %q", format, FormatText)
+		format = FormatText
+	}
+
+	writer := newTelegrafWriter(oFile, format)
+	outputMu.Lock()
+	output = writer
+	outputMu.Unlock()
+	log.SetOutput(writer)
 }
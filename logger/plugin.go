@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/wlog"
+)
+
+// pluginLogger logs the messages of a single plugin instance.
+type pluginLogger struct {
+	source string
+	alias  string
+
+	// level overrides the global log level when set.
+	level wlog.Level
+}
+
+// NewLogger returns a logger for a plugin instance.  The source is the
+// plugin name, ie "inputs.cpu", and the alias, which may be empty, tells
+// apart instances of the same plugin.  Level is one of "debug", "info",
+// "warn" or "error", an empty level uses the global log level.
+func NewLogger(source, alias, level string) (telegraf.Logger, error) {
+	l := &pluginLogger{
+		source: source,
+		alias:  alias,
+	}
+	if level != "" {
+		var ok bool
+		l.level, ok = wlog.StringToLevel[strings.ToUpper(level)]
+		if !ok || l.level == wlog.OFF {
+			return nil, fmt.Errorf("invalid log level %q", level)
+		}
+	}
+	return l, nil
+}
+
+func (l *pluginLogger) Errorf(format string, args ...interface{}) {
+	l.print('E', fmt.Sprintf(format, args...))
+}
+
+func (l *pluginLogger) Error(args ...interface{}) {
+	l.print('E', fmt.Sprint(args...))
+}
+
+func (l *pluginLogger) Warnf(format string, args ...interface{}) {
+	l.print('W', fmt.Sprintf(format, args...))
+}
+
+func (l *pluginLogger) Warn(args ...interface{}) {
+	l.print('W', fmt.Sprint(args...))
+}
+
+func (l *pluginLogger) Infof(format string, args ...interface{}) {
+	l.print('I', fmt.Sprintf(format, args...))
+}
+
+func (l *pluginLogger) Info(args ...interface{}) {
+	l.print('I', fmt.Sprint(args...))
+}
+
+func (l *pluginLogger) Debugf(format string, args ...interface{}) {
+	l.print('D', fmt.Sprintf(format, args...))
+}
+
+func (l *pluginLogger) Debug(args ...interface{}) {
+	l.print('D', fmt.Sprint(args...))
+}
+
+func (l *pluginLogger) print(level byte, message string) {
+	min := l.level
+	if min == 0 {
+		min = wlog.LogLevel()
+	}
+	if wlog.Levels[level] < min {
+		return
+	}
+
+	outputMu.Lock()
+	w := output
+	outputMu.Unlock()
+
+	w.writeEntry(entry{
+		time:    time.Now().UTC(),
+		level:   level,
+		source:  l.source,
+		alias:   l.alias,
+		message: Redact(strings.TrimSuffix(message, "\n")),
+	})
+}
@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redacted replaces the secrets in log messages.
+const redacted = "******"
+
+// secrets are the values redacted from log messages, longest first so that a
+// secret containing another one is redacted as a whole.
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret adds a value to redact from all log messages.  The URL
+// escaped forms of the value are redacted too, as they appear in the errors
+// about URLs containing the secret.
+func RegisterSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	forms := []string{
+		secret,
+		url.QueryEscape(secret),
+		url.PathEscape(secret),
+		strings.TrimPrefix(url.UserPassword("", secret).String(), ":"),
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, form := range forms {
+		known := false
+		for _, s := range secrets {
+			if s == form {
+				known = true
+				break
+			}
+		}
+		if !known {
+			secrets = append(secrets, form)
+		}
+	}
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+}
+
+// Redact returns s with the registered secrets replaced.
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, secret := range secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+	return s
+}
@@ -3,6 +3,7 @@ package metric
 import (
 	"log"
 	"runtime"
+	"sync"
 	"sync/atomic"
 
 	"github.com/influxdata/telegraf"
@@ -69,6 +70,18 @@ func (d *trackingData) reject() {
 	atomic.AddInt32(&d.rejectCount, 1)
 }
 
+// release removes a reference, notifying once there are none left.
+func (d *trackingData) release() {
+	v := d.decr()
+	if v < 0 {
+		panic("negative refcount")
+	}
+
+	if v == 0 {
+		d.notify()
+	}
+}
+
 func (d *trackingData) notify() {
 	d.notifyFunc(
 		&deliveryInfo{
@@ -141,26 +154,114 @@ func (m *trackingMetric) Copy() telegraf.Metric {
 
 func (m *trackingMetric) Accept() {
 	m.d.accept()
-	m.decr()
+	m.d.release()
 }
 
 func (m *trackingMetric) Reject() {
 	m.d.reject()
-	m.decr()
+	m.d.release()
 }
 
 func (m *trackingMetric) Drop() {
-	m.decr()
+	m.d.release()
 }
 
-func (m *trackingMetric) decr() {
-	v := m.d.decr()
-	if v < 0 {
-		panic("negative refcount")
+// Derivation carries the tracking of metrics over to the metrics derived from
+// them, such as the aggregates of an aggregator or the metrics a processor
+// creates from its input.  The delivery of the parent metrics is held until
+// all derived metrics are delivered, then the parents are rejected if any
+// derived metric was rejected, accepted if any was accepted and otherwise
+// dropped.
+type Derivation struct {
+	mu      sync.Mutex
+	parents map[*trackingData]bool
+	d       *trackingData
+}
+
+// NewDerivation returns a Derivation from the given metrics, parents can also
+// be added later with Add.
+func NewDerivation(parents ...telegraf.Metric) *Derivation {
+	g := &Derivation{}
+	for _, m := range parents {
+		g.Add(m)
 	}
+	return g
+}
 
-	if v == 0 {
-		m.d.notify()
+// Add adds a parent metric, holding its delivery until the derived metrics are
+// delivered.  Metrics without tracking are ignored.
+func (g *Derivation) Add(m telegraf.Metric) {
+	tm, ok := m.(*trackingMetric)
+	if !ok {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.parents == nil {
+		g.parents = make(map[*trackingData]bool)
+	}
+	if !g.parents[tm.d] {
+		tm.d.incr()
+		g.parents[tm.d] = true
+	}
+}
+
+// Track returns the derived metric with tracking.  Metrics which already have
+// tracking are returned unchanged, as are all metrics when no parent has
+// tracking.  Track must not be called after Done.
+func (g *Derivation) Track(m telegraf.Metric) telegraf.Metric {
+	if _, ok := m.(*trackingMetric); ok {
+		return m
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.parents) == 0 {
+		return m
+	}
+	if g.d == nil {
+		g.d = &trackingData{
+			id:         newTrackingID(),
+			rc:         1,
+			notifyFunc: g.deliver,
+		}
+	}
+	g.d.incr()
+	return &trackingMetric{Metric: m, d: g.d}
+}
+
+// Done is called once all metrics have been derived.  The parents are
+// delivered immediately if no metric was derived.
+func (g *Derivation) Done() {
+	g.mu.Lock()
+	d := g.d
+	g.mu.Unlock()
+
+	if d == nil {
+		g.deliver(nil)
+		return
+	}
+	d.release()
+}
+
+func (g *Derivation) deliver(telegraf.DeliveryInfo) {
+	var accepted, rejected int32
+	if g.d != nil {
+		accepted = atomic.LoadInt32(&g.d.acceptCount)
+		rejected = atomic.LoadInt32(&g.d.rejectCount)
+	}
+
+	for parent := range g.parents {
+		switch {
+		case rejected > 0:
+			parent.reject()
+		case accepted > 0:
+			parent.accept()
+		}
+		parent.release()
 	}
 }
 
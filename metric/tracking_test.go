@@ -258,3 +258,103 @@ func TestGroupTracking(t *testing.T) {
 		})
 	}
 }
+
+func TestDerivationTracking(t *testing.T) {
+	tests := []struct {
+		name      string
+		actions   func(derived []telegraf.Metric)
+		delivered bool
+	}{
+		{
+			name: "accept",
+			actions: func(derived []telegraf.Metric) {
+				derived[0].Accept()
+				derived[1].Accept()
+			},
+			delivered: true,
+		},
+		{
+			name: "drop",
+			actions: func(derived []telegraf.Metric) {
+				derived[0].Drop()
+				derived[1].Drop()
+			},
+			delivered: true,
+		},
+		{
+			name: "mixed",
+			actions: func(derived []telegraf.Metric) {
+				derived[0].Accept()
+				derived[1].Reject()
+			},
+			delivered: false,
+		},
+		{
+			name: "reject copy",
+			actions: func(derived []telegraf.Metric) {
+				derived[0].Copy().Reject()
+				derived[0].Accept()
+				derived[1].Drop()
+			},
+			delivered: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &deliveries{
+				Info: make(map[telegraf.TrackingID]telegraf.DeliveryInfo),
+			}
+			metrics, id := WithGroupTracking([]telegraf.Metric{
+				mustMetric("cpu", map[string]string{},
+					map[string]interface{}{"value": 42}, time.Unix(0, 0)),
+				mustMetric("cpu", map[string]string{},
+					map[string]interface{}{"value": 43}, time.Unix(0, 0)),
+			}, d.onDelivery)
+
+			derivation := NewDerivation(metrics...)
+			for _, m := range metrics {
+				m.Drop()
+			}
+
+			derived := []telegraf.Metric{
+				derivation.Track(mustMetric("cpu_sum", map[string]string{},
+					map[string]interface{}{"value": 85}, time.Unix(0, 0))),
+				derivation.Track(mustMetric("cpu_count", map[string]string{},
+					map[string]interface{}{"value": 2}, time.Unix(0, 0))),
+			}
+			derivation.Done()
+			require.Len(t, d.Info, 0)
+
+			tt.actions(derived)
+			info, ok := d.Info[id]
+			require.True(t, ok)
+			require.Equal(t, tt.delivered, info.Delivered())
+		})
+	}
+}
+
+func TestDerivationWithoutDerived(t *testing.T) {
+	d := &deliveries{
+		Info: make(map[telegraf.TrackingID]telegraf.DeliveryInfo),
+	}
+	m, id := WithTracking(mustMetric("cpu", map[string]string{},
+		map[string]interface{}{"value": 42}, time.Unix(0, 0)), d.onDelivery)
+
+	derivation := NewDerivation(m)
+	m.Accept()
+	require.Len(t, d.Info, 0)
+
+	derivation.Done()
+	require.True(t, d.Info[id].Delivered())
+}
+
+func TestDerivationUntracked(t *testing.T) {
+	parent := mustMetric("cpu", map[string]string{},
+		map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	derived := mustMetric("cpu_sum", map[string]string{},
+		map[string]interface{}{"value": 42}, time.Unix(0, 0))
+
+	derivation := NewDerivation(parent)
+	require.True(t, derived == derivation.Track(derived))
+	derivation.Done()
+}
@@ -1,5 +1,7 @@
 package telegraf
 
+import "time"
+
 type Output interface {
 	// Connect to the Output
 	Connect() error
@@ -26,3 +28,24 @@ type AggregatingOutput interface {
 	// Reset signals the the aggregator period is completed.
 	Reset()
 }
+
+// OutputStatus is the state of an output run by the agent.
+type OutputStatus struct {
+	Name string
+	// BufferSize is the number of metrics waiting to be written.
+	BufferSize  int
+	BufferLimit int
+	// LastWrite is the time of the last successful write, or the time the
+	// output was started if it has not written yet.
+	LastWrite time.Time
+}
+
+// StatusOutput is an Output reporting on the other outputs of the agent.
+type StatusOutput interface {
+	Output
+
+	// SetStatus is called before Connect with a function returning the
+	// status of the other outputs.  The function is safe to call
+	// concurrently.
+	SetStatus(status func() []OutputStatus)
+}
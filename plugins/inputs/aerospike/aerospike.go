@@ -22,7 +22,7 @@ type Aerospike struct {
 	Password string `toml:"password"`
 
 	EnableTLS bool `toml:"enable_tls"`
-	EnableSSL bool `toml:"enable_ssl"` // deprecated in 1.7; use enable_tls
+	EnableSSL bool `toml:"enable_ssl" deprecated:"1.7;use enable_tls"`
 	tlsint.ClientConfig
 
 	initialized bool
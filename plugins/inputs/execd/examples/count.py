@@ -0,0 +1,17 @@
+#!/usr/bin/env python3
+
+# Example input plugin for inputs.execd, outputs a counter each time a newline
+# is received on stdin.
+#
+#   [[inputs.execd]]
+#     command = ["plugins/inputs/execd/examples/count.py"]
+#     signal = "STDIN"
+
+import sys
+
+counter = 0
+
+for line in sys.stdin:
+    counter += 1
+    print("counter_python count={}i".format(counter))
+    sys.stdout.flush()
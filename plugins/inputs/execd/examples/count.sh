@@ -0,0 +1,18 @@
+#!/bin/sh
+
+# Example input plugin for inputs.execd, outputs a counter on each SIGUSR1.
+#
+#   [[inputs.execd]]
+#     command = ["plugins/inputs/execd/examples/count.sh"]
+#     signal = "SIGUSR1"
+
+counter=0
+
+increment() {
+  counter=$((counter+1))
+  echo "counter_sh count=${counter}i"
+}
+
+trap increment USR1
+
+while true; do sleep 1; done
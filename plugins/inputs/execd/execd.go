@@ -0,0 +1,166 @@
+package execd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/process"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/parsers"
+)
+
+const sampleConfig = `
+  ## Program to run as daemon, the first element is the executable and the
+  ## others its arguments.
+  command = ["telegraf-smartctl", "-d", "/dev/sda"]
+
+  ## Define how the process is signaled on each collection interval.
+  ## Valid values are:
+  ##   "none"    : Do not signal anything.
+  ##               The process must output metrics by itself.
+  ##   "STDIN"   : Send a newline on STDIN.
+  ##   "SIGHUP"  : Send a HUP signal. Not available on Windows.
+  ##   "SIGUSR1" : Send a USR1 signal. Not available on Windows.
+  ##   "SIGUSR2" : Send a USR2 signal. Not available on Windows.
+  signal = "none"
+
+  ## Delay before the process is restarted after an unexpected termination,
+  ## doubled on every restart of a process exiting quickly, up to 5m.
+  restart_delay = "10s"
+
+  ## Data format to consume.
+  ## Each data format has its own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
+  data_format = "influx"
+`
+
+type Execd struct {
+	Command      []string          `toml:"command"`
+	Signal       string            `toml:"signal"`
+	RestartDelay internal.Duration `toml:"restart_delay"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	acc     telegraf.Accumulator
+	parser  parsers.Parser
+	process *process.Process
+}
+
+func New() *Execd {
+	return &Execd{
+		Signal:       "none",
+		RestartDelay: internal.Duration{Duration: 10 * time.Second},
+	}
+}
+
+func (e *Execd) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Execd) Description() string {
+	return "Run executable as long-running input plugin"
+}
+
+// SetLogger implements telegraf.LoggerPlugin.
+func (e *Execd) SetLogger(l telegraf.Logger) {
+	e.Log = l
+}
+
+func (e *Execd) SetParser(parser parsers.Parser) {
+	e.parser = parser
+}
+
+func (e *Execd) Start(acc telegraf.Accumulator) error {
+	if e.Signal != "none" && e.Signal != "STDIN" {
+		if _, err := signal(e.Signal); err != nil {
+			return err
+		}
+	}
+
+	p, err := process.New(e.Command)
+	if err != nil {
+		return fmt.Errorf("error creating process: %v", err)
+	}
+	p.Log = e.Log
+	p.RestartDelay = e.RestartDelay.Duration
+	p.ReadStdout = e.readStdout
+	p.ReadStderr = e.readStderr
+
+	e.acc = acc
+	e.process = p
+	return p.Start()
+}
+
+func (e *Execd) Stop() {
+	e.process.Stop()
+}
+
+// Gather signals the process to output its metrics.
+func (e *Execd) Gather(acc telegraf.Accumulator) error {
+	switch e.Signal {
+	case "none":
+		return nil
+	case "STDIN":
+		if _, err := e.process.Write([]byte{'\n'}); err != nil {
+			return fmt.Errorf("error writing to stdin: %v", err)
+		}
+		return nil
+	}
+
+	sig, err := signal(e.Signal)
+	if err != nil {
+		return err
+	}
+	if err := e.process.Signal(sig); err != nil {
+		return fmt.Errorf("error sending %s: %v", e.Signal, err)
+	}
+	return nil
+}
+
+func (e *Execd) readStdout(r io.Reader) {
+	scanner := process.NewLineScanner(r)
+	for scanner.Scan() {
+		line, err := scanner.Line()
+		if err != nil {
+			e.acc.AddError(fmt.Errorf("error reading stdout: %v", err))
+			continue
+		}
+		metrics, err := e.parser.Parse(line)
+		if err != nil {
+			e.acc.AddError(fmt.Errorf("parse error: %v", err))
+			continue
+		}
+		for _, m := range metrics {
+			e.acc.AddMetric(m)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		e.acc.AddError(fmt.Errorf("error reading stdout: %v", err))
+	}
+}
+
+// readStderr logs each line of the standard error of the process.
+func (e *Execd) readStderr(r io.Reader) {
+	scanner := process.NewLineScanner(r)
+	for scanner.Scan() {
+		line, err := scanner.Line()
+		if err != nil {
+			e.acc.AddError(fmt.Errorf("error reading stderr: %v", err))
+			continue
+		}
+		e.Log.Errorf("stderr: %s", line)
+	}
+	if err := scanner.Err(); err != nil {
+		e.acc.AddError(fmt.Errorf("error reading stderr: %v", err))
+	}
+}
+
+func init() {
+	inputs.Add("execd", func() telegraf.Input {
+		return New()
+	})
+}
@@ -0,0 +1,21 @@
+// +build !windows
+
+package execd
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func signal(name string) (os.Signal, error) {
+	switch name {
+	case "SIGHUP":
+		return syscall.SIGHUP, nil
+	case "SIGUSR1":
+		return syscall.SIGUSR1, nil
+	case "SIGUSR2":
+		return syscall.SIGUSR2, nil
+	}
+	return nil, fmt.Errorf("unknown signal %q", name)
+}
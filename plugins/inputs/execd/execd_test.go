@@ -0,0 +1,110 @@
+// +build !windows
+
+package execd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newExecd(t *testing.T, script string) *Execd {
+	e := New()
+	e.Log = testutil.Logger{}
+	e.Command = []string{"sh", "-c", script}
+	parser, err := parsers.NewInfluxParser()
+	require.NoError(t, err)
+	e.SetParser(parser)
+	return e
+}
+
+func TestSignalStdin(t *testing.T) {
+	e := newExecd(t, `
+i=0
+while read line; do
+	i=$((i+1))
+	echo "counter value=${i}i"
+done`)
+	e.Signal = "STDIN"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Start(acc))
+	defer e.Stop()
+
+	require.NoError(t, e.Gather(acc))
+	acc.Wait(1)
+	require.NoError(t, e.Gather(acc))
+	acc.Wait(2)
+
+	require.Equal(t, int64(2), acc.Metrics[1].Fields["value"])
+}
+
+func TestSignalUSR1(t *testing.T) {
+	e := newExecd(t, `
+trap 'echo "cpu value=1"' USR1
+echo "ready value=1"
+while true; do sleep 0.01; done`)
+	e.Signal = "SIGUSR1"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Start(acc))
+	defer e.Stop()
+
+	// The trap is set once the first metric is output.
+	acc.Wait(1)
+	require.NoError(t, e.Gather(acc))
+	acc.Wait(2)
+	require.True(t, acc.HasMeasurement("cpu"))
+}
+
+func TestRestart(t *testing.T) {
+	e := newExecd(t, `echo "cpu value=1"; echo "error" >&2; exit 1`)
+	e.RestartDelay = internal.Duration{Duration: 10 * time.Millisecond}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Start(acc))
+	defer e.Stop()
+
+	acc.Wait(2)
+}
+
+func TestParseError(t *testing.T) {
+	e := newExecd(t, `echo "not line protocol"; echo "cpu value=1"; exec sleep 10`)
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Start(acc))
+	defer e.Stop()
+
+	acc.Wait(1)
+	require.Len(t, acc.Errors, 1)
+}
+
+func TestLongLine(t *testing.T) {
+	e := newExecd(t, `
+printf 'cpu,long=%s value=1\n' "$(head -c 100000 /dev/zero | tr '\0' x)"
+echo "cpu value=2"
+exec sleep 10`)
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Start(acc))
+	defer e.Stop()
+
+	// Lines longer than the default buffer of bufio.Scanner are read.
+	acc.Wait(2)
+	require.Len(t, acc.Metrics[0].Tags["long"], 100000)
+	require.Equal(t, 2.0, acc.Metrics[1].Fields["value"])
+}
+
+func TestInvalidConfig(t *testing.T) {
+	e := newExecd(t, "true")
+	e.Signal = "SIGKILL"
+	require.Error(t, e.Start(&testutil.Accumulator{}))
+
+	e = New()
+	e.Log = testutil.Logger{}
+	require.Error(t, e.Start(&testutil.Accumulator{}))
+}
@@ -0,0 +1,16 @@
+// +build windows
+
+package execd
+
+import (
+	"fmt"
+	"os"
+)
+
+func signal(name string) (os.Signal, error) {
+	switch name {
+	case "SIGHUP", "SIGUSR1", "SIGUSR2":
+		return nil, fmt.Errorf("signal %s is not supported on Windows", name)
+	}
+	return nil, fmt.Errorf("unknown signal %q", name)
+}
@@ -48,7 +48,7 @@ const sampleConfig = `
 `
 
 type FileCount struct {
-	Directory   string // deprecated in 1.9
+	Directory   string `deprecated:"1.9;use directories"`
 	Directories []string
 	Name        string
 	Recursive   bool
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -92,6 +93,12 @@ func (*HTTP) Description() string {
 // Gather takes in an accumulator and adds the metrics that the Input
 // gathers. This is called every "interval"
 func (h *HTTP) Gather(acc telegraf.Accumulator) error {
+	return h.GatherContext(context.Background(), acc)
+}
+
+// GatherContext gathers the urls, cancelling the requests once the context is
+// done.
+func (h *HTTP) GatherContext(ctx context.Context, acc telegraf.Accumulator) error {
 	if h.parser == nil {
 		return errors.New("Parser is not set")
 	}
@@ -115,7 +122,7 @@ func (h *HTTP) Gather(acc telegraf.Accumulator) error {
 		wg.Add(1)
 		go func(url string) {
 			defer wg.Done()
-			if err := h.gatherURL(acc, url); err != nil {
+			if err := h.gatherURL(ctx, acc, url); err != nil {
 				acc.AddError(fmt.Errorf("[url=%s]: %s", url, err))
 			}
 		}(u)
@@ -133,12 +140,14 @@ func (h *HTTP) SetParser(parser parsers.Parser) {
 
 // Gathers data from a particular URL
 // Parameters:
+//     ctx    : The context cancelling the request
 //     acc    : The telegraf Accumulator to use
 //     url    : endpoint to send request to
 //
 // Returns:
 //     error: Any error that may have occurred
 func (h *HTTP) gatherURL(
+	ctx context.Context,
 	acc telegraf.Accumulator,
 	url string,
 ) error {
@@ -151,6 +160,7 @@ func (h *HTTP) gatherURL(
 	if err != nil {
 		return err
 	}
+	request = request.WithContext(ctx)
 
 	if h.ContentEncoding == "gzip" {
 		request.Header.Set("Content-Encoding", "gzip")
@@ -1,6 +1,7 @@
 package http_response
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -171,7 +172,7 @@ func setError(err error, fields map[string]interface{}, tags map[string]string)
 }
 
 // HTTPGather gathers all fields and returns any errors it encounters
-func (h *HTTPResponse) httpGather() (map[string]interface{}, map[string]string, error) {
+func (h *HTTPResponse) httpGather(ctx context.Context) (map[string]interface{}, map[string]string, error) {
 	// Prepare fields and tags
 	fields := make(map[string]interface{})
 	tags := map[string]string{"server": h.Address, "method": h.Method}
@@ -184,6 +185,7 @@ func (h *HTTPResponse) httpGather() (map[string]interface{}, map[string]string,
 	if err != nil {
 		return nil, nil, err
 	}
+	request = request.WithContext(ctx)
 
 	for key, val := range h.Headers {
 		request.Header.Add(key, val)
@@ -267,6 +269,12 @@ func (h *HTTPResponse) httpGather() (map[string]interface{}, map[string]string,
 
 // Gather gets all metric fields and tags and returns any errors it encounters
 func (h *HTTPResponse) Gather(acc telegraf.Accumulator) error {
+	return h.GatherContext(context.Background(), acc)
+}
+
+// GatherContext gathers the response, cancelling the request once the context
+// is done.
+func (h *HTTPResponse) GatherContext(ctx context.Context, acc telegraf.Accumulator) error {
 	// Compile the body regex if it exist
 	if h.compiledStringMatch == nil {
 		var err error
@@ -308,7 +316,7 @@ func (h *HTTPResponse) Gather(acc telegraf.Accumulator) error {
 	}
 
 	// Gather data
-	fields, tags, err = h.httpGather()
+	fields, tags, err = h.httpGather(ctx)
 	if err != nil {
 		return err
 	}
@@ -3,6 +3,7 @@
 package ping
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"sort"
@@ -141,7 +142,7 @@ func TestArguments(t *testing.T) {
 	}
 }
 
-func mockHostPinger(binary string, timeout float64, args ...string) (string, error) {
+func mockHostPinger(ctx context.Context, binary string, timeout float64, args ...string) (string, error) {
 	return linuxPingOutput, nil
 }
 
@@ -182,7 +183,7 @@ PING www.google.com (216.58.218.164) 56(84) bytes of data.
 rtt min/avg/max/mdev = 35.225/44.033/51.806/5.325 ms
 `
 
-func mockLossyHostPinger(binary string, timeout float64, args ...string) (string, error) {
+func mockLossyHostPinger(ctx context.Context, binary string, timeout float64, args ...string) (string, error) {
 	return lossyPingOutput, nil
 }
 
@@ -217,7 +218,7 @@ Request timeout for icmp_seq 0
 2 packets transmitted, 0 packets received, 100.0% packet loss
 `
 
-func mockErrorHostPinger(binary string, timeout float64, args ...string) (string, error) {
+func mockErrorHostPinger(ctx context.Context, binary string, timeout float64, args ...string) (string, error) {
 	// This error will not trigger correct error paths
 	return errorPingOutput, nil
 }
@@ -242,7 +243,7 @@ func TestBadPingGather(t *testing.T) {
 	acc.AssertContainsTaggedFields(t, "ping", fields, tags)
 }
 
-func mockFatalHostPinger(binary string, timeout float64, args ...string) (string, error) {
+func mockFatalHostPinger(ctx context.Context, binary string, timeout float64, args ...string) (string, error) {
 	return fatalPingOutput, errors.New("So very bad")
 }
 
@@ -282,7 +283,7 @@ func TestErrorWithHostNamePingGather(t *testing.T) {
 		var acc testutil.Accumulator
 		p := Ping{
 			Urls: []string{"www.amazon.com"},
-			pingHost: func(binary string, timeout float64, args ...string) (string, error) {
+			pingHost: func(ctx context.Context, binary string, timeout float64, args ...string) (string, error) {
 				return param.out, errors.New("So very bad")
 			},
 		}
@@ -297,7 +298,7 @@ func TestPingBinary(t *testing.T) {
 	p := Ping{
 		Urls:   []string{"www.google.com"},
 		Binary: "ping6",
-		pingHost: func(binary string, timeout float64, args ...string) (string, error) {
+		pingHost: func(ctx context.Context, binary string, timeout float64, args ...string) (string, error) {
 			assert.True(t, binary == "ping6")
 			return "", nil
 		},
@@ -3,6 +3,7 @@
 package ping
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -20,8 +21,9 @@ import (
 
 // HostPinger is a function that runs the "ping" function using a list of
 // passed arguments. This can be easily switched with a mocked ping function
-// for unit test purposes (see ping_test.go)
-type HostPinger func(binary string, timeout float64, args ...string) (string, error)
+// for unit test purposes (see ping_test.go).  The ping is killed once the
+// context is done.
+type HostPinger func(ctx context.Context, binary string, timeout float64, args ...string) (string, error)
 
 type Ping struct {
 	wg sync.WaitGroup
@@ -73,6 +75,12 @@ func (s *Ping) SampleConfig() string {
 }
 
 func (p *Ping) Gather(acc telegraf.Accumulator) error {
+	return p.GatherContext(context.Background(), acc)
+}
+
+// GatherContext pings the urls, stopping the pings still running once the
+// context is done.
+func (p *Ping) GatherContext(ctx context.Context, acc telegraf.Accumulator) error {
 	if p.Count < 1 {
 		p.Count = 1
 	}
@@ -80,7 +88,7 @@ func (p *Ping) Gather(acc telegraf.Accumulator) error {
 	// Spin off a go routine for each url to ping
 	for _, url := range p.Urls {
 		p.wg.Add(1)
-		go p.pingToURL(url, acc)
+		go p.pingToURL(ctx, url, acc)
 	}
 
 	p.wg.Wait()
@@ -88,7 +96,7 @@ func (p *Ping) Gather(acc telegraf.Accumulator) error {
 	return nil
 }
 
-func (p *Ping) pingToURL(u string, acc telegraf.Accumulator) {
+func (p *Ping) pingToURL(ctx context.Context, u string, acc telegraf.Accumulator) {
 	defer p.wg.Done()
 
 	tags := map[string]string{"url": u}
@@ -108,7 +116,7 @@ func (p *Ping) pingToURL(u string, acc telegraf.Accumulator) {
 		totalTimeout = p.timeout() * float64(p.Count)
 	}
 
-	out, err := p.pingHost(p.Binary, totalTimeout, args...)
+	out, err := p.pingHost(ctx, p.Binary, totalTimeout, args...)
 	// ping host return exitcode != 0 also when there was no response from host
 	// but command was execute successfully
 	var pendingError error
@@ -151,12 +159,12 @@ func (p *Ping) pingToURL(u string, acc telegraf.Accumulator) {
 	acc.AddFields("ping", fields, tags)
 }
 
-func hostPinger(binary string, timeout float64, args ...string) (string, error) {
+func hostPinger(ctx context.Context, binary string, timeout float64, args ...string) (string, error) {
 	bin, err := exec.LookPath(binary)
 	if err != nil {
 		return "", err
 	}
-	c := exec.Command(bin, args...)
+	c := exec.CommandContext(ctx, bin, args...)
 	out, err := internal.CombinedOutputTimeout(c,
 		time.Second*time.Duration(timeout+1))
 	return string(out), err
@@ -2,6 +2,7 @@
 package snmp
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os/exec"
@@ -652,6 +653,32 @@ func TestGather_host(t *testing.T) {
 	assert.Equal(t, "baz", m.Tags["host"])
 }
 
+func TestGatherContext_cancelled(t *testing.T) {
+	s := &Snmp{
+		Agents: []string{"TestGather"},
+		Name:   "mytable",
+		Fields: []Field{
+			{
+				Name: "myfield2",
+				Oid:  ".1.0.0.1.2",
+			},
+		},
+
+		connectionCache: []snmpConnection{
+			tsc,
+		},
+		initialized: true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.GatherContext(ctx, acc))
+	require.Len(t, acc.Metrics, 0)
+	require.Len(t, acc.Errors, 1)
+}
+
 func TestFieldConvert(t *testing.T) {
 	testTable := []struct {
 		input    interface{}
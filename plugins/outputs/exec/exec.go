@@ -0,0 +1,119 @@
+package exec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/serializers"
+)
+
+// maxStderrBytes limits the output of the command included in errors.
+const maxStderrBytes = 512
+
+var sampleConfig = `
+  ## Command to run for each batch of metrics, the first element is the
+  ## executable and the others its arguments.  The serialized metrics are
+  ## written on its standard input.
+  command = ["tee", "-a", "/tmp/metrics.out"]
+
+  ## Timeout for the command to complete, the batch is written again later if
+  ## the command times out or exits with a non-zero status.
+  # timeout = "5s"
+
+  ## Data format to output.
+  ## Each data format has its own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
+  data_format = "influx"
+`
+
+type Exec struct {
+	Command []string          `toml:"command"`
+	Timeout internal.Duration `toml:"timeout"`
+
+	serializer serializers.Serializer
+}
+
+func New() *Exec {
+	return &Exec{
+		Timeout: internal.Duration{Duration: 5 * time.Second},
+	}
+}
+
+func (e *Exec) SetSerializer(serializer serializers.Serializer) {
+	e.serializer = serializer
+}
+
+func (e *Exec) Connect() error {
+	if len(e.Command) == 0 || e.Command[0] == "" {
+		return errors.New("no command specified")
+	}
+	return nil
+}
+
+func (e *Exec) Close() error {
+	return nil
+}
+
+func (e *Exec) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Exec) Description() string {
+	return "Send metrics to command as input over stdin"
+}
+
+func (e *Exec) Write(metrics []telegraf.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	b, err := e.serializer.SerializeBatch(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to serialize metrics: %v", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(e.Command[0], e.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stderr = &stderr
+
+	if err := internal.RunTimeout(cmd, e.Timeout.Duration); err != nil {
+		return fmt.Errorf("%s failed: %v%s", e.Command[0], err, truncate(stderr.Bytes()))
+	}
+	return nil
+}
+
+// truncate formats the first line of the error output of the command.
+func truncate(stderr []byte) string {
+	stderr = bytes.TrimSpace(stderr)
+	if len(stderr) == 0 {
+		return ""
+	}
+
+	truncated := false
+	if len(stderr) > maxStderrBytes {
+		stderr = stderr[:maxStderrBytes]
+		truncated = true
+	}
+	if i := bytes.IndexAny(stderr, "\r\n"); i >= 0 {
+		stderr = stderr[:i]
+		truncated = true
+	}
+	if truncated {
+		return fmt.Sprintf(": %s...", stderr)
+	}
+	return fmt.Sprintf(": %s", stderr)
+}
+
+func init() {
+	outputs.Add("exec", func() telegraf.Output {
+		return New()
+	})
+}
@@ -0,0 +1,71 @@
+// +build !windows
+
+package exec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newExec(t *testing.T, command ...string) *Exec {
+	e := New()
+	e.Command = command
+	serializer, err := serializers.NewInfluxSerializer()
+	require.NoError(t, err)
+	e.SetSerializer(serializer)
+	require.NoError(t, e.Connect())
+	return e
+}
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.out")
+
+	e := newExec(t, "sh", "-c", "cat > "+path)
+	metrics := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 42.0},
+			time.Unix(0, 0)),
+		testutil.MustMetric("mem",
+			map[string]string{},
+			map[string]interface{}{"value": 1.0},
+			time.Unix(0, 0)),
+	}
+	require.NoError(t, e.Write(metrics))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "cpu value=42 0\nmem value=1 0\n", string(b))
+}
+
+func TestWriteFailure(t *testing.T) {
+	e := newExec(t, "sh", "-c", "cat > /dev/null; echo 'write failed' >&2; echo 'details' >&2; exit 1")
+
+	err := e.Write(testutil.MockMetrics())
+	require.Error(t, err)
+	require.True(t, strings.HasSuffix(err.Error(), ": write failed..."), err.Error())
+}
+
+func TestTimeout(t *testing.T) {
+	e := newExec(t, "sleep", "10")
+	e.Timeout = internal.Duration{Duration: 10 * time.Millisecond}
+
+	require.Error(t, e.Write(testutil.MockMetrics()))
+}
+
+func TestNoCommand(t *testing.T) {
+	require.Error(t, New().Connect())
+}
@@ -0,0 +1,194 @@
+package execd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/process"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/serializers"
+)
+
+var sampleConfig = `
+  ## Program to run as daemon, the first element is the executable and the
+  ## others its arguments.  The serialized metrics are written on its
+  ## standard input.
+  command = ["cat"]
+
+  ## Delay before the process is restarted after an unexpected termination,
+  ## doubled on every restart of a process exiting quickly, up to 5m.
+  restart_delay = "10s"
+
+  ## Maximum time to write a batch of metrics to the process.  A process not
+  ## reading the batch in time is restarted, and the batch written again at
+  ## the next flush.
+  # timeout = "5s"
+
+  ## Data format to output.
+  ## Each data format has its own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
+  data_format = "influx"
+`
+
+type Execd struct {
+	Command      []string          `toml:"command"`
+	RestartDelay internal.Duration `toml:"restart_delay"`
+	Timeout      internal.Duration `toml:"timeout"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	serializer serializers.Serializer
+	process    *process.Process
+
+	// writing is closed once the last write to the process returns, which
+	// may be after Write timed out.
+	writing chan struct{}
+}
+
+func New() *Execd {
+	return &Execd{
+		RestartDelay: internal.Duration{Duration: 10 * time.Second},
+		Timeout:      internal.Duration{Duration: 5 * time.Second},
+	}
+}
+
+func (e *Execd) SetSerializer(serializer serializers.Serializer) {
+	e.serializer = serializer
+}
+
+func (e *Execd) Connect() error {
+	if e.Timeout.Duration <= 0 {
+		return fmt.Errorf("timeout must be positive, found %s", e.Timeout.Duration)
+	}
+
+	p, err := process.New(e.Command)
+	if err != nil {
+		return fmt.Errorf("error creating process: %v", err)
+	}
+	p.Log = e.Log
+	p.RestartDelay = e.RestartDelay.Duration
+	p.ReadStdout = e.readStdout
+	p.ReadStderr = e.readStderr
+
+	if err := p.Start(); err != nil {
+		return err
+	}
+	e.process = p
+	return nil
+}
+
+func (e *Execd) Close() error {
+	if e.process != nil {
+		e.process.Stop()
+		e.process = nil
+	}
+	return nil
+}
+
+func (e *Execd) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Execd) Description() string {
+	return "Run executable as long-running output plugin"
+}
+
+// SetLogger implements telegraf.LoggerPlugin.
+func (e *Execd) SetLogger(l telegraf.Logger) {
+	e.Log = l
+}
+
+// Write writes the metrics to the process, the batch is kept by the output
+// when the process is not running or does not read it within the timeout.  A
+// process not reading in time is restarted, failing the write left in the
+// background, so that it does not receive the batch again once it is resent.
+func (e *Execd) Write(metrics []telegraf.Metric) error {
+	timer := time.NewTimer(e.Timeout.Duration)
+	defer timer.Stop()
+
+	// A write which timed out goes on in the background until the process is
+	// restarted, the next batch waits for it so that their metrics are not
+	// interleaved.
+	if e.writing != nil {
+		select {
+		case <-e.writing:
+		case <-timer.C:
+			return errors.New("previous write to process still in progress")
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		b, err := e.serializer.Serialize(m)
+		if err != nil {
+			e.Log.Errorf("could not serialize metric: %v", err)
+			continue
+		}
+		buf.Write(b)
+	}
+
+	p := e.process
+	written := make(chan error, 1)
+	writing := make(chan struct{})
+	e.writing = writing
+	go func() {
+		defer close(writing)
+		_, err := p.Write(buf.Bytes())
+		written <- err
+	}()
+
+	select {
+	case err := <-written:
+		if err != nil {
+			return fmt.Errorf("error writing to process: %v", err)
+		}
+		return nil
+	case <-timer.C:
+		p.Restart()
+		return fmt.Errorf("timeout writing to process after %s, restarting it", e.Timeout.Duration)
+	}
+}
+
+// readStdout logs the output of the process, which is not expected to
+// output anything but messages.
+func (e *Execd) readStdout(r io.Reader) {
+	scanner := process.NewLineScanner(r)
+	for scanner.Scan() {
+		line, err := scanner.Line()
+		if err != nil {
+			e.Log.Errorf("error reading stdout: %v", err)
+			continue
+		}
+		e.Log.Infof("stdout: %s", line)
+	}
+	if err := scanner.Err(); err != nil {
+		e.Log.Errorf("error reading stdout: %v", err)
+	}
+}
+
+func (e *Execd) readStderr(r io.Reader) {
+	scanner := process.NewLineScanner(r)
+	for scanner.Scan() {
+		line, err := scanner.Line()
+		if err != nil {
+			e.Log.Errorf("error reading stderr: %v", err)
+			continue
+		}
+		e.Log.Errorf("stderr: %s", line)
+	}
+	if err := scanner.Err(); err != nil {
+		e.Log.Errorf("error reading stderr: %v", err)
+	}
+}
+
+func init() {
+	outputs.Add("execd", func() telegraf.Output {
+		return New()
+	})
+}
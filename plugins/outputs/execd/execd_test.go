@@ -0,0 +1,132 @@
+// +build !windows
+
+package execd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newExecd(t *testing.T, command ...string) *Execd {
+	e := New()
+	e.Command = command
+	e.Log = testutil.Logger{}
+	serializer, err := serializers.NewInfluxSerializer()
+	require.NoError(t, err)
+	e.SetSerializer(serializer)
+	return e
+}
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "execd")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.out")
+
+	e := newExecd(t, "sh", "-c", `while read line; do echo "$line" >> `+path+`; done`)
+	require.NoError(t, e.Connect())
+	defer e.Close()
+
+	metrics := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 42.0},
+			time.Unix(0, 0)),
+	}
+	require.NoError(t, e.Write(metrics))
+	require.NoError(t, e.Write(metrics))
+
+	expected := "cpu value=42 0\ncpu value=42 0\n"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, _ := ioutil.ReadFile(path)
+		if string(b) == expected {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "timeout waiting for %q, found %q", expected, b)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWriteNotRunning(t *testing.T) {
+	e := newExecd(t, "true")
+	e.RestartDelay = internal.Duration{Duration: time.Hour}
+	require.NoError(t, e.Connect())
+	defer e.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for e.Write(testutil.MockMetrics()) == nil {
+		require.True(t, time.Now().Before(deadline), "timeout waiting for write error")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	e := newExecd(t, "sh", "-c", "exec sleep 10")
+	e.Timeout = internal.Duration{Duration: 100 * time.Millisecond}
+	require.NoError(t, e.Connect())
+	defer e.Close()
+
+	// Without a reader the write blocks once the pipe is full.
+	var metrics []telegraf.Metric
+	for i := 0; i < 10000; i++ {
+		metrics = append(metrics, testutil.TestMetric(i))
+	}
+	start := time.Now()
+	require.Error(t, e.Write(metrics))
+	require.Error(t, e.Write(metrics))
+	require.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestWriteTimeoutRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "execd")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.out")
+	started := filepath.Join(dir, "started")
+
+	// The first run does not read its input, the next ones copy it.
+	e := newExecd(t, "sh", "-c", `if [ -e `+started+` ]; then exec cat >> `+path+`; fi; touch `+started+`; exec sleep 60`)
+	e.Timeout = internal.Duration{Duration: 100 * time.Millisecond}
+	e.RestartDelay = internal.Duration{Duration: time.Hour}
+	require.NoError(t, e.Connect())
+	defer e.Close()
+
+	var metrics []telegraf.Metric
+	for i := 0; i < 10000; i++ {
+		metrics = append(metrics, testutil.TestMetric(i))
+	}
+	require.Error(t, e.Write(metrics))
+
+	// The batch is only received once when written again to the restarted
+	// process.
+	deadline := time.Now().Add(10 * time.Second)
+	for e.Write(metrics) != nil {
+		require.True(t, time.Now().Before(deadline), "timeout waiting for write")
+		time.Sleep(10 * time.Millisecond)
+	}
+	e.Close()
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, len(metrics), bytes.Count(b, []byte("\n")))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	require.Error(t, New().Connect())
+	require.Error(t, newExecd(t, "/nonexistent").Connect())
+
+	e := newExecd(t, "cat")
+	e.Timeout = internal.Duration{}
+	require.Error(t, e.Connect())
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"fmt"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Compares checks that a field compares as required in every metric which
+// has it.  Only the comparisons which are set are made.
+type Compares struct {
+	Field string   `toml:"field"`
+	GT    *float64 `toml:"gt"`
+	GE    *float64 `toml:"ge"`
+	LT    *float64 `toml:"lt"`
+	LE    *float64 `toml:"le"`
+	EQ    *float64 `toml:"eq"`
+	NE    *float64 `toml:"ne"`
+}
+
+// Check returns an error describing the first metric failing the check.
+func (c *Compares) Check(metrics []telegraf.Metric) error {
+	for _, m := range metrics {
+		value, ok := m.GetField(c.Field)
+		if !ok {
+			continue
+		}
+
+		f, ok := toFloat(value)
+		if !ok {
+			return fmt.Errorf("%s: field %s is not a number", m.Name(), c.Field)
+		}
+		if op, ok := c.compare(f); !ok {
+			return fmt.Errorf("%s: field %s is %v, expected %s",
+				m.Name(), c.Field, value, op)
+		}
+	}
+	return nil
+}
+
+// compare returns false and the failed comparison if the value does not
+// compare as required.
+func (c *Compares) compare(f float64) (string, bool) {
+	switch {
+	case c.GT != nil && !(f > *c.GT):
+		return fmt.Sprintf("> %v", *c.GT), false
+	case c.GE != nil && !(f >= *c.GE):
+		return fmt.Sprintf(">= %v", *c.GE), false
+	case c.LT != nil && !(f < *c.LT):
+		return fmt.Sprintf("< %v", *c.LT), false
+	case c.LE != nil && !(f <= *c.LE):
+		return fmt.Sprintf("<= %v", *c.LE), false
+	case c.EQ != nil && !(f == *c.EQ):
+		return fmt.Sprintf("== %v", *c.EQ), false
+	case c.NE != nil && !(f != *c.NE):
+		return fmt.Sprintf("!= %v", *c.NE), false
+	}
+	return "", true
+}
+
+// Contains checks that at least one metric has a field.
+type Contains struct {
+	Field string `toml:"field"`
+}
+
+// Check returns an error if no metric has the field.
+func (c *Contains) Check(metrics []telegraf.Metric) error {
+	for _, m := range metrics {
+		if m.HasField(c.Field) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no metric has field %s", c.Field)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,322 @@
+package health
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	tlsint "github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+const (
+	defaultServiceAddress = ":8080"
+	defaultReadTimeout    = 5 * time.Second
+	defaultWriteTimeout   = 5 * time.Second
+)
+
+var sampleConfig = `
+  ## Address and port to serve the health status on.  A request to any path
+  ## returns 200 when healthy and 503 when a check fails.
+  # service_address = ":8080"
+
+  ## Maximum duration before timing out read of the request
+  # read_timeout = "5s"
+  ## Maximum duration before timing out write of the response
+  # write_timeout = "5s"
+
+  ## Optional username and password to accept for HTTP basic authentication.
+  # basic_username = "user1"
+  # basic_password = "secret"
+
+  ## Set one or more allowed client CA certificate file names to
+  ## enable mutually authenticated TLS connections
+  # tls_allowed_cacerts = ["/etc/telegraf/clientca.pem"]
+
+  ## Add service certificate and key
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+
+  ## Unhealthy when another output has buffered metrics but has not written
+  ## successfully for this long.
+  # max_time_since_write = "5m"
+
+  ## Unhealthy when the buffer of another output is fuller than this
+  ## fraction of its limit.
+  # max_buffer_fill = 0.9
+
+  ## Checks over the metrics written to this output, use metric filtering to
+  ## select the metrics checked.  The checks are run over all metrics of a
+  ## flush, and each flush with metrics replaces the result of the previous
+  ## one.
+  # namepass = ["internal_write"]
+  # tagpass = { output = ["influxdb"] }
+
+  ## Unhealthy when no metrics have been checked for this long, zero never
+  ## expires the results of the checks.
+  # max_check_age = "0s"
+
+  ## Unhealthy when the field of a metric does not compare as required.
+  # [[outputs.health.compares]]
+  #   field = "buffer_size"
+  #   lt = 5000.0
+
+  ## Unhealthy when no metric of a flush has the field.
+  # [[outputs.health.contains]]
+  #   field = "buffer_size"
+`
+
+// Health serves the health of the agent over HTTP, so that orchestrators
+// can restart an agent which stopped writing.
+type Health struct {
+	ServiceAddress string            `toml:"service_address"`
+	ReadTimeout    internal.Duration `toml:"read_timeout"`
+	WriteTimeout   internal.Duration `toml:"write_timeout"`
+	BasicUsername  string            `toml:"basic_username"`
+	BasicPassword  string            `toml:"basic_password"`
+	tlsint.ServerConfig
+
+	MaxTimeSinceWrite internal.Duration `toml:"max_time_since_write"`
+	MaxBufferFill     float64           `toml:"max_buffer_fill"`
+
+	MaxCheckAge internal.Duration `toml:"max_check_age"`
+	Compares    []*Compares       `toml:"compares"`
+	Contains    []*Contains       `toml:"contains"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	status   func() []telegraf.OutputStatus
+	now      func() time.Time
+	listener net.Listener
+	server   *http.Server
+	wg       sync.WaitGroup
+
+	// metrics are the metrics added since the last flush.
+	metrics []telegraf.Metric
+
+	mu sync.Mutex
+	// failures are the failed metric checks of the last flush with metrics,
+	// run at checked.
+	failures []string
+	checked  time.Time
+}
+
+func (h *Health) SampleConfig() string {
+	return sampleConfig
+}
+
+func (h *Health) Description() string {
+	return "Serve the health of the agent over HTTP"
+}
+
+// SetLogger implements telegraf.LoggerPlugin.
+func (h *Health) SetLogger(l telegraf.Logger) {
+	h.Log = l
+}
+
+// SetStatus implements telegraf.StatusOutput.
+func (h *Health) SetStatus(status func() []telegraf.OutputStatus) {
+	h.status = status
+}
+
+func (h *Health) Connect() error {
+	h.mu.Lock()
+	h.checked = h.time()
+	h.mu.Unlock()
+
+	if h.ServiceAddress == "" {
+		h.ServiceAddress = defaultServiceAddress
+	}
+	if h.ReadTimeout.Duration == 0 {
+		h.ReadTimeout.Duration = defaultReadTimeout
+	}
+	if h.WriteTimeout.Duration == 0 {
+		h.WriteTimeout.Duration = defaultWriteTimeout
+	}
+
+	tlsConf, err := h.ServerConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	if tlsConf != nil {
+		h.listener, err = tls.Listen("tcp", h.ServiceAddress, tlsConf)
+	} else {
+		h.listener, err = net.Listen("tcp", h.ServiceAddress)
+	}
+	if err != nil {
+		return err
+	}
+
+	h.server = &http.Server{
+		Handler:      h,
+		ReadTimeout:  h.ReadTimeout.Duration,
+		WriteTimeout: h.WriteTimeout.Duration,
+	}
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		err := h.server.Serve(h.listener)
+		if err != nil && err != http.ErrServerClosed {
+			h.Log.Errorf("Error serving health status: %v", err)
+		}
+	}()
+
+	h.Log.Infof("Listening on %s", h.listener.Addr())
+	return nil
+}
+
+func (h *Health) Close() error {
+	if h.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := h.server.Shutdown(ctx)
+	h.wg.Wait()
+	return err
+}
+
+// Write is not called with any metrics, they are checked on each flush by
+// Push.
+func (h *Health) Write(metrics []telegraf.Metric) error {
+	return nil
+}
+
+// Add implements telegraf.AggregatingOutput, keeping the metric until the
+// flush so that the checks see all metrics of the flush and not a batch.
+func (h *Health) Add(m telegraf.Metric) {
+	h.metrics = append(h.metrics, m)
+}
+
+// Push runs the metric checks over the metrics added since the last flush.
+// A flush without metrics keeps the results of the previous one.
+func (h *Health) Push() []telegraf.Metric {
+	if len(h.metrics) == 0 {
+		return nil
+	}
+
+	var failures []string
+	for _, check := range h.Compares {
+		if err := check.Check(h.metrics); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	for _, check := range h.Contains {
+		if err := check.Check(h.metrics); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	h.mu.Lock()
+	h.failures = failures
+	h.checked = h.time()
+	h.mu.Unlock()
+	return nil
+}
+
+// Reset releases the metrics checked by Push.
+func (h *Health) Reset() {
+	for i, m := range h.metrics {
+		m.Accept()
+		h.metrics[i] = nil
+	}
+	h.metrics = h.metrics[:0]
+}
+
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	failures := h.check()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(failures) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(failures, "\n"))
+		return
+	}
+	fmt.Fprintln(w, "OK")
+}
+
+func (h *Health) authorized(r *http.Request) bool {
+	if h.BasicUsername == "" && h.BasicPassword == "" {
+		return true
+	}
+	username, password, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(h.BasicUsername)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(h.BasicPassword)) == 1
+}
+
+// time returns the current time.
+func (h *Health) time() time.Time {
+	if h.now != nil {
+		return h.now()
+	}
+	return time.Now()
+}
+
+// check returns the failed checks, the metric checks of the last flush
+// followed by those of the other outputs.
+func (h *Health) check() []string {
+	now := h.time()
+
+	h.mu.Lock()
+	failures := append([]string(nil), h.failures...)
+	checked := h.checked
+	h.mu.Unlock()
+
+	age := now.Sub(checked)
+	if h.MaxCheckAge.Duration > 0 && len(h.Compares)+len(h.Contains) > 0 &&
+		age > h.MaxCheckAge.Duration {
+		failures = append(failures, fmt.Sprintf(
+			"no metrics checked for %s", age.Truncate(time.Second)))
+	}
+
+	if h.status == nil {
+		return failures
+	}
+
+	for _, status := range h.status() {
+		if h.MaxBufferFill > 0 && status.BufferLimit > 0 {
+			fill := float64(status.BufferSize) / float64(status.BufferLimit)
+			if fill > h.MaxBufferFill {
+				failures = append(failures, fmt.Sprintf(
+					"outputs.%s: buffer is %.0f%% full", status.Name, fill*100))
+			}
+		}
+
+		since := now.Sub(status.LastWrite)
+		if h.MaxTimeSinceWrite.Duration > 0 && status.BufferSize > 0 &&
+			since > h.MaxTimeSinceWrite.Duration {
+			failures = append(failures, fmt.Sprintf(
+				"outputs.%s: no successful write for %s",
+				status.Name, since.Truncate(time.Second)))
+		}
+	}
+	return failures
+}
+
+func init() {
+	outputs.Add("health", func() telegraf.Output {
+		return &Health{}
+	})
+}
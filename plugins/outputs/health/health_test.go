@@ -0,0 +1,244 @@
+package health
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/influxdata/toml"
+	"github.com/stretchr/testify/require"
+)
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func statusCode(t *testing.T, h *Health) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	return rec.Code
+}
+
+// flush checks the metrics as done by the agent on each flush.
+func flush(h *Health, metrics ...telegraf.Metric) {
+	for _, m := range metrics {
+		h.Add(m)
+	}
+	h.Push()
+	h.Reset()
+}
+
+func TestHealthChecks(t *testing.T) {
+	tests := []struct {
+		name     string
+		health   *Health
+		metrics  []telegraf.Metric
+		expected int
+	}{
+		{
+			name:     "no checks",
+			health:   &Health{},
+			metrics:  []telegraf.Metric{testutil.TestMetric(1.0)},
+			expected: http.StatusOK,
+		},
+		{
+			name: "compare passes",
+			health: &Health{
+				Compares: []*Compares{{Field: "value", GT: float(0), LT: float(5)}},
+			},
+			metrics:  []telegraf.Metric{testutil.TestMetric(1.0)},
+			expected: http.StatusOK,
+		},
+		{
+			name: "compare fails",
+			health: &Health{
+				Compares: []*Compares{{Field: "value", LT: float(5)}},
+			},
+			metrics: []telegraf.Metric{
+				testutil.TestMetric(1.0),
+				testutil.TestMetric(int64(5)),
+			},
+			expected: http.StatusServiceUnavailable,
+		},
+		{
+			name: "compare ignores metrics without the field",
+			health: &Health{
+				Compares: []*Compares{{Field: "buffer_size", EQ: float(0)}},
+			},
+			metrics:  []telegraf.Metric{testutil.TestMetric(1.0)},
+			expected: http.StatusOK,
+		},
+		{
+			name: "compare fails on strings",
+			health: &Health{
+				Compares: []*Compares{{Field: "value", NE: float(0)}},
+			},
+			metrics:  []telegraf.Metric{testutil.TestMetric("ok")},
+			expected: http.StatusServiceUnavailable,
+		},
+		{
+			name: "contains passes",
+			health: &Health{
+				Contains: []*Contains{{Field: "value"}},
+			},
+			metrics:  []telegraf.Metric{testutil.TestMetric(1.0)},
+			expected: http.StatusOK,
+		},
+		{
+			name: "contains fails",
+			health: &Health{
+				Contains: []*Contains{{Field: "buffer_size"}},
+			},
+			metrics:  []telegraf.Metric{testutil.TestMetric(1.0)},
+			expected: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, http.StatusOK, statusCode(t, tt.health))
+			flush(tt.health, tt.metrics...)
+			require.Equal(t, tt.expected, statusCode(t, tt.health))
+		})
+	}
+}
+
+func TestHealthFlush(t *testing.T) {
+	h := &Health{
+		Compares: []*Compares{{Field: "value", LT: float(5)}},
+		Contains: []*Contains{{Field: "value"}},
+	}
+
+	// A failure in one batch is not cleared by a later batch of the flush.
+	h.Add(testutil.TestMetric(int64(10)))
+	h.Add(testutil.TestMetric(int64(1)))
+	require.NoError(t, h.Write(nil))
+	h.Push()
+	h.Reset()
+	require.Equal(t, http.StatusServiceUnavailable, statusCode(t, h))
+
+	// A flush without metrics keeps the results.
+	flush(h)
+	require.Equal(t, http.StatusServiceUnavailable, statusCode(t, h))
+
+	flush(h, testutil.TestMetric(int64(1)))
+	require.Equal(t, http.StatusOK, statusCode(t, h))
+
+	// The field is contained by any metric of the flush.
+	other := func() telegraf.Metric {
+		return testutil.MustMetric("other",
+			map[string]string{}, map[string]interface{}{"x": 1.0}, time.Now())
+	}
+	flush(h, other(), testutil.TestMetric(int64(1)))
+	require.Equal(t, http.StatusOK, statusCode(t, h))
+	flush(h, other())
+	require.Equal(t, http.StatusServiceUnavailable, statusCode(t, h))
+}
+
+func TestHealthMaxCheckAge(t *testing.T) {
+	now := time.Unix(1000, 0)
+	h := &Health{
+		ServiceAddress: "127.0.0.1:0",
+		MaxCheckAge:    internal.Duration{Duration: time.Minute},
+		Contains:       []*Contains{{Field: "value"}},
+		Log:            testutil.Logger{},
+		now:            func() time.Time { return now },
+	}
+	require.NoError(t, h.Connect())
+	defer h.Close()
+	require.Equal(t, http.StatusOK, statusCode(t, h))
+
+	now = now.Add(2 * time.Minute)
+	require.Equal(t, http.StatusServiceUnavailable, statusCode(t, h))
+
+	flush(h, testutil.TestMetric(1.0))
+	require.Equal(t, http.StatusOK, statusCode(t, h))
+
+	// The results expire when the metrics stop.
+	now = now.Add(30 * time.Second)
+	flush(h)
+	require.Equal(t, http.StatusOK, statusCode(t, h))
+	now = now.Add(time.Minute)
+	require.Equal(t, http.StatusServiceUnavailable, statusCode(t, h))
+}
+
+func TestHealthOutputStatus(t *testing.T) {
+	now := time.Unix(1000, 0)
+	status := []telegraf.OutputStatus{
+		{Name: "influxdb", BufferSize: 50, BufferLimit: 100, LastWrite: now},
+	}
+
+	h := &Health{
+		MaxBufferFill:     0.8,
+		MaxTimeSinceWrite: internal.Duration{Duration: time.Minute},
+		now:               func() time.Time { return now },
+	}
+	h.SetStatus(func() []telegraf.OutputStatus { return status })
+	require.Equal(t, http.StatusOK, statusCode(t, h))
+
+	status[0].BufferSize = 90
+	require.Equal(t, http.StatusServiceUnavailable, statusCode(t, h))
+
+	// An output without buffered metrics does not need to write.
+	status[0].BufferSize = 0
+	status[0].LastWrite = now.Add(-time.Hour)
+	require.Equal(t, http.StatusOK, statusCode(t, h))
+
+	status[0].BufferSize = 10
+	require.Equal(t, http.StatusServiceUnavailable, statusCode(t, h))
+}
+
+func TestHealthBasicAuth(t *testing.T) {
+	h := &Health{BasicUsername: "user", BasicPassword: "secret"}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.SetBasicAuth("user", "secret")
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHealthConnect(t *testing.T) {
+	h := &Health{ServiceAddress: "127.0.0.1:0", Log: testutil.Logger{}}
+	require.NoError(t, h.Connect())
+
+	resp, err := http.Get("http://" + h.listener.Addr().String() + "/health")
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "OK\n", string(body))
+
+	require.NoError(t, h.Close())
+}
+
+func TestHealthConfig(t *testing.T) {
+	h := &Health{}
+	err := toml.Unmarshal([]byte(`
+max_buffer_fill = 0.5
+max_check_age = "10m"
+
+[[compares]]
+  field = "buffer_size"
+  lt = 5000.0
+
+[[contains]]
+  field = "buffer_size"
+`), h)
+	require.NoError(t, err)
+	require.Equal(t, 0.5, h.MaxBufferFill)
+	require.Equal(t, 10*time.Minute, h.MaxCheckAge.Duration)
+	require.Len(t, h.Compares, 1)
+	require.Equal(t, float(5000), h.Compares[0].LT)
+	require.Nil(t, h.Compares[0].GT)
+	require.Len(t, h.Contains, 1)
+}
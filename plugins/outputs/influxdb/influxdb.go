@@ -32,7 +32,7 @@ type Client interface {
 
 // InfluxDB struct is the primary data structure for the plugin
 type InfluxDB struct {
-	URL                  string   // url deprecated in 0.1.9; use urls
+	URL                  string   `deprecated:"0.1.9;use urls"`
 	URLs                 []string `toml:"urls"`
 	Username             string
 	Password             string
@@ -49,7 +49,7 @@ type InfluxDB struct {
 	InfluxUintSupport    bool              `toml:"influx_uint_support"`
 	tls.ClientConfig
 
-	Precision string // precision deprecated in 1.0; value is ignored
+	Precision string `deprecated:"1.0;value is ignored"`
 
 	clients []Client
 
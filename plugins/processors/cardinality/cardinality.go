@@ -0,0 +1,366 @@
+package cardinality
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+const (
+	actionDrop  = "drop"
+	actionStrip = "strip"
+)
+
+var sampleConfig = `
+  ## Stage of the pipeline the processor is applied in, one of "inputs",
+  ## "aggregators" or "outputs".  It must be set, as the summary metrics are
+  ## returned to that stage only.
+  stage = "inputs"
+
+  ## Maximum number of distinct series of each measurement within a window.
+  ## When tag_keys is set, the maximum number of distinct values of each of
+  ## the tags of each measurement instead.
+  # limit = 1000
+
+  ## Duration after which the counts are reset.
+  # window = "1h"
+
+  ## Maximum number of distinct measurements counted within a window, the
+  ## metrics of the measurements over it are handled as over the limit.
+  # max_measurements = 1000
+
+  ## Limit the distinct values of these tags, instead of the series.
+  # tag_keys = ["request_id"]
+
+  ## What to do with a metric over the limit:
+  ##   drop:  drop the metric
+  ##   strip: remove the tags over the limit and keep the metric, requires
+  ##          tag_keys
+  # action = "drop"
+
+  ## Name of the summary metric, emitted at the end of each window for every
+  ## measurement which went over the limit, and without the measurement tag
+  ## for the measurements over max_measurements.
+  # summary_measurement = "cardinality_limit"
+`
+
+// Cardinality limits the number of distinct series, or tag values, of each
+// measurement within a window.
+//
+// Only the hashes of the series under the limit are kept, and only for up to
+// max_measurements measurements, so memory is bounded by the limit times
+// max_measurements.
+type Cardinality struct {
+	Limit              int               `toml:"limit"`
+	Window             internal.Duration `toml:"window"`
+	MaxMeasurements    int               `toml:"max_measurements"`
+	TagKeys            []string          `toml:"tag_keys"`
+	Action             string            `toml:"action"`
+	SummaryMeasurement string            `toml:"summary_measurement"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	initialized bool
+	err         error
+	now         func() time.Time
+	windowStart time.Time
+	counters    map[key]*counter
+	// measurements are the measurements counted in the window.
+	measurements map[string]struct{}
+}
+
+// key identifies a counter, the tag key is empty when counting series.  The
+// zero key counts the metrics of the measurements over max_measurements.
+type key struct {
+	measurement string
+	tagKey      string
+}
+
+type counter struct {
+	seen     map[uint64]struct{}
+	dropped  int64
+	stripped int64
+}
+
+// admit returns true if the hash was seen or can still be added.
+func (c *counter) admit(hash uint64, limit int) bool {
+	if _, ok := c.seen[hash]; ok {
+		return true
+	}
+	return len(c.seen) < limit
+}
+
+func New() *Cardinality {
+	return &Cardinality{
+		Limit:              1000,
+		Window:             internal.Duration{Duration: time.Hour},
+		MaxMeasurements:    1000,
+		Action:             actionDrop,
+		SummaryMeasurement: "cardinality_limit",
+		now:                time.Now,
+	}
+}
+
+func (c *Cardinality) SampleConfig() string {
+	return sampleConfig
+}
+
+func (c *Cardinality) Description() string {
+	return "Limit the number of distinct series of each measurement"
+}
+
+// SetLogger implements telegraf.LoggerPlugin.
+func (c *Cardinality) SetLogger(l telegraf.Logger) {
+	c.Log = l
+}
+
+func (c *Cardinality) init() error {
+	c.initialized = true
+	switch c.Action {
+	case actionDrop:
+	case actionStrip:
+		if len(c.TagKeys) == 0 {
+			return fmt.Errorf("action %q requires tag_keys", c.Action)
+		}
+	default:
+		return fmt.Errorf("unknown action %q", c.Action)
+	}
+	if c.Limit <= 0 {
+		return fmt.Errorf("limit must be positive, found %d", c.Limit)
+	}
+	if c.MaxMeasurements <= 0 {
+		return fmt.Errorf("max_measurements must be positive, found %d", c.MaxMeasurements)
+	}
+	return nil
+}
+
+func (c *Cardinality) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	if !c.initialized {
+		c.err = c.init()
+		if c.err != nil {
+			c.Log.Errorf("%v, metrics are not limited", c.err)
+		}
+	}
+	if c.err != nil {
+		return in
+	}
+
+	var out []telegraf.Metric
+	now := c.now()
+	if c.counters == nil || now.Sub(c.windowStart) >= c.Window.Duration {
+		out = c.startWindow(now)
+	}
+
+	for _, m := range in {
+		if !c.admitMeasurement(m.Name()) {
+			if !c.overflow(m) {
+				m.Drop()
+				continue
+			}
+		} else if len(c.TagKeys) == 0 {
+			if !c.admitSeries(m) {
+				m.Drop()
+				continue
+			}
+		} else if !c.admitTags(m) {
+			m.Drop()
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// Flush ends the window once it has passed, returning its summary.
+func (c *Cardinality) Flush() []telegraf.Metric {
+	if c.err != nil || c.counters == nil {
+		return nil
+	}
+
+	now := c.now()
+	if now.Sub(c.windowStart) < c.Window.Duration {
+		return nil
+	}
+	return c.startWindow(now)
+}
+
+// Stop returns the summary of the current window.
+func (c *Cardinality) Stop() []telegraf.Metric {
+	if c.err != nil || c.counters == nil {
+		return nil
+	}
+	return c.summary(c.now())
+}
+
+// startWindow resets the counts and returns the summary of the previous
+// window.
+func (c *Cardinality) startWindow(now time.Time) []telegraf.Metric {
+	metrics := c.summary(now)
+	c.windowStart = now
+	c.counters = make(map[key]*counter)
+	c.measurements = make(map[string]struct{})
+	return metrics
+}
+
+func (c *Cardinality) counter(k key) *counter {
+	ctr, ok := c.counters[k]
+	if !ok {
+		ctr = &counter{seen: make(map[uint64]struct{})}
+		c.counters[k] = ctr
+	}
+	return ctr
+}
+
+// admitMeasurement returns false if the measurement is new and the window
+// already counts max_measurements measurements.
+func (c *Cardinality) admitMeasurement(name string) bool {
+	if _, ok := c.measurements[name]; ok {
+		return true
+	}
+	if len(c.measurements) >= c.MaxMeasurements {
+		return false
+	}
+	c.measurements[name] = struct{}{}
+	return true
+}
+
+// overflow returns false if the metric of a measurement over
+// max_measurements is to be dropped.  When stripping, all the tag keys are
+// removed instead.
+func (c *Cardinality) overflow(m telegraf.Metric) bool {
+	ctr := c.counter(key{})
+	if c.Action == actionDrop {
+		ctr.dropped++
+		return false
+	}
+
+	stripped := false
+	for _, tagKey := range c.TagKeys {
+		if m.HasTag(tagKey) {
+			m.RemoveTag(tagKey)
+			stripped = true
+		}
+	}
+	if stripped {
+		ctr.stripped++
+	}
+	return true
+}
+
+// admitSeries returns false if the metric is a new series of a measurement
+// at the limit.
+func (c *Cardinality) admitSeries(m telegraf.Metric) bool {
+	ctr := c.counter(key{measurement: m.Name()})
+	id := m.HashID()
+	if !ctr.admit(id, c.Limit) {
+		ctr.dropped++
+		return false
+	}
+	ctr.seen[id] = struct{}{}
+	return true
+}
+
+// admitTags returns false if the metric has a new value of a tag at the
+// limit and is to be dropped.  When stripping, the tags over the limit are
+// removed instead.
+func (c *Cardinality) admitTags(m telegraf.Metric) bool {
+	type value struct {
+		ctr    *counter
+		tagKey string
+		hash   uint64
+	}
+	var admitted, over []value
+	for _, tagKey := range c.TagKeys {
+		v, ok := m.GetTag(tagKey)
+		if !ok {
+			continue
+		}
+		ctr := c.counter(key{measurement: m.Name(), tagKey: tagKey})
+		h := hashValue(v)
+		if ctr.admit(h, c.Limit) {
+			admitted = append(admitted, value{ctr, tagKey, h})
+		} else {
+			over = append(over, value{ctr, tagKey, h})
+		}
+	}
+
+	if len(over) > 0 && c.Action == actionDrop {
+		for _, v := range over {
+			v.ctr.dropped++
+		}
+		return false
+	}
+
+	for _, v := range over {
+		v.ctr.stripped++
+		m.RemoveTag(v.tagKey)
+	}
+	for _, v := range admitted {
+		v.ctr.seen[v.hash] = struct{}{}
+	}
+	return true
+}
+
+// summary returns a metric for each counter which went over the limit, the
+// metric of the measurements over max_measurements has no measurement tag.
+func (c *Cardinality) summary(now time.Time) []telegraf.Metric {
+	keys := make([]key, 0, len(c.counters))
+	for k, ctr := range c.counters {
+		if ctr.dropped > 0 || ctr.stripped > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].measurement != keys[j].measurement {
+			return keys[i].measurement < keys[j].measurement
+		}
+		return keys[i].tagKey < keys[j].tagKey
+	})
+
+	metrics := make([]telegraf.Metric, 0, len(keys))
+	for _, k := range keys {
+		ctr := c.counters[k]
+		tags := map[string]string{"measurement": k.measurement}
+		fields := map[string]interface{}{
+			"limit":   int64(c.Limit),
+			"dropped": ctr.dropped,
+		}
+		if k.tagKey != "" {
+			tags["tag_key"] = k.tagKey
+			fields["stripped"] = ctr.stripped
+		}
+		if k == (key{}) {
+			delete(tags, "measurement")
+			fields["limit"] = int64(c.MaxMeasurements)
+			if len(c.TagKeys) > 0 {
+				fields["stripped"] = ctr.stripped
+			}
+		}
+
+		m, err := metric.New(c.SummaryMeasurement, tags, fields, now)
+		if err != nil {
+			c.Log.Errorf("Could not create summary: %v", err)
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func hashValue(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func init() {
+	processors.Add("cardinality", func() telegraf.Processor {
+		return New()
+	})
+}
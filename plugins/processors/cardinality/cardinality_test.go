@@ -0,0 +1,296 @@
+package cardinality
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func names(metrics []telegraf.Metric) []string {
+	var result []string
+	for _, m := range metrics {
+		result = append(result, m.Name()+","+m.Tags()["id"])
+	}
+	return result
+}
+
+func TestSeriesLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New()
+	c.Limit = 2
+	c.Log = testutil.Logger{}
+	c.now = func() time.Time { return now }
+
+	out := c.Apply(
+		testutil.MustMetric("cpu", map[string]string{"id": "1"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "2"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "3"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("mem", map[string]string{"id": "3"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+	)
+	require.Equal(t, []string{"cpu,1", "cpu,2", "mem,3"}, names(out))
+
+	// Known series are still accepted.
+	out = c.Apply(
+		testutil.MustMetric("cpu", map[string]string{"id": "1"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "4"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+	)
+	require.Equal(t, []string{"cpu,1"}, names(out))
+}
+
+func TestWindowSummary(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New()
+	c.Limit = 1
+	c.Log = testutil.Logger{}
+	c.now = func() time.Time { return now }
+
+	out := c.Apply(
+		testutil.MustMetric("cpu", map[string]string{"id": "1"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "2"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "3"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+	)
+	require.Equal(t, []string{"cpu,1"}, names(out))
+
+	now = now.Add(time.Hour)
+
+	// The summary is emitted and the counts reset.
+	out = c.Apply(testutil.MustMetric("cpu", map[string]string{"id": "2"},
+		map[string]interface{}{"value": 1}, time.Unix(0, 0)))
+	require.Len(t, out, 2)
+	require.Equal(t, "cardinality_limit", out[0].Name())
+	require.Equal(t, map[string]string{"measurement": "cpu"}, out[0].Tags())
+	require.Equal(t, map[string]interface{}{
+		"limit":   int64(1),
+		"dropped": int64(2),
+	}, out[0].Fields())
+	require.Equal(t, "cpu,2", names(out[1:])[0])
+}
+
+func TestWindowSummaryFlush(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New()
+	c.Limit = 1
+	c.Log = testutil.Logger{}
+	c.now = func() time.Time { return now }
+
+	require.Empty(t, c.Flush())
+
+	out := c.Apply(
+		testutil.MustMetric("cpu", map[string]string{"id": "1"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "2"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+	)
+	require.Equal(t, []string{"cpu,1"}, names(out))
+	require.Empty(t, c.Flush())
+
+	// The summary is emitted once the window ends, without waiting for the
+	// next metrics.
+	now = now.Add(time.Hour)
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cardinality_limit",
+			map[string]string{"measurement": "cpu"},
+			map[string]interface{}{"limit": int64(1), "dropped": int64(1)},
+			now),
+	}
+	testutil.RequireMetricsEqual(t, expected, c.Flush())
+	require.Empty(t, c.Flush())
+
+	// The counts were reset.
+	out = c.Apply(
+		testutil.MustMetric("cpu", map[string]string{"id": "2"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "3"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+	)
+	require.Equal(t, []string{"cpu,2"}, names(out))
+
+	// The summary of the current window is returned when stopping.
+	now = now.Add(time.Minute)
+	expected = []telegraf.Metric{
+		testutil.MustMetric("cardinality_limit",
+			map[string]string{"measurement": "cpu"},
+			map[string]interface{}{"limit": int64(1), "dropped": int64(1)},
+			now),
+	}
+	testutil.RequireMetricsEqual(t, expected, c.Stop())
+}
+
+func TestTagKeysDrop(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New()
+	c.Limit = 1
+	c.Log = testutil.Logger{}
+	c.now = func() time.Time { return now }
+	c.TagKeys = []string{"id"}
+
+	out := c.Apply(
+		testutil.MustMetric("cpu", map[string]string{"id": "1", "host": "a"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "1", "host": "b"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "2", "host": "a"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"host": "c"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+	)
+	require.Len(t, out, 3)
+	require.Equal(t, "b", out[1].Tags()["host"])
+	require.Equal(t, map[string]string{"host": "c"}, out[2].Tags())
+}
+
+func TestTagKeysStrip(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New()
+	c.Limit = 1
+	c.Log = testutil.Logger{}
+	c.now = func() time.Time { return now }
+	c.TagKeys = []string{"id"}
+	c.Action = "strip"
+
+	out := c.Apply(
+		testutil.MustMetric("cpu", map[string]string{"id": "1", "host": "a"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "2", "host": "a"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+	)
+	require.Len(t, out, 2)
+	require.Equal(t, map[string]string{"id": "1", "host": "a"}, out[0].Tags())
+	require.Equal(t, map[string]string{"host": "a"}, out[1].Tags())
+
+	now = now.Add(time.Hour)
+
+	out = c.Apply()
+	require.Len(t, out, 1)
+	require.Equal(t, map[string]string{
+		"measurement": "cpu",
+		"tag_key":     "id",
+	}, out[0].Tags())
+	require.Equal(t, map[string]interface{}{
+		"limit":    int64(1),
+		"dropped":  int64(0),
+		"stripped": int64(1),
+	}, out[0].Fields())
+}
+
+func TestMaxMeasurements(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New()
+	c.Limit = 10
+	c.Log = testutil.Logger{}
+	c.now = func() time.Time { return now }
+	c.MaxMeasurements = 2
+
+	out := c.Apply(
+		testutil.MustMetric("cpu", map[string]string{"id": "1"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("mem", map[string]string{"id": "1"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("disk", map[string]string{"id": "1"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("net", map[string]string{"id": "1"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "2"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+	)
+	require.Equal(t, []string{"cpu,1", "mem,1", "cpu,2"}, names(out))
+	require.Len(t, c.counters, 3)
+
+	now = now.Add(time.Hour)
+
+	out = c.Apply(testutil.MustMetric("disk", map[string]string{"id": "1"},
+		map[string]interface{}{"value": 1}, time.Unix(0, 0)))
+	require.Len(t, out, 2)
+	require.Equal(t, map[string]string{}, out[0].Tags())
+	require.Equal(t, map[string]interface{}{
+		"limit":   int64(2),
+		"dropped": int64(2),
+	}, out[0].Fields())
+	require.Equal(t, "disk,1", names(out[1:])[0])
+}
+
+func TestMaxMeasurementsStrip(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New()
+	c.Limit = 10
+	c.Log = testutil.Logger{}
+	c.now = func() time.Time { return now }
+	c.MaxMeasurements = 1
+	c.TagKeys = []string{"id"}
+	c.Action = "strip"
+
+	out := c.Apply(
+		testutil.MustMetric("cpu", map[string]string{"id": "1", "host": "a"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("mem", map[string]string{"id": "1", "host": "a"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+	)
+	require.Len(t, out, 2)
+	require.Equal(t, map[string]string{"id": "1", "host": "a"}, out[0].Tags())
+	require.Equal(t, map[string]string{"host": "a"}, out[1].Tags())
+
+	now = now.Add(time.Hour)
+
+	out = c.Apply()
+	require.Len(t, out, 1)
+	require.Equal(t, map[string]string{}, out[0].Tags())
+	require.Equal(t, map[string]interface{}{
+		"limit":    int64(1),
+		"dropped":  int64(0),
+		"stripped": int64(1),
+	}, out[0].Fields())
+}
+
+func TestDroppedMetricsAreDelivered(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New()
+	c.Limit = 1
+	c.Log = testutil.Logger{}
+	c.now = func() time.Time { return now }
+
+	var delivered []telegraf.DeliveryInfo
+	notify := func(di telegraf.DeliveryInfo) { delivered = append(delivered, di) }
+	m, _ := metric.WithTracking(testutil.MustMetric("cpu", map[string]string{"id": "1"},
+		map[string]interface{}{"value": 1}, time.Unix(0, 0)), notify)
+	over, _ := metric.WithTracking(testutil.MustMetric("cpu", map[string]string{"id": "2"},
+		map[string]interface{}{"value": 1}, time.Unix(0, 0)), notify)
+
+	// Dropped metrics are not rejected, so that inputs do not retry them.
+	out := c.Apply(m, over)
+	require.Len(t, out, 1)
+	require.Len(t, delivered, 1)
+	require.True(t, delivered[0].Delivered())
+	out[0].Accept()
+	require.Len(t, delivered, 2)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New()
+	c.Limit = 1
+	c.Log = testutil.Logger{}
+	c.now = func() time.Time { return now }
+	c.Action = "strip"
+
+	// Without tag_keys the metrics are passed unmodified.
+	out := c.Apply(
+		testutil.MustMetric("cpu", map[string]string{"id": "1"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{"id": "2"},
+			map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+	)
+	require.Len(t, out, 2)
+}
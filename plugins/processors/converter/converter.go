@@ -2,7 +2,6 @@ package converter
 
 import (
 	"fmt"
-	"log"
 	"math"
 	"strconv"
 
@@ -51,6 +50,8 @@ type Converter struct {
 	Tags   *Conversion `toml:"tags"`
 	Fields *Conversion `toml:"fields"`
 
+	Log telegraf.Logger `toml:"-"`
+
 	initialized      bool
 	tagConversions   *ConversionFilter
 	fieldConversions *ConversionFilter
@@ -73,11 +74,16 @@ func (p *Converter) Description() string {
 	return "Convert values to another metric value type"
 }
 
+// SetLogger implements telegraf.LoggerPlugin.
+func (p *Converter) SetLogger(l telegraf.Logger) {
+	p.Log = l
+}
+
 func (p *Converter) Apply(metrics ...telegraf.Metric) []telegraf.Metric {
 	if !p.initialized {
 		err := p.compile()
 		if err != nil {
-			logPrintf("initialization error: %v\n", err)
+			p.Log.Debugf("initialization error: %v", err)
 			return metrics
 		}
 	}
@@ -167,7 +173,7 @@ func (p *Converter) convertTags(metric telegraf.Metric) {
 			v, ok := toInteger(value)
 			if !ok {
 				metric.RemoveTag(key)
-				logPrintf("error converting to integer [%T]: %v\n", value, value)
+				p.Log.Debugf("error converting to integer [%T]: %v", value, value)
 				continue
 			}
 
@@ -179,7 +185,7 @@ func (p *Converter) convertTags(metric telegraf.Metric) {
 			v, ok := toUnsigned(value)
 			if !ok {
 				metric.RemoveTag(key)
-				logPrintf("error converting to unsigned [%T]: %v\n", value, value)
+				p.Log.Debugf("error converting to unsigned [%T]: %v", value, value)
 				continue
 			}
 
@@ -192,7 +198,7 @@ func (p *Converter) convertTags(metric telegraf.Metric) {
 			v, ok := toBool(value)
 			if !ok {
 				metric.RemoveTag(key)
-				logPrintf("error converting to boolean [%T]: %v\n", value, value)
+				p.Log.Debugf("error converting to boolean [%T]: %v", value, value)
 				continue
 			}
 
@@ -205,7 +211,7 @@ func (p *Converter) convertTags(metric telegraf.Metric) {
 			v, ok := toFloat(value)
 			if !ok {
 				metric.RemoveTag(key)
-				logPrintf("error converting to float [%T]: %v\n", value, value)
+				p.Log.Debugf("error converting to float [%T]: %v", value, value)
 				continue
 			}
 
@@ -227,7 +233,7 @@ func (p *Converter) convertFields(metric telegraf.Metric) {
 			v, ok := toString(value)
 			if !ok {
 				metric.RemoveField(key)
-				logPrintf("error converting to tag [%T]: %v\n", value, value)
+				p.Log.Debugf("error converting to tag [%T]: %v", value, value)
 				continue
 			}
 
@@ -240,7 +246,7 @@ func (p *Converter) convertFields(metric telegraf.Metric) {
 			v, ok := toFloat(value)
 			if !ok {
 				metric.RemoveField(key)
-				logPrintf("error converting to integer [%T]: %v\n", value, value)
+				p.Log.Debugf("error converting to integer [%T]: %v", value, value)
 				continue
 			}
 
@@ -253,7 +259,7 @@ func (p *Converter) convertFields(metric telegraf.Metric) {
 			v, ok := toInteger(value)
 			if !ok {
 				metric.RemoveField(key)
-				logPrintf("error converting to integer [%T]: %v\n", value, value)
+				p.Log.Debugf("error converting to integer [%T]: %v", value, value)
 				continue
 			}
 
@@ -266,7 +272,7 @@ func (p *Converter) convertFields(metric telegraf.Metric) {
 			v, ok := toUnsigned(value)
 			if !ok {
 				metric.RemoveField(key)
-				logPrintf("error converting to unsigned [%T]: %v\n", value, value)
+				p.Log.Debugf("error converting to unsigned [%T]: %v", value, value)
 				continue
 			}
 
@@ -279,7 +285,7 @@ func (p *Converter) convertFields(metric telegraf.Metric) {
 			v, ok := toBool(value)
 			if !ok {
 				metric.RemoveField(key)
-				logPrintf("error converting to bool [%T]: %v\n", value, value)
+				p.Log.Debugf("error converting to bool [%T]: %v", value, value)
 				continue
 			}
 
@@ -292,7 +298,7 @@ func (p *Converter) convertFields(metric telegraf.Metric) {
 			v, ok := toString(value)
 			if !ok {
 				metric.RemoveField(key)
-				logPrintf("error converting to string [%T]: %v\n", value, value)
+				p.Log.Debugf("error converting to string [%T]: %v", value, value)
 				continue
 			}
 
@@ -419,10 +425,6 @@ func toString(v interface{}) (string, bool) {
 	return "", false
 }
 
-func logPrintf(format string, v ...interface{}) {
-	log.Printf("D! [processors.converter] "+format, v...)
-}
-
 func init() {
 	processors.Add("converter", func() telegraf.Processor {
 		return &Converter{}
@@ -0,0 +1,115 @@
+package dedup
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+var sampleConfig = `
+  ## Maximum time to suppress output of an unchanged series, it is also the
+  ## time after which a series which was not seen is forgotten.
+  dedup_interval = "600s"
+`
+
+// Dedup suppresses the metrics of a series while their field values are
+// unchanged.
+type Dedup struct {
+	DedupInterval internal.Duration `toml:"dedup_interval"`
+
+	now       func() time.Time
+	lastClean time.Time
+	cache     map[uint64]*entry
+}
+
+// entry is the last emitted metric of a series.
+type entry struct {
+	fields map[string]interface{}
+	// emitted is the timestamp of the metric.
+	emitted time.Time
+	// seen is the time the metric was emitted, to expire the entry.
+	seen time.Time
+}
+
+func New() *Dedup {
+	return &Dedup{
+		DedupInterval: internal.Duration{Duration: 10 * time.Minute},
+		now:           time.Now,
+		cache:         make(map[uint64]*entry),
+	}
+}
+
+func (d *Dedup) SampleConfig() string {
+	return sampleConfig
+}
+
+func (d *Dedup) Description() string {
+	return "Filter metrics with repeating field values"
+}
+
+func (d *Dedup) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	now := d.now()
+	out := in[:0]
+	for _, m := range in {
+		id := m.HashID()
+		e, ok := d.cache[id]
+		if ok && m.Time().Before(e.emitted) {
+			// A metric older than the emitted one is passed, without
+			// replacing the latest values of the series.
+			out = append(out, m)
+			continue
+		}
+		if ok && m.Time().Sub(e.emitted) < d.DedupInterval.Duration &&
+			!changed(e.fields, m) {
+			m.Drop()
+			continue
+		}
+
+		d.cache[id] = &entry{fields: m.Fields(), emitted: m.Time(), seen: now}
+		out = append(out, m)
+	}
+
+	d.clean(now)
+	return out
+}
+
+// changed returns true if the fields of the metric differ from the emitted
+// fields, including fields which were added or removed.
+func changed(fields map[string]interface{}, m telegraf.Metric) bool {
+	list := m.FieldList()
+	if len(list) != len(fields) {
+		return true
+	}
+	for _, field := range list {
+		value, ok := fields[field.Key]
+		if !ok || value != field.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// clean forgets the series which were not emitted within the interval, at
+// most once per interval.  The interval is compared to the time of the
+// emission and not to the timestamps of the metrics, which may be from any
+// clock.
+func (d *Dedup) clean(now time.Time) {
+	if now.Sub(d.lastClean) < d.DedupInterval.Duration {
+		return
+	}
+	d.lastClean = now
+
+	for id, e := range d.cache {
+		if now.Sub(e.seen) >= d.DedupInterval.Duration {
+			delete(d.cache, id)
+		}
+	}
+}
+
+func init() {
+	processors.Add("dedup", func() telegraf.Processor {
+		return New()
+	})
+}
@@ -0,0 +1,142 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuppressUnchanged(t *testing.T) {
+	now := time.Unix(1000, 0)
+	d := New()
+	d.now = func() time.Time { return now }
+
+	out := d.Apply(
+		testutil.MustMetric("cpu", map[string]string{"host": "a"},
+			map[string]interface{}{"value": 1}, now),
+		testutil.MustMetric("cpu", map[string]string{"host": "b"},
+			map[string]interface{}{"value": 1}, now),
+	)
+	require.Len(t, out, 2)
+
+	out = d.Apply(
+		testutil.MustMetric("cpu", map[string]string{"host": "a"},
+			map[string]interface{}{"value": 1}, now.Add(time.Second)),
+		testutil.MustMetric("cpu", map[string]string{"host": "b"},
+			map[string]interface{}{"value": 2}, now.Add(time.Second)),
+	)
+	require.Len(t, out, 1)
+	require.Equal(t, "b", out[0].Tags()["host"])
+
+	// Adding or removing a field is a change.
+	out = d.Apply(
+		testutil.MustMetric("cpu", map[string]string{"host": "a"},
+			map[string]interface{}{"value": 1, "other": 1}, now.Add(2*time.Second)),
+		testutil.MustMetric("cpu", map[string]string{"host": "b"},
+			map[string]interface{}{}, now.Add(2*time.Second)),
+	)
+	require.Len(t, out, 2)
+}
+
+func TestRefreshAfterInterval(t *testing.T) {
+	now := time.Unix(1000, 0)
+	d := New()
+	d.now = func() time.Time { return now }
+
+	out := d.Apply(testutil.MustMetric("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1}, now))
+	require.Len(t, out, 1)
+
+	out = d.Apply(testutil.MustMetric("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1}, now.Add(9*time.Minute)))
+	require.Len(t, out, 0)
+
+	out = d.Apply(testutil.MustMetric("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1}, now.Add(10*time.Minute)))
+	require.Len(t, out, 1)
+
+	// The refresh restarts the interval.
+	out = d.Apply(testutil.MustMetric("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1}, now.Add(15*time.Minute)))
+	require.Len(t, out, 0)
+}
+
+func TestExpireStaleSeries(t *testing.T) {
+	now := time.Unix(1000, 0)
+	d := New()
+	d.now = func() time.Time { return now }
+
+	d.Apply(testutil.MustMetric("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1}, now))
+	require.Len(t, d.cache, 1)
+
+	now = now.Add(10 * time.Minute)
+
+	b := testutil.MustMetric("cpu", map[string]string{"host": "b"},
+		map[string]interface{}{"value": 1}, now)
+	d.Apply(b)
+	require.Len(t, d.cache, 1)
+	_, ok := d.cache[b.HashID()]
+	require.True(t, ok)
+}
+
+func TestExpireByClock(t *testing.T) {
+	now := time.Unix(1000, 0)
+	d := New()
+	d.now = func() time.Time { return now }
+
+	// Series are expired by the time they were emitted, not their timestamp.
+	d.Apply(testutil.MustMetric("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1}, now.Add(-time.Hour)))
+	d.Apply(testutil.MustMetric("cpu", map[string]string{"host": "b"},
+		map[string]interface{}{"value": 1}, now.Add(time.Hour)))
+	require.Len(t, d.cache, 2)
+
+	now = now.Add(5 * time.Minute)
+	d.lastClean = time.Time{}
+	d.Apply()
+	require.Len(t, d.cache, 2)
+}
+
+func TestPassOutOfOrder(t *testing.T) {
+	now := time.Unix(1000, 0)
+	d := New()
+	d.now = func() time.Time { return now }
+
+	out := d.Apply(testutil.MustMetric("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1}, now.Add(time.Minute)))
+	require.Len(t, out, 1)
+
+	out = d.Apply(testutil.MustMetric("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1}, now))
+	require.Len(t, out, 1)
+
+	// The latest emitted values are kept.
+	out = d.Apply(testutil.MustMetric("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1}, now.Add(2*time.Minute)))
+	require.Len(t, out, 0)
+}
+
+func TestSuppressedMetricsAreDelivered(t *testing.T) {
+	now := time.Unix(1000, 0)
+	d := New()
+	d.now = func() time.Time { return now }
+
+	var delivered []telegraf.DeliveryInfo
+	notify := func(di telegraf.DeliveryInfo) { delivered = append(delivered, di) }
+	first, _ := metric.WithTracking(testutil.MustMetric("cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1}, now), notify)
+	second, _ := metric.WithTracking(testutil.MustMetric("cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1}, now.Add(time.Second)), notify)
+
+	out := d.Apply(first, second)
+	require.Len(t, out, 1)
+	require.Len(t, delivered, 1)
+	require.True(t, delivered[0].Delivered())
+}
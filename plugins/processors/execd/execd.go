@@ -0,0 +1,359 @@
+package execd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/process"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/plugins/serializers"
+)
+
+var sampleConfig = `
+  ## Stage of the pipeline the processor is applied in, one of "inputs",
+  ## "aggregators" or "outputs".  It must be set, as the metrics output by the
+  ## program are returned to that stage only.
+  stage = "inputs"
+
+  ## Program to run as daemon, the first element is the executable and the
+  ## others its arguments.  The program reads metrics on its standard input
+  ## and writes the processed metrics on its standard output.
+  command = ["cat"]
+
+  ## Delay before the process is restarted after an unexpected termination,
+  ## doubled on every restart of a process exiting quickly, up to 5m.
+  restart_delay = "10s"
+
+  ## Maximum number of metrics waiting to be written to the process, and of
+  ## metrics read from the process waiting to be returned.  Metrics are
+  ## rejected while the process does not keep up.
+  # max_in_flight = 1000
+
+  ## Data format used to write metrics to the process and read them back.
+  ## Each data format has its own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
+  data_format = "influx"
+`
+
+// stopTimeout is how long the process has to read the queued metrics, and
+// then to exit once its input is closed, when the processor is stopped.
+const stopTimeout = 5 * time.Second
+
+var errStopped = errors.New("processor is stopped")
+
+// Execd streams metrics through a long-running program.
+//
+// Metrics are written to the program in the background, so that Apply never
+// blocks on it, and the metrics it outputs are returned by the next call to
+// Apply or Flush.
+type Execd struct {
+	Command      []string          `toml:"command"`
+	RestartDelay internal.Duration `toml:"restart_delay"`
+	MaxInFlight  int               `toml:"max_in_flight"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	parser     parsers.Parser
+	serializer serializers.Serializer
+
+	initialized bool
+	err         error
+	process     *process.Process
+	queue       chan telegraf.Metric
+	results     chan telegraf.Metric
+	written     chan struct{}
+	stopped     chan struct{}
+	stopTimeout time.Duration
+
+	// mu guards the derivation tracking the output of the process, its
+	// parents are the metrics written since the previous output.
+	mu         sync.Mutex
+	derivation *metric.Derivation
+	derived    bool
+	parents    int
+}
+
+func New() *Execd {
+	return &Execd{
+		RestartDelay: internal.Duration{Duration: 10 * time.Second},
+		MaxInFlight:  1000,
+		stopTimeout:  stopTimeout,
+	}
+}
+
+func (e *Execd) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Execd) Description() string {
+	return "Run executable as long-running processor plugin"
+}
+
+// SetLogger implements telegraf.LoggerPlugin.
+func (e *Execd) SetLogger(l telegraf.Logger) {
+	e.Log = l
+}
+
+func (e *Execd) SetParser(parser parsers.Parser) {
+	e.parser = parser
+}
+
+func (e *Execd) SetSerializer(serializer serializers.Serializer) {
+	e.serializer = serializer
+}
+
+func (e *Execd) init() error {
+	e.initialized = true
+	if e.parser == nil || e.serializer == nil {
+		return errors.New("no data format set")
+	}
+	if e.MaxInFlight <= 0 {
+		return fmt.Errorf("max_in_flight must be positive, found %d", e.MaxInFlight)
+	}
+
+	p, err := process.New(e.Command)
+	if err != nil {
+		return fmt.Errorf("error creating process: %v", err)
+	}
+	p.Log = e.Log
+	p.RestartDelay = e.RestartDelay.Duration
+	p.ExitTimeout = e.stopTimeout
+	p.ReadStdout = e.readStdout
+	p.ReadStderr = e.readStderr
+
+	e.queue = make(chan telegraf.Metric, e.MaxInFlight)
+	e.results = make(chan telegraf.Metric, e.MaxInFlight)
+	e.written = make(chan struct{})
+	e.stopped = make(chan struct{})
+	if err := p.Start(); err != nil {
+		return err
+	}
+	e.process = p
+
+	go e.write(p)
+	return nil
+}
+
+func (e *Execd) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	if !e.initialized {
+		e.err = e.init()
+		if e.err != nil {
+			e.Log.Errorf("%v, metrics are not processed", e.err)
+		}
+	}
+	if e.err != nil {
+		return in
+	}
+
+	rejected := 0
+	for _, m := range in {
+		select {
+		case e.queue <- m:
+		default:
+			m.Reject()
+			rejected++
+		}
+	}
+	if rejected > 0 {
+		e.Log.Warnf("queue is full, rejected %d metrics", rejected)
+	}
+
+	return e.drain()
+}
+
+// Flush returns the metrics output by the process since the last call to
+// Apply or Flush.
+func (e *Execd) Flush() []telegraf.Metric {
+	return e.drain()
+}
+
+// Stop writes the queued metrics to the process and closes its input, then
+// returns the metrics it outputs until it exits.
+func (e *Execd) Stop() []telegraf.Metric {
+	p := e.process
+	e.process = nil
+	e.initialized = true
+	if e.err == nil {
+		e.err = errStopped
+	}
+	if p == nil {
+		return nil
+	}
+
+	close(e.queue)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// A process no longer reading its input is stopped without waiting
+		// for the queue, the remaining metrics are rejected.
+		timer := time.NewTimer(e.stopTimeout)
+		defer timer.Stop()
+		select {
+		case <-e.written:
+		case <-timer.C:
+			e.Log.Warnf("process did not read the queued metrics within %s", e.stopTimeout)
+		}
+		p.Stop()
+		<-e.written
+	}()
+
+	var out []telegraf.Metric
+collect:
+	for {
+		select {
+		case m := <-e.results:
+			out = append(out, m)
+		case <-done:
+			break collect
+		}
+	}
+	out = append(out, e.drain()...)
+
+	e.mu.Lock()
+	if e.derivation != nil {
+		e.derivation.Done()
+		e.derivation = nil
+	}
+	e.mu.Unlock()
+	close(e.stopped)
+	return out
+}
+
+// drain returns the metrics waiting in the results.  The derivation which
+// output metrics is done, so that its parents are delivered with them.
+func (e *Execd) drain() []telegraf.Metric {
+	var out []telegraf.Metric
+collect:
+	for {
+		select {
+		case m := <-e.results:
+			out = append(out, m)
+		default:
+			break collect
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.derived {
+		e.derivation.Done()
+		e.derivation = nil
+		e.derived = false
+	}
+	return out
+}
+
+// write writes the queued metrics to the process until the queue is closed.
+// Written metrics are delivered along with the next metrics output by the
+// process.
+func (e *Execd) write(p *process.Process) {
+	defer close(e.written)
+	for m := range e.queue {
+		b, err := e.serializer.Serialize(m)
+		if err != nil {
+			e.Log.Errorf("could not serialize metric: %v", err)
+			m.Drop()
+			continue
+		}
+		if _, err := p.Write(b); err != nil {
+			e.Log.Errorf("could not write metric: %v", err)
+			m.Reject()
+			continue
+		}
+		e.track(m)
+		m.Drop()
+	}
+}
+
+// track adds a written metric to the parents of the next output of the
+// process.  A new derivation is started once the previous one has output
+// metrics, or holds max_in_flight parents so that the metrics filtered out by
+// the process are not held indefinitely.
+func (e *Execd) track(m telegraf.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.derivation == nil || e.derived || e.parents >= e.MaxInFlight {
+		if e.derivation != nil {
+			e.derivation.Done()
+		}
+		e.derivation = metric.NewDerivation()
+		e.derived = false
+		e.parents = 0
+	}
+	e.derivation.Add(m)
+	e.parents++
+}
+
+// derive returns a metric output by the process, tracked by the metrics
+// written before it.
+func (e *Execd) derive(m telegraf.Metric) telegraf.Metric {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.derivation == nil {
+		return m
+	}
+	e.derived = true
+	return e.derivation.Track(m)
+}
+
+func (e *Execd) readStdout(r io.Reader) {
+	scanner := process.NewLineScanner(r)
+	for scanner.Scan() {
+		line, err := scanner.Line()
+		if err != nil {
+			e.Log.Errorf("error reading stdout: %v", err)
+			continue
+		}
+		metrics, err := e.parser.Parse(line)
+		if err != nil {
+			e.Log.Errorf("parse error: %v", err)
+			continue
+		}
+		// The results are collected by Stop until the process exits, a
+		// reader outliving the process has nowhere to send its metrics.
+		for _, m := range metrics {
+			m = e.derive(m)
+			select {
+			case e.results <- m:
+			case <-e.stopped:
+				m.Drop()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		e.Log.Errorf("error reading stdout: %v", err)
+	}
+}
+
+// readStderr logs each line of the standard error of the process.
+func (e *Execd) readStderr(r io.Reader) {
+	scanner := process.NewLineScanner(r)
+	for scanner.Scan() {
+		line, err := scanner.Line()
+		if err != nil {
+			e.Log.Errorf("error reading stderr: %v", err)
+			continue
+		}
+		e.Log.Errorf("stderr: %s", line)
+	}
+	if err := scanner.Err(); err != nil {
+		e.Log.Errorf("error reading stderr: %v", err)
+	}
+}
+
+func init() {
+	processors.Add("execd", func() telegraf.Processor {
+		return New()
+	})
+}
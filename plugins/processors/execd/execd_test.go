@@ -0,0 +1,248 @@
+// +build !windows
+
+package execd
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newExecd(t *testing.T, command ...string) *Execd {
+	e := New()
+	e.Command = command
+	e.Log = testutil.Logger{}
+	parser, err := parsers.NewInfluxParser()
+	require.NoError(t, err)
+	e.SetParser(parser)
+	serializer, err := serializers.NewInfluxSerializer()
+	require.NoError(t, err)
+	e.SetSerializer(serializer)
+	return e
+}
+
+// applyUntil applies the metrics and keeps calling Apply until n metrics are
+// returned.
+func applyUntil(t *testing.T, e *Execd, n int, in ...telegraf.Metric) []telegraf.Metric {
+	out := e.Apply(in...)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(out) < n {
+		require.True(t, time.Now().Before(deadline), "timeout waiting for metrics")
+		time.Sleep(10 * time.Millisecond)
+		out = append(out, e.Apply()...)
+	}
+	return out
+}
+
+func TestApply(t *testing.T) {
+	e := newExecd(t, "sh", "-c", `sed -u 's/^cpu/cpu_processed/'`)
+	defer e.Stop()
+
+	out := applyUntil(t, e, 2,
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{"value": int64(1)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{"value": int64(2)},
+			time.Unix(0, 0)),
+	)
+	require.Len(t, out, 2)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu_processed",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{"value": int64(1)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu_processed",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{"value": int64(2)},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, out)
+}
+
+func TestDelivery(t *testing.T) {
+	e := newExecd(t, "cat")
+	defer e.Stop()
+
+	delivered := make(chan telegraf.DeliveryInfo, 1)
+	notify := func(info telegraf.DeliveryInfo) {
+		delivered <- info
+	}
+	m, _ := metric.WithTracking(testutil.MustMetric("cpu",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 0)), notify)
+
+	// The input is delivered along with the output of the process.
+	out := applyUntil(t, e, 1, m)
+	require.Len(t, out, 1)
+	select {
+	case <-delivered:
+		t.Fatal("metric delivered before the output")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	out[0].Accept()
+	select {
+	case info := <-delivered:
+		require.True(t, info.Delivered())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for delivery")
+	}
+}
+
+func TestDeliveryRejected(t *testing.T) {
+	e := newExecd(t, "cat")
+	defer e.Stop()
+
+	delivered := make(chan telegraf.DeliveryInfo, 1)
+	notify := func(info telegraf.DeliveryInfo) {
+		delivered <- info
+	}
+	m, _ := metric.WithTracking(testutil.MustMetric("cpu",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 0)), notify)
+
+	out := applyUntil(t, e, 1, m)
+	require.Len(t, out, 1)
+	out[0].Reject()
+	select {
+	case info := <-delivered:
+		require.False(t, info.Delivered())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for delivery")
+	}
+}
+
+func TestDeliveryWithoutOutput(t *testing.T) {
+	e := newExecd(t, "sh", "-c", "cat >/dev/null")
+
+	delivered := make(chan telegraf.DeliveryInfo, 1)
+	notify := func(info telegraf.DeliveryInfo) {
+		delivered <- info
+	}
+	m, _ := metric.WithTracking(testutil.MustMetric("cpu",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 0)), notify)
+
+	// Metrics filtered out by the process are held until it outputs a
+	// metric, or until it stops.
+	require.Empty(t, e.Apply(m))
+	require.Empty(t, e.Stop())
+	select {
+	case info := <-delivered:
+		require.True(t, info.Delivered())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for delivery")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	e := newExecd(t, "cat")
+	defer e.Stop()
+
+	require.Empty(t, e.Flush())
+
+	out := e.Apply(testutil.MustMetric("cpu",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 0)))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(out) == 0 {
+		require.True(t, time.Now().Before(deadline), "timeout waiting for metrics")
+		time.Sleep(10 * time.Millisecond)
+		out = e.Flush()
+	}
+	require.Len(t, out, 1)
+}
+
+func TestQueueFull(t *testing.T) {
+	e := newExecd(t, "sh", "-c", "exec sleep 10")
+	e.MaxInFlight = 1
+	e.stopTimeout = 100 * time.Millisecond
+	defer e.Stop()
+
+	// Without a reader the writes block once the pipe is full, so one metric
+	// waits in the queue and the next ones are rejected.
+	var rejected int32
+	notify := func(info telegraf.DeliveryInfo) {
+		if !info.Delivered() {
+			atomic.AddInt32(&rejected, 1)
+		}
+	}
+	var in []telegraf.Metric
+	for i := 0; i < 10000; i++ {
+		m, _ := metric.WithTracking(testutil.MustMetric("cpu",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{"value": i},
+			time.Unix(0, 0)), notify)
+		in = append(in, m)
+	}
+	require.Empty(t, e.Apply(in...))
+	require.NotZero(t, atomic.LoadInt32(&rejected))
+}
+
+func TestStop(t *testing.T) {
+	// sort only outputs the metrics once its input is closed.
+	e := newExecd(t, "sort")
+
+	require.Empty(t, e.Apply(
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{"value": int64(2)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{"value": int64(1)},
+			time.Unix(0, 0)),
+	))
+	out := e.Stop()
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{"value": int64(1)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{"value": int64(2)},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, out)
+
+	require.Empty(t, e.Stop())
+	m := testutil.MustMetric("cpu",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"value": int64(3)},
+		time.Unix(0, 0))
+	require.Equal(t, []telegraf.Metric{m}, e.Apply(m))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	m := testutil.MustMetric("cpu",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 0))
+
+	e := New()
+	e.Command = []string{"cat"}
+	e.Log = testutil.Logger{}
+	require.Equal(t, []telegraf.Metric{m}, e.Apply(m))
+	e.Stop()
+
+	e = newExecd(t)
+	require.Equal(t, []telegraf.Metric{m}, e.Apply(m))
+	e.Stop()
+}
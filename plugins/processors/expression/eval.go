@@ -0,0 +1,570 @@
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/influxdata/telegraf"
+)
+
+var (
+	errDivisionByZero = errors.New("division by zero")
+	errOverflow       = errors.New("integer overflow")
+	errNotFinite      = errors.New("result is not a finite number")
+)
+
+// node is a compiled expression, evaluating to an int64, uint64, float64,
+// bool or string.
+type node interface {
+	eval(m telegraf.Metric) (interface{}, error)
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (n *literal) eval(telegraf.Metric) (interface{}, error) {
+	return n.value, nil
+}
+
+// identifier is a field of the metric, or a tag when there is no field of
+// the name.
+type identifier struct {
+	name string
+}
+
+func (n *identifier) eval(m telegraf.Metric) (interface{}, error) {
+	if v, ok := m.GetField(n.name); ok {
+		return v, nil
+	}
+	if v, ok := m.GetTag(n.name); ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("no field or tag %q", n.name)
+}
+
+type unary struct {
+	op string
+	x  node
+}
+
+func (n *unary) eval(m telegraf.Metric) (interface{}, error) {
+	x, err := n.x.eval(m)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "!":
+		if b, ok := x.(bool); ok {
+			return !b, nil
+		}
+	case "-":
+		switch x := x.(type) {
+		case int64:
+			if x == math.MinInt64 {
+				return nil, errOverflow
+			}
+			return -x, nil
+		case uint64:
+			if x > math.MaxInt64 {
+				return nil, errOverflow
+			}
+			return -int64(x), nil
+		case float64:
+			return -x, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid operation %s%T", n.op, x)
+}
+
+// logical is a boolean operator, the right operand is only evaluated when
+// needed.
+type logical struct {
+	op   string
+	x, y node
+}
+
+func (n *logical) eval(m telegraf.Metric) (interface{}, error) {
+	x, err := evalBool(n.x, m)
+	if err != nil {
+		return nil, err
+	}
+	if (n.op == "&&") != x {
+		return x, nil
+	}
+	return evalBool(n.y, m)
+}
+
+func evalBool(n node, m telegraf.Metric) (bool, error) {
+	v, err := n.eval(m)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected bool, found %T", v)
+	}
+	return b, nil
+}
+
+type binary struct {
+	op   string
+	x, y node
+}
+
+func (n *binary) eval(m telegraf.Metric) (interface{}, error) {
+	x, err := n.x.eval(m)
+	if err != nil {
+		return nil, err
+	}
+	y, err := n.y.eval(m)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return compare(n.op, x, y)
+	}
+
+	if x, ok := x.(string); ok && n.op == "+" {
+		if y, ok := y.(string); ok {
+			return x + y, nil
+		}
+	}
+	if !isNumber(x) || !isNumber(y) {
+		return nil, fmt.Errorf("invalid operation %T %s %T", x, n.op, y)
+	}
+
+	switch {
+	case isFloat(x) || isFloat(y):
+		return floatOp(n.op, toFloat(x), toFloat(y))
+	case isUint(x) && isUint(y):
+		return uintOp(n.op, x.(uint64), y.(uint64))
+	default:
+		a, err := toInt(x)
+		if err != nil {
+			return nil, err
+		}
+		b, err := toInt(y)
+		if err != nil {
+			return nil, err
+		}
+		return intOp(n.op, a, b)
+	}
+}
+
+func floatOp(op string, x, y float64) (interface{}, error) {
+	var v float64
+	switch op {
+	case "+":
+		v = x + y
+	case "-":
+		v = x - y
+	case "*":
+		v = x * y
+	case "/":
+		if y == 0 {
+			return nil, errDivisionByZero
+		}
+		v = x / y
+	case "%":
+		if y == 0 {
+			return nil, errDivisionByZero
+		}
+		v = math.Mod(x, y)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, errNotFinite
+	}
+	return v, nil
+}
+
+func intOp(op string, x, y int64) (interface{}, error) {
+	switch op {
+	case "+":
+		v := x + y
+		if (v > x) != (y > 0) {
+			return nil, errOverflow
+		}
+		return v, nil
+	case "-":
+		v := x - y
+		if (v < x) != (y > 0) {
+			return nil, errOverflow
+		}
+		return v, nil
+	case "*":
+		if x == 0 || y == 0 {
+			return int64(0), nil
+		}
+		v := x * y
+		if v/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+			return nil, errOverflow
+		}
+		return v, nil
+	case "/", "%":
+		if y == 0 {
+			return nil, errDivisionByZero
+		}
+		if x == math.MinInt64 && y == -1 {
+			return nil, errOverflow
+		}
+		if op == "/" {
+			return x / y, nil
+		}
+		return x % y, nil
+	}
+	return nil, fmt.Errorf("invalid operator %s", op)
+}
+
+func uintOp(op string, x, y uint64) (interface{}, error) {
+	switch op {
+	case "+":
+		v := x + y
+		if v < x {
+			return nil, errOverflow
+		}
+		return v, nil
+	case "-":
+		if y > x {
+			return nil, errOverflow
+		}
+		return x - y, nil
+	case "*":
+		v := x * y
+		if x != 0 && v/x != y {
+			return nil, errOverflow
+		}
+		return v, nil
+	case "/", "%":
+		if y == 0 {
+			return nil, errDivisionByZero
+		}
+		if op == "/" {
+			return x / y, nil
+		}
+		return x % y, nil
+	}
+	return nil, fmt.Errorf("invalid operator %s", op)
+}
+
+// compare compares numbers of any type, strings, or bools for equality.
+func compare(op string, x, y interface{}) (interface{}, error) {
+	var c int
+	switch {
+	case isNumber(x) && isNumber(y):
+		c = compareNumbers(x, y)
+	case isString(x) && isString(y):
+		a, b := x.(string), y.(string)
+		switch {
+		case a < b:
+			c = -1
+		case a > b:
+			c = 1
+		}
+	case isBool(x) && isBool(y) && (op == "==" || op == "!="):
+		if x != y {
+			c = 1
+		}
+	default:
+		return nil, fmt.Errorf("invalid comparison %T %s %T", x, op, y)
+	}
+
+	switch op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+// compareNumbers compares integers exactly, and as floats when either is a
+// float.
+func compareNumbers(x, y interface{}) int {
+	if isFloat(x) || isFloat(y) {
+		a, b := toFloat(x), toFloat(y)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+
+	// A negative int64 is lower than any uint64, the other integers fit in a
+	// uint64.
+	if a, ok := x.(int64); ok && a < 0 {
+		if b, ok := y.(int64); !ok || a < b {
+			return -1
+		} else if a == b {
+			return 0
+		}
+		return 1
+	}
+	if b, ok := y.(int64); ok && b < 0 {
+		return -compareNumbers(y, x)
+	}
+	a, b := toUint(x), toUint(y)
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int64, uint64, float64:
+		return true
+	}
+	return false
+}
+
+func isFloat(v interface{}) bool {
+	_, ok := v.(float64)
+	return ok
+}
+
+func isUint(v interface{}) bool {
+	_, ok := v.(uint64)
+	return ok
+}
+
+func isString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+func isBool(v interface{}) bool {
+	_, ok := v.(bool)
+	return ok
+}
+
+// toFloat converts a number to a float64.
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// toInt converts an integer to an int64.
+func toInt(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, errOverflow
+		}
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("expected integer, found %T", v)
+}
+
+// toUint converts a non-negative integer to a uint64.
+func toUint(v interface{}) uint64 {
+	switch v := v.(type) {
+	case int64:
+		return uint64(v)
+	case uint64:
+		return v
+	}
+	return 0
+}
+
+// format formats a value as a tag value.
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+type call struct {
+	name string
+	fn   func(m telegraf.Metric, args []interface{}) (interface{}, error)
+	args []node
+}
+
+func (n *call) eval(m telegraf.Metric) (interface{}, error) {
+	args := make([]interface{}, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := arg.eval(m)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+
+	v, err := n.fn(m, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", n.name, err)
+	}
+	return v, nil
+}
+
+type function struct {
+	// minArgs and maxArgs are the number of arguments accepted, maxArgs is
+	// -1 when there is no maximum.
+	minArgs, maxArgs int
+	fn               func(m telegraf.Metric, args []interface{}) (interface{}, error)
+}
+
+var functions = map[string]function{
+	"field": {1, 1, func(m telegraf.Metric, args []interface{}) (interface{}, error) {
+		name, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, found %T", args[0])
+		}
+		if v, ok := m.GetField(name); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("no field %q", name)
+	}},
+	"tag": {1, 1, func(m telegraf.Metric, args []interface{}) (interface{}, error) {
+		name, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, found %T", args[0])
+		}
+		if v, ok := m.GetTag(name); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("no tag %q", name)
+	}},
+	"has": {1, 1, func(m telegraf.Metric, args []interface{}) (interface{}, error) {
+		name, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, found %T", args[0])
+		}
+		return m.HasField(name) || m.HasTag(name), nil
+	}},
+	"float": {1, 1, func(_ telegraf.Metric, args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case bool:
+			if v {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, err
+			}
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return nil, errNotFinite
+			}
+			return f, nil
+		}
+		return toFloat(args[0]), nil
+	}},
+	"int": {1, 1, func(_ telegraf.Metric, args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case string:
+			return strconv.ParseInt(v, 10, 64)
+		case float64:
+			if math.IsNaN(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+				return nil, errOverflow
+			}
+			return int64(v), nil
+		}
+		return toInt(args[0])
+	}},
+	"uint": {1, 1, func(_ telegraf.Metric, args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case bool:
+			if v {
+				return uint64(1), nil
+			}
+			return uint64(0), nil
+		case string:
+			return strconv.ParseUint(v, 10, 64)
+		case float64:
+			if math.IsNaN(v) || v <= -1 || v >= math.MaxUint64 {
+				return nil, errOverflow
+			}
+			return uint64(v), nil
+		case int64:
+			if v < 0 {
+				return nil, errOverflow
+			}
+			return uint64(v), nil
+		}
+		return args[0], nil
+	}},
+	"string": {1, 1, func(_ telegraf.Metric, args []interface{}) (interface{}, error) {
+		return format(args[0]), nil
+	}},
+	"abs": {1, 1, func(_ telegraf.Metric, args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case int64:
+			if v == math.MinInt64 {
+				return nil, errOverflow
+			}
+			if v < 0 {
+				return -v, nil
+			}
+			return v, nil
+		case uint64:
+			return v, nil
+		case float64:
+			return math.Abs(v), nil
+		}
+		return nil, fmt.Errorf("expected number, found %T", args[0])
+	}},
+	"min": {2, -1, func(_ telegraf.Metric, args []interface{}) (interface{}, error) {
+		return extremum(args, -1)
+	}},
+	"max": {2, -1, func(_ telegraf.Metric, args []interface{}) (interface{}, error) {
+		return extremum(args, 1)
+	}},
+}
+
+// extremum returns the lowest of the numbers when sign is -1, and the
+// highest when sign is 1, keeping its type.
+func extremum(args []interface{}, sign int) (interface{}, error) {
+	result := args[0]
+	for _, v := range args {
+		if !isNumber(v) {
+			return nil, fmt.Errorf("expected number, found %T", v)
+		}
+		if compareNumbers(v, result) == sign {
+			result = v
+		}
+	}
+	return result, nil
+}
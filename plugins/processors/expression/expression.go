@@ -0,0 +1,198 @@
+package expression
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+var sampleConfig = `
+  ## Fields set to the result of an expression over the fields and tags of
+  ## the metric.  Rules are applied in order, after a rule fails the field is
+  ## left as is.
+  [[processors.expression.fields]]
+    ## Field to set, replaced if it exists.
+    key = "used_percent"
+    ## Expression computing the value.
+    expression = "float(used) / total * 100"
+    ## Optional expression which must be true for the field to be set.
+    # condition = "total > 0"
+
+  ## Tags set to the result of an expression, formatted as a string.  Tag
+  ## rules are applied after the field rules.
+  # [[processors.expression.tags]]
+  #   key = "level"
+  #   expression = "used_percent >= 90"
+`
+
+// warnInterval is the minimum time between two warnings about the errors of
+// a rule, the errors in between are counted.
+const warnInterval = time.Minute
+
+// Rule sets a field or tag to the result of an expression.
+type Rule struct {
+	Key        string `toml:"key"`
+	Expression string `toml:"expression"`
+	Condition  string `toml:"condition"`
+}
+
+type Expression struct {
+	Fields []Rule `toml:"fields"`
+	Tags   []Rule `toml:"tags"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	now         func() time.Time
+	initialized bool
+	err         error
+	fields      []*rule
+	tags        []*rule
+}
+
+// rule is a compiled Rule, condition is nil when it always applies.
+type rule struct {
+	key        string
+	expression node
+	condition  node
+
+	// warned is when an error of the rule was last logged, and suppressed
+	// the number of errors since.
+	warned     time.Time
+	suppressed int
+}
+
+func New() *Expression {
+	return &Expression{
+		now: time.Now,
+	}
+}
+
+func (e *Expression) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Expression) Description() string {
+	return "Set fields and tags from expressions over the metric"
+}
+
+// SetLogger implements telegraf.LoggerPlugin.
+func (e *Expression) SetLogger(l telegraf.Logger) {
+	e.Log = l
+}
+
+func (e *Expression) init() error {
+	e.initialized = true
+
+	var err error
+	e.fields, err = compile(e.Fields)
+	if err != nil {
+		return err
+	}
+	e.tags, err = compile(e.Tags)
+	return err
+}
+
+func compile(rules []Rule) ([]*rule, error) {
+	compiled := make([]*rule, 0, len(rules))
+	for _, r := range rules {
+		if r.Key == "" {
+			return nil, fmt.Errorf("no key set for expression %q", r.Expression)
+		}
+
+		c := &rule{key: r.Key}
+		var err error
+		c.expression, err = parse(r.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression for %q: %v", r.Key, err)
+		}
+		if r.Condition != "" {
+			c.condition, err = parse(r.Condition)
+			if err != nil {
+				return nil, fmt.Errorf("invalid condition for %q: %v", r.Key, err)
+			}
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+func (e *Expression) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	if !e.initialized {
+		e.err = e.init()
+		if e.err != nil {
+			e.Log.Errorf("%v, metrics are not processed", e.err)
+		}
+	}
+	if e.err != nil {
+		return in
+	}
+
+	for _, m := range in {
+		for _, r := range e.fields {
+			v, ok := e.eval(r, m)
+			if !ok {
+				continue
+			}
+			if f, isFloat := v.(float64); isFloat && (math.IsNaN(f) || math.IsInf(f, 0)) {
+				e.warn(r, fmt.Errorf("error evaluating %q: %v", r.key, errNotFinite))
+				continue
+			}
+			m.AddField(r.key, v)
+		}
+		for _, r := range e.tags {
+			if v, ok := e.eval(r, m); ok {
+				m.AddTag(r.key, format(v))
+			}
+		}
+	}
+	return in
+}
+
+// eval returns the value of the expression of the rule, and false when the
+// condition is false or the evaluation fails.
+func (e *Expression) eval(r *rule, m telegraf.Metric) (interface{}, bool) {
+	if r.condition != nil {
+		ok, err := evalBool(r.condition, m)
+		if err != nil {
+			e.warn(r, fmt.Errorf("error evaluating condition of %q: %v", r.key, err))
+			return nil, false
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+
+	v, err := r.expression.eval(m)
+	if err != nil {
+		e.warn(r, fmt.Errorf("error evaluating %q: %v", r.key, err))
+		return nil, false
+	}
+	return v, true
+}
+
+// warn logs an error of the rule, at most once per warnInterval so that a rule
+// failing on every metric does not flood the log.
+func (e *Expression) warn(r *rule, err error) {
+	now := e.now()
+	if !r.warned.IsZero() && now.Sub(r.warned) < warnInterval {
+		r.suppressed++
+		return
+	}
+
+	if r.suppressed > 0 {
+		e.Log.Warnf("%v, %d more errors since the last warning", err, r.suppressed)
+	} else {
+		e.Log.Warn(err)
+	}
+	r.warned = now
+	r.suppressed = 0
+}
+
+func init() {
+	processors.Add("expression", func() telegraf.Processor {
+		return New()
+	})
+}
@@ -0,0 +1,297 @@
+package expression
+
+import (
+	"bytes"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEval(t *testing.T) {
+	m := testutil.MustMetric("mem",
+		map[string]string{
+			"host": "localhost",
+			"cpu":  "cpu0",
+		},
+		map[string]interface{}{
+			"used":    int64(25),
+			"total":   int64(100),
+			"rx":      uint64(3),
+			"tx":      uint64(4),
+			"ratio":   0.5,
+			"zero":    int64(0),
+			"enabled": true,
+			"state":   "ok",
+			"big":     uint64(math.MaxUint64),
+			"min":     int64(math.MinInt64),
+			"nan":     math.NaN(),
+		},
+		time.Unix(0, 0))
+
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"1 + 2 * 3", int64(7)},
+		{"(1 + 2) * 3", int64(9)},
+		{"7 / 2", int64(3)},
+		{"7 % 2", int64(1)},
+		{"7.0 / 2", 3.5},
+		{"-used", int64(-25)},
+		{"used / total * 100", int64(0)},
+		{"float(used) / total * 100", 25.0},
+		{"used * 100 / total", int64(25)},
+		{"rx + tx", uint64(7)},
+		{"rx - used", int64(-22)},
+		{"used + ratio", 25.5},
+		{"2u", uint64(2)},
+		{"1e3", 1000.0},
+		{"state + '!'", "ok!"},
+		{`host + "/" + cpu`, "localhost/cpu0"},
+		{`field("used")`, int64(25)},
+		{`tag("host")`, "localhost"},
+		{`has("host") && has("used") && !has("missing")`, true},
+		{"used < total", true},
+		{"used >= total", false},
+		{"rx == 3", true},
+		{"min < rx", true},
+		{"big > used", true},
+		{"ratio == 0.5", true},
+		{"state == 'ok' || missing", true},
+		{"enabled && missing", nil},
+		{"!enabled || used > 10", true},
+		{"enabled != false", true},
+		{`"a" < "b"`, true},
+		{"int(ratio * 3)", int64(1)},
+		{"int('42')", int64(42)},
+		{"uint(used)", uint64(25)},
+		{"float(enabled)", 1.0},
+		{"string(ratio)", "0.5"},
+		{"abs(rx - used)", int64(22)},
+		{"min(used, rx, ratio)", 0.5},
+		{"min(used, rx)", uint64(3)},
+		{"max(used, rx, ratio)", int64(25)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			n, err := parse(tt.expression)
+			require.NoError(t, err)
+			v, err := n.eval(m)
+			if tt.expected == nil {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, v)
+		})
+	}
+}
+
+func TestEvalError(t *testing.T) {
+	m := testutil.MustMetric("mem",
+		map[string]string{
+			"host": "localhost",
+			"cpu":  "cpu0",
+		},
+		map[string]interface{}{
+			"used":    int64(25),
+			"total":   int64(100),
+			"rx":      uint64(3),
+			"tx":      uint64(4),
+			"ratio":   0.5,
+			"zero":    int64(0),
+			"enabled": true,
+			"state":   "ok",
+			"big":     uint64(math.MaxUint64),
+			"min":     int64(math.MinInt64),
+			"nan":     math.NaN(),
+		},
+		time.Unix(0, 0))
+
+	tests := []struct {
+		expression string
+		err        string
+	}{
+		{"used / zero", "division by zero"},
+		{"used % zero", "division by zero"},
+		{"ratio / 0", "division by zero"},
+		{"rx / 0u", "division by zero"},
+		{"1e308 * 10", "result is not a finite number"},
+		{"big + rx", "integer overflow"},
+		{"rx - tx", "integer overflow"},
+		{"big - used", "integer overflow"},
+		{"-min", "integer overflow"},
+		{"min - 1", "integer overflow"},
+		{"min * -1", "integer overflow"},
+		{"missing + 1", `no field or tag "missing"`},
+		{"state * 2", "invalid operation string * int64"},
+		{"state < 1", "invalid comparison string < int64"},
+		{"enabled < true", "invalid comparison bool < bool"},
+		{"used && enabled", "expected bool, found int64"},
+		{"!used", "invalid operation !int64"},
+		{"int('x')", `int: strconv.ParseInt: parsing "x": invalid syntax`},
+		{"uint(-1)", "uint: integer overflow"},
+		{"tag('used')", `tag: no tag "used"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			n, err := parse(tt.expression)
+			require.NoError(t, err)
+			_, err = n.eval(m)
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        string
+	}{
+		{"", "unexpected end of expression at position 0"},
+		{"1 +", "unexpected end of expression at position 3"},
+		{"(1 + 2", "unexpected end of expression at position 6"},
+		{"1 2", `unexpected "2" at position 2`},
+		{"a < b < c", `unexpected "<" at position 6`},
+		{"a = b", `unexpected character '=' at position 2`},
+		{"'abc", "unterminated string at position 0"},
+		{"99999999999999999999", `invalid number "99999999999999999999" at position 0: strconv.ParseInt: parsing "99999999999999999999": value out of range`},
+		{"sqrt(2)", `unknown function "sqrt" at position 0`},
+		{"abs(1, 2)", "wrong number of arguments for abs at position 0"},
+		{"max(1)", "wrong number of arguments for max at position 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := parse(tt.expression)
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseString(t *testing.T) {
+	n, err := parse(`'it\'s "quoted"\n' + "\tdouble"`)
+	require.NoError(t, err)
+	v, err := n.eval(testutil.MustMetric("cpu",
+		map[string]string{},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 0)))
+	require.NoError(t, err)
+	require.Equal(t, "it's \"quoted\"\n\tdouble", v)
+}
+
+func TestApply(t *testing.T) {
+	e := New()
+	e.Log = testutil.Logger{}
+	e.Fields = []Rule{
+		{Key: "used_percent", Expression: "float(used) / total * 100"},
+		{Key: "traffic", Expression: "rx + tx"},
+		{Key: "per_used", Expression: "total / zero"},
+		{Key: "skipped", Expression: "1", Condition: "zero > 0"},
+		{Key: "not_finite", Expression: "nan * 2"},
+		{Key: "nan_copy", Expression: "nan"},
+	}
+	e.Tags = []Rule{
+		{Key: "level", Expression: "used_percent >= 20"},
+		{Key: "ratio", Expression: "ratio", Condition: "has('ratio')"},
+	}
+
+	m := testutil.MustMetric("mem",
+		map[string]string{},
+		map[string]interface{}{
+			"used":  int64(25),
+			"total": int64(100),
+			"rx":    uint64(3),
+			"tx":    uint64(4),
+			"zero":  int64(0),
+			"ratio": 0.25,
+			"nan":   math.NaN(),
+		},
+		time.Unix(0, 0))
+	out := e.Apply(m)
+	require.Len(t, out, 1)
+
+	require.Equal(t, map[string]string{"level": "true", "ratio": "0.25"}, out[0].Tags())
+	fields := out[0].Fields()
+	require.Equal(t, 25.0, fields["used_percent"])
+	require.Equal(t, uint64(7), fields["traffic"])
+	require.NotContains(t, fields, "per_used")
+	require.NotContains(t, fields, "skipped")
+	require.NotContains(t, fields, "not_finite")
+	require.NotContains(t, fields, "nan_copy")
+}
+
+func TestInvalidConfig(t *testing.T) {
+	m := testutil.MustMetric("cpu",
+		map[string]string{},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 0))
+	for _, rules := range []struct {
+		fields []Rule
+		tags   []Rule
+	}{
+		{fields: []Rule{{Expression: "1"}}},
+		{fields: []Rule{{Key: "a", Expression: "1 +"}}},
+		{tags: []Rule{{Key: "a", Expression: "1", Condition: "("}}},
+	} {
+		e := New()
+		e.Log = testutil.Logger{}
+		e.Fields = rules.fields
+		e.Tags = rules.tags
+		require.Equal(t, []telegraf.Metric{m}, e.Apply(m))
+		require.Error(t, e.err)
+	}
+}
+
+func TestWarnInterval(t *testing.T) {
+	now := time.Unix(1000, 0)
+	e := New()
+	e.Log = testutil.Logger{}
+	e.now = func() time.Time { return now }
+	e.Fields = []Rule{
+		{Key: "a", Expression: "value / 0"},
+		{Key: "b", Expression: "1", Condition: "missing"},
+	}
+	m := testutil.MustMetric("cpu",
+		map[string]string{},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 0))
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	warnings := func() []string {
+		defer buf.Reset()
+		return strings.Split(strings.TrimSpace(buf.String()), "\n")
+	}
+
+	// The first error of each rule is logged, the next ones are counted.
+	for i := 0; i < 3; i++ {
+		e.Apply(m)
+	}
+	out := warnings()
+	require.Len(t, out, 2)
+	require.Contains(t, out[0], `W! [] error evaluating "a": division by zero`)
+	require.Contains(t, out[1], `W! [] error evaluating condition of "b": no field or tag "missing"`)
+
+	now = now.Add(warnInterval - time.Second)
+	e.Apply(m)
+	require.Empty(t, buf.String())
+
+	now = now.Add(time.Second)
+	e.Apply(m)
+	out = warnings()
+	require.Len(t, out, 2)
+	require.Contains(t, out[0], `error evaluating "a": division by zero, 3 more errors since the last warning`)
+	require.Contains(t, out[1], `error evaluating condition of "b": no field or tag "missing", 3 more errors since the last warning`)
+
+	e.Apply(m)
+	require.Empty(t, buf.String())
+}
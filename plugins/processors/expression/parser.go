@@ -0,0 +1,392 @@
+package expression
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators are sorted so that the longest operators are matched first.
+var operators = []string{
+	"||", "&&", "==", "!=", "<=", ">=",
+	"<", ">", "+", "-", "*", "/", "%", "!", "(", ")", ",",
+}
+
+// tokenize splits the expression into tokens, ending with tokenEOF.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r >= '0' && r <= '9' || r == '.':
+			end := scanNumber(s, i)
+			tokens = append(tokens, token{tokenNumber, s[i:end], i})
+			i = end
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(s) && s[end] != byte(r) {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, token{tokenString, s[i : end+1], i})
+			i = end + 1
+		case r == '_' || unicode.IsLetter(r):
+			end := i
+			for end < len(s) {
+				r, size := utf8.DecodeRuneInString(s[end:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				end += size
+			}
+			tokens = append(tokens, token{tokenIdent, s[i:end], i})
+			i = end
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+			tokens = append(tokens, token{tokenOperator, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(s)}), nil
+}
+
+// scanNumber returns the end of the number starting at i, including a
+// fraction, an exponent and the unsigned suffix.
+func scanNumber(s string, i int) int {
+	digits := func() {
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+	}
+	digits()
+	if i < len(s) && s[i] == '.' {
+		i++
+		digits()
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		digits()
+	}
+	if i < len(s) && s[i] == 'u' {
+		i++
+	}
+	return i
+}
+
+// parser is a recursive descent parser, with one method per precedence level
+// from the lowest to the highest.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse compiles the expression.
+func parse(s string) (node, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the operators.
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return p.unexpected(p.peek())
+	}
+	return nil
+}
+
+func (p *parser) unexpected(t token) error {
+	return fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+func (p *parser) or() (node, error) {
+	x, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return x, nil
+		}
+		y, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		x = &logical{op: "||", x: x, y: y}
+	}
+}
+
+func (p *parser) and() (node, error) {
+	x, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return x, nil
+		}
+		y, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		x = &logical{op: "&&", x: x, y: y}
+	}
+}
+
+// comparison does not associate, a < b < c is an error.
+func (p *parser) comparison() (node, error) {
+	x, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=")
+	if !ok {
+		return x, nil
+	}
+	y, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	return &binary{op: op, x: x, y: y}, nil
+}
+
+func (p *parser) additive() (node, error) {
+	x, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return x, nil
+		}
+		y, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op: op, x: x, y: y}
+	}
+}
+
+func (p *parser) multiplicative() (node, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return x, nil
+		}
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op: op, x: x, y: y}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	op, ok := p.accept("!", "-")
+	if !ok {
+		return p.primary()
+	}
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return &unary{op: op, x: x}, nil
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		v, err := parseNumber(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at position %d: %v", t, t.pos, err)
+		}
+		return &literal{value: v}, nil
+	case tokenString:
+		v, err := parseString(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s at position %d", t.text, t.pos)
+		}
+		return &literal{value: v}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.call(t)
+		}
+		return &identifier{name: t.text}, nil
+	case tokenOperator:
+		if t.text == "(" {
+			x, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	}
+	return nil, p.unexpected(t)
+}
+
+// call parses the arguments of a function call, the opening parenthesis is
+// already consumed.
+func (p *parser) call(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at position %d", name, name.pos)
+	}
+
+	var args []node
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments for %s at position %d", name.text, name.pos)
+	}
+	return &call{name: name.text, fn: fn.fn, args: args}, nil
+}
+
+// parseNumber parses integers as int64, integers with the u suffix as uint64,
+// and numbers with a fraction or an exponent as float64.
+func parseNumber(s string) (interface{}, error) {
+	if strings.HasSuffix(s, "u") {
+		return strconv.ParseUint(strings.TrimSuffix(s, "u"), 10, 64)
+	}
+	if strings.ContainsAny(s, ".eE") {
+		return strconv.ParseFloat(s, 64)
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// parseString unquotes a string literal, single quoted strings use the same
+// escapes as double quoted ones.
+func parseString(s string) (string, error) {
+	if s[0] == '"' {
+		return strconv.Unquote(s)
+	}
+
+	var b bytes.Buffer
+	b.WriteByte('"')
+	for i := 1; i < len(s)-1; i++ {
+		switch {
+		case s[i] == '\\' && s[i+1] == '\'':
+			b.WriteByte('\'')
+			i++
+		case s[i] == '\\':
+			b.WriteString(s[i : i+2])
+			i++
+		case s[i] == '"':
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	b.WriteByte('"')
+	return strconv.Unquote(b.String())
+}
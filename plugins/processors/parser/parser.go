@@ -1,9 +1,8 @@
 package parser
 
 import (
-	"log"
-
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/parsers"
 	"github.com/influxdata/telegraf/plugins/processors"
 )
@@ -14,6 +13,7 @@ type Parser struct {
 	Merge        string   `toml:"merge"`
 	ParseFields  []string `toml:"parse_fields"`
 	Parser       parsers.Parser
+	Log          telegraf.Logger `toml:"-"`
 }
 
 var SampleConfig = `
@@ -42,37 +42,42 @@ func (p *Parser) Description() string {
 	return "Parse a value in a specified field/tag(s) and add the result in a new metric"
 }
 
+// SetLogger implements telegraf.LoggerPlugin.
+func (p *Parser) SetLogger(l telegraf.Logger) {
+	p.Log = l
+}
+
 func (p *Parser) Apply(metrics ...telegraf.Metric) []telegraf.Metric {
 	if p.Parser == nil {
 		var err error
 		p.Parser, err = parsers.NewParser(&p.Config)
 		if err != nil {
-			log.Printf("E! [processors.parser] could not create parser: %v", err)
+			p.Log.Errorf("could not create parser: %v", err)
 			return metrics
 		}
 	}
 
 	results := []telegraf.Metric{}
 
-	for _, metric := range metrics {
+	for _, original := range metrics {
 		newMetrics := []telegraf.Metric{}
 		if !p.DropOriginal {
-			newMetrics = append(newMetrics, metric)
+			newMetrics = append(newMetrics, original)
 		}
 
 		for _, key := range p.ParseFields {
-			for _, field := range metric.FieldList() {
+			for _, field := range original.FieldList() {
 				if field.Key == key {
 					switch value := field.Value.(type) {
 					case string:
 						fromFieldMetric, err := p.parseField(value)
 						if err != nil {
-							log.Printf("E! [processors.parser] could not parse field %s: %v", key, err)
+							p.Log.Errorf("could not parse field %s: %v", key, err)
 						}
 
 						for _, m := range fromFieldMetric {
 							if m.Name() == "" {
-								m.SetName(metric.Name())
+								m.SetName(original.Name())
 							}
 						}
 
@@ -81,21 +86,32 @@ func (p *Parser) Apply(metrics ...telegraf.Metric) []telegraf.Metric {
 						// prior to returning.
 						newMetrics = append(newMetrics, fromFieldMetric...)
 					default:
-						log.Printf("E! [processors.parser] field '%s' not a string, skipping", key)
+						p.Log.Errorf("field '%s' not a string, skipping", key)
 					}
 				}
 			}
 		}
 
 		if len(newMetrics) == 0 {
+			original.Drop()
 			continue
 		}
 
 		if p.Merge == "override" {
-			results = append(results, merge(newMetrics[0], newMetrics[1:]))
-		} else {
-			results = append(results, newMetrics...)
+			newMetrics = []telegraf.Metric{merge(newMetrics[0], newMetrics[1:])}
 		}
+
+		// The parsed metrics are delivered along with the original metric.
+		derivation := metric.NewDerivation(original)
+		for i, m := range newMetrics {
+			newMetrics[i] = derivation.Track(m)
+		}
+		derivation.Done()
+		if p.DropOriginal {
+			original.Drop()
+		}
+
+		results = append(results, newMetrics...)
 	}
 	return results
 }
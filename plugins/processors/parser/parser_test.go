@@ -7,6 +7,7 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -523,6 +524,7 @@ func TestApply(t *testing.T) {
 				ParseFields:  tt.parseFields,
 				DropOriginal: tt.dropOriginal,
 				Merge:        tt.merge,
+				Log:          testutil.Logger{},
 			}
 
 			output := parser.Apply(tt.input)
@@ -595,6 +597,7 @@ func TestBadApply(t *testing.T) {
 			parser := Parser{
 				Config:      tt.config,
 				ParseFields: tt.parseFields,
+				Log:         testutil.Logger{},
 			}
 
 			output := parser.Apply(tt.input)
@@ -668,3 +671,35 @@ func BenchmarkFields(b *testing.B) {
 		getMetricFields(metric)
 	}
 }
+
+func TestApplyTracking(t *testing.T) {
+	var delivered []telegraf.DeliveryInfo
+	input, _ := metric.WithTracking(
+		Metric(metric.New(
+			"success",
+			map[string]string{},
+			map[string]interface{}{
+				"sample": `{"value": 42}`,
+			},
+			time.Unix(0, 0))),
+		func(info telegraf.DeliveryInfo) {
+			delivered = append(delivered, info)
+		})
+
+	parser := Parser{
+		Config: parsers.Config{
+			DataFormat: "json",
+		},
+		ParseFields:  []string{"sample"},
+		DropOriginal: true,
+		Log:          testutil.Logger{},
+	}
+
+	output := parser.Apply(input)
+	require.Len(t, output, 1)
+	require.Len(t, delivered, 0)
+
+	output[0].Accept()
+	require.Len(t, delivered, 1)
+	require.True(t, delivered[0].Delivered())
+}
@@ -0,0 +1,417 @@
+package starlark
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// dict is implemented by the tags and fields of a metric, which behave as
+// dicts in the script.
+type dict interface {
+	starlark.HasSetKey
+	Iterate() starlark.Iterator
+	Items() []starlark.Tuple
+	Len() int
+	// remove deletes the key and returns its value, if the key is present.
+	remove(key starlark.Value) (starlark.Value, bool, error)
+}
+
+// TagDict is the script value of the tags of a metric.
+type TagDict struct {
+	m *Metric
+}
+
+func (d *TagDict) String() string       { return dictString(d) }
+func (d *TagDict) Type() string         { return "Tags" }
+func (d *TagDict) Freeze()              { d.m.Freeze() }
+func (d *TagDict) Truth() starlark.Bool { return d.Len() != 0 }
+func (d *TagDict) Hash() (uint32, error) {
+	return 0, errors.New("unhashable type: Tags")
+}
+
+func (d *TagDict) Len() int {
+	if d.m.metric == nil {
+		return 0
+	}
+	return len(d.m.metric.TagList())
+}
+
+func (d *TagDict) AttrNames() []string                      { return dictAttrNames() }
+func (d *TagDict) Attr(name string) (starlark.Value, error) { return dictAttr(d, name) }
+func (d *TagDict) Iterate() starlark.Iterator               { return iterateKeys(d) }
+
+func (d *TagDict) Get(key starlark.Value) (starlark.Value, bool, error) {
+	if err := d.m.check(false); err != nil {
+		return nil, false, err
+	}
+	k, ok := key.(starlark.String)
+	if !ok {
+		return nil, false, nil
+	}
+	v, ok := d.m.metric.GetTag(string(k))
+	if !ok {
+		return nil, false, nil
+	}
+	return starlark.String(v), true, nil
+}
+
+func (d *TagDict) SetKey(key, value starlark.Value) error {
+	if err := d.m.check(true); err != nil {
+		return err
+	}
+	k, ok := key.(starlark.String)
+	if !ok {
+		return fmt.Errorf("tag key must be a string, not %s", key.Type())
+	}
+	v, ok := value.(starlark.String)
+	if !ok {
+		return fmt.Errorf("tag value must be a string, not %s", value.Type())
+	}
+	d.m.metric.AddTag(string(k), string(v))
+	return nil
+}
+
+func (d *TagDict) Items() []starlark.Tuple {
+	if d.m.metric == nil {
+		return nil
+	}
+	items := make([]starlark.Tuple, 0, d.Len())
+	for _, tag := range d.m.metric.TagList() {
+		items = append(items, starlark.Tuple{starlark.String(tag.Key), starlark.String(tag.Value)})
+	}
+	return items
+}
+
+func (d *TagDict) remove(key starlark.Value) (starlark.Value, bool, error) {
+	v, found, err := d.Get(key)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	if err := d.m.check(true); err != nil {
+		return nil, false, err
+	}
+	d.m.metric.RemoveTag(string(key.(starlark.String)))
+	return v, true, nil
+}
+
+// FieldDict is the script value of the fields of a metric.
+type FieldDict struct {
+	m *Metric
+}
+
+func (d *FieldDict) String() string       { return dictString(d) }
+func (d *FieldDict) Type() string         { return "Fields" }
+func (d *FieldDict) Freeze()              { d.m.Freeze() }
+func (d *FieldDict) Truth() starlark.Bool { return d.Len() != 0 }
+func (d *FieldDict) Hash() (uint32, error) {
+	return 0, errors.New("unhashable type: Fields")
+}
+
+func (d *FieldDict) Len() int {
+	if d.m.metric == nil {
+		return 0
+	}
+	return len(d.m.metric.FieldList())
+}
+
+func (d *FieldDict) AttrNames() []string                      { return dictAttrNames() }
+func (d *FieldDict) Attr(name string) (starlark.Value, error) { return dictAttr(d, name) }
+func (d *FieldDict) Iterate() starlark.Iterator               { return iterateKeys(d) }
+
+func (d *FieldDict) Get(key starlark.Value) (starlark.Value, bool, error) {
+	if err := d.m.check(false); err != nil {
+		return nil, false, err
+	}
+	k, ok := key.(starlark.String)
+	if !ok {
+		return nil, false, nil
+	}
+	v, ok := d.m.metric.GetField(string(k))
+	if !ok {
+		return nil, false, nil
+	}
+	sv, err := toStarlark(v)
+	if err != nil {
+		return nil, false, err
+	}
+	return sv, true, nil
+}
+
+func (d *FieldDict) SetKey(key, value starlark.Value) error {
+	if err := d.m.check(true); err != nil {
+		return err
+	}
+	k, ok := key.(starlark.String)
+	if !ok {
+		return fmt.Errorf("field key must be a string, not %s", key.Type())
+	}
+	v, err := fromStarlark(value)
+	if err != nil {
+		return err
+	}
+	d.m.metric.AddField(string(k), v)
+	return nil
+}
+
+func (d *FieldDict) Items() []starlark.Tuple {
+	if d.m.metric == nil {
+		return nil
+	}
+	items := make([]starlark.Tuple, 0, d.Len())
+	for _, field := range d.m.metric.FieldList() {
+		v, err := toStarlark(field.Value)
+		if err != nil {
+			continue
+		}
+		items = append(items, starlark.Tuple{starlark.String(field.Key), v})
+	}
+	return items
+}
+
+func (d *FieldDict) remove(key starlark.Value) (starlark.Value, bool, error) {
+	v, found, err := d.Get(key)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	if err := d.m.check(true); err != nil {
+		return nil, false, err
+	}
+	d.m.metric.RemoveField(string(key.(starlark.String)))
+	return v, true, nil
+}
+
+// toStarlark converts a field value to a script value.
+func toStarlark(value interface{}) (starlark.Value, error) {
+	switch v := value.(type) {
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case uint64:
+		return starlark.MakeUint64(v), nil
+	case float64:
+		return starlark.Float(v), nil
+	case string:
+		return starlark.String(v), nil
+	case bool:
+		return starlark.Bool(v), nil
+	}
+	return nil, fmt.Errorf("unsupported field type %T", value)
+}
+
+// fromStarlark converts a script value to a field value.
+func fromStarlark(value starlark.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i, nil
+		}
+		if u, ok := v.Uint64(); ok {
+			return u, nil
+		}
+		return nil, errors.New("field value is out of range")
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Bool:
+		return bool(v), nil
+	}
+	return nil, fmt.Errorf("field value must be an int, float, string or bool, not %s", value.Type())
+}
+
+func dictString(d dict) string {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, item := range d.Items() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(item[0].String())
+		b.WriteString(": ")
+		b.WriteString(item[1].String())
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// iterateKeys iterates over a snapshot of the keys, so the dict can be
+// modified while iterating.
+func iterateKeys(d dict) starlark.Iterator {
+	items := d.Items()
+	keys := make([]starlark.Value, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, item[0])
+	}
+	return starlark.NewList(keys).Iterate()
+}
+
+type dictMethod func(name string, d dict, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error)
+
+var dictMethods = map[string]dictMethod{
+	"clear":      dictClear,
+	"get":        dictGet,
+	"items":      dictItems,
+	"keys":       dictKeys,
+	"pop":        dictPop,
+	"setdefault": dictSetdefault,
+	"update":     dictUpdate,
+	"values":     dictValues,
+}
+
+func dictAttrNames() []string {
+	names := make([]string, 0, len(dictMethods))
+	for name := range dictMethods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dictAttr returns the method bound to the dict, or nil if there is no such
+// method.
+func dictAttr(d dict, name string) (starlark.Value, error) {
+	method, ok := dictMethods[name]
+	if !ok {
+		return nil, nil
+	}
+	impl := func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		return method(b.Name(), b.Receiver().(dict), args, kwargs)
+	}
+	return starlark.NewBuiltin(name, impl).BindReceiver(d), nil
+}
+
+func dictClear(name string, d dict, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(name, args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	for _, item := range d.Items() {
+		if _, _, err := d.remove(item[0]); err != nil {
+			return nil, err
+		}
+	}
+	return starlark.None, nil
+}
+
+func dictGet(name string, d dict, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key, dflt starlark.Value = nil, starlark.None
+	if err := starlark.UnpackPositionalArgs(name, args, kwargs, 1, &key, &dflt); err != nil {
+		return nil, err
+	}
+	v, found, err := d.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return dflt, nil
+	}
+	return v, nil
+}
+
+func dictItems(name string, d dict, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(name, args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	items := d.Items()
+	values := make([]starlark.Value, 0, len(items))
+	for _, item := range items {
+		values = append(values, item)
+	}
+	return starlark.NewList(values), nil
+}
+
+func dictKeys(name string, d dict, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	return dictColumn(name, d, args, kwargs, 0)
+}
+
+func dictValues(name string, d dict, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	return dictColumn(name, d, args, kwargs, 1)
+}
+
+// dictColumn returns a list of the keys, or the values, of the dict.
+func dictColumn(name string, d dict, args starlark.Tuple, kwargs []starlark.Tuple, column int) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(name, args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	items := d.Items()
+	values := make([]starlark.Value, 0, len(items))
+	for _, item := range items {
+		values = append(values, item[column])
+	}
+	return starlark.NewList(values), nil
+}
+
+func dictPop(name string, d dict, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key, dflt starlark.Value
+	if err := starlark.UnpackPositionalArgs(name, args, kwargs, 1, &key, &dflt); err != nil {
+		return nil, err
+	}
+	v, found, err := d.remove(key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if dflt == nil {
+			return nil, fmt.Errorf("%s: missing key %s", name, key)
+		}
+		return dflt, nil
+	}
+	return v, nil
+}
+
+func dictSetdefault(name string, d dict, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key, dflt starlark.Value = nil, starlark.None
+	if err := starlark.UnpackPositionalArgs(name, args, kwargs, 1, &key, &dflt); err != nil {
+		return nil, err
+	}
+	v, found, err := d.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return v, nil
+	}
+	if err := d.SetKey(key, dflt); err != nil {
+		return nil, err
+	}
+	return dflt, nil
+}
+
+// dictUpdate sets the keys of a mapping or of an iterable of pairs, and of the
+// keyword arguments.
+func dictUpdate(name string, d dict, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("%s: got %d arguments, want at most 1", name, len(args))
+	}
+
+	var pairs []starlark.Tuple
+	if len(args) == 1 {
+		switch updates := args[0].(type) {
+		case starlark.IterableMapping:
+			pairs = updates.Items()
+		case starlark.Iterable:
+			iter := updates.Iterate()
+			defer iter.Done()
+			var x starlark.Value
+			for i := 0; iter.Next(&x); i++ {
+				pair, ok := x.(starlark.Indexable)
+				if !ok || pair.Len() != 2 {
+					return nil, fmt.Errorf("%s: element #%d is not a pair", name, i)
+				}
+				pairs = append(pairs, starlark.Tuple{pair.Index(0), pair.Index(1)})
+			}
+		default:
+			return nil, fmt.Errorf("%s: got %s, want iterable", name, args[0].Type())
+		}
+	}
+	pairs = append(pairs, kwargs...)
+
+	for _, pair := range pairs {
+		if err := d.SetKey(pair[0], pair[1]); err != nil {
+			return nil, err
+		}
+	}
+	return starlark.None, nil
+}
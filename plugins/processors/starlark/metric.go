@@ -0,0 +1,145 @@
+package starlark
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"go.starlark.net/starlark"
+)
+
+// errInvalidMetric is returned when using a metric which was passed to or
+// returned from apply in a previous call.
+var errInvalidMetric = errors.New("metric is no longer valid, use deepcopy to keep a metric")
+
+// Metric is the script value of a telegraf.Metric.
+type Metric struct {
+	metric telegraf.Metric
+	frozen bool
+}
+
+// invalidate prevents further use of the metric by the script.
+func (m *Metric) invalidate() {
+	m.metric = nil
+}
+
+// check returns an error if the metric cannot be used, or modified.
+func (m *Metric) check(modify bool) error {
+	if m.metric == nil {
+		return errInvalidMetric
+	}
+	if modify && m.frozen {
+		return errors.New("cannot modify frozen metric")
+	}
+	return nil
+}
+
+func (m *Metric) String() string {
+	if m.metric == nil {
+		return "Metric(<invalid>)"
+	}
+	return fmt.Sprintf("Metric(%q, tags=%s, fields=%s, time=%d)",
+		m.metric.Name(), &TagDict{m}, &FieldDict{m}, m.metric.Time().UnixNano())
+}
+
+func (m *Metric) Type() string {
+	return "Metric"
+}
+
+func (m *Metric) Freeze() {
+	m.frozen = true
+}
+
+func (m *Metric) Truth() starlark.Bool {
+	return starlark.True
+}
+
+func (m *Metric) Hash() (uint32, error) {
+	return 0, errors.New("unhashable type: Metric")
+}
+
+func (m *Metric) AttrNames() []string {
+	return []string{"fields", "name", "tags", "time"}
+}
+
+func (m *Metric) Attr(name string) (starlark.Value, error) {
+	if err := m.check(false); err != nil {
+		return nil, err
+	}
+	switch name {
+	case "name":
+		return starlark.String(m.metric.Name()), nil
+	case "tags":
+		return &TagDict{m}, nil
+	case "fields":
+		return &FieldDict{m}, nil
+	case "time":
+		return starlark.MakeInt64(m.metric.Time().UnixNano()), nil
+	}
+	return nil, nil
+}
+
+func (m *Metric) SetField(name string, value starlark.Value) error {
+	if err := m.check(true); err != nil {
+		return err
+	}
+	switch name {
+	case "name":
+		s, ok := value.(starlark.String)
+		if !ok {
+			return fmt.Errorf("name must be a string, not %s", value.Type())
+		}
+		m.metric.SetName(string(s))
+		return nil
+	case "time":
+		i, ok := value.(starlark.Int)
+		if !ok {
+			return fmt.Errorf("time must be an int, not %s", value.Type())
+		}
+		ns, ok := i.Int64()
+		if !ok {
+			return errors.New("time is out of range")
+		}
+		m.metric.SetTime(time.Unix(0, ns))
+		return nil
+	case "tags", "fields":
+		return fmt.Errorf("cannot set %s, modify them in place", name)
+	}
+	return starlark.NoSuchAttrError(fmt.Sprintf("Metric has no .%s field", name))
+}
+
+// newMetric implements the Metric builtin, creating a metric without tags or
+// fields.
+func newMetric(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &name); err != nil {
+		return nil, err
+	}
+
+	m, err := metric.New(name, nil, nil, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &Metric{metric: m}, nil
+}
+
+// deepcopy implements the deepcopy builtin, the copy is not tracked with the
+// metric.
+func deepcopy(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var src *Metric
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &src); err != nil {
+		return nil, err
+	}
+	if err := src.check(false); err != nil {
+		return nil, err
+	}
+
+	m, err := metric.New(src.metric.Name(), src.metric.Tags(), src.metric.Fields(),
+		src.metric.Time(), src.metric.Type())
+	if err != nil {
+		return nil, err
+	}
+	return &Metric{metric: m}, nil
+}
@@ -0,0 +1,222 @@
+package starlark
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+)
+
+var sampleConfig = `
+  ## The Starlark source can be set as a string in this configuration file, or
+  ## by referencing a file containing the script.  Only one of source or script
+  ## can be set.
+  ##
+  ## Source of the Starlark script.
+  source = '''
+def apply(metric):
+	return metric
+'''
+
+  ## File containing a Starlark script.
+  # script = "/usr/local/bin/myscript.star"
+
+  ## Maximum number of steps executed by each call of apply, a call over the
+  ## limit is aborted and its metric rejected.
+  # max_execution_steps = 1000000
+`
+
+const defaultMaxExecutionSteps = 1000000
+
+// Starlark runs the apply function of a Starlark script on each metric.
+//
+// The globals of the script are frozen once it is loaded, values which are
+// kept between calls are stored in the predeclared state dict.
+type Starlark struct {
+	Source            string `toml:"source"`
+	Script            string `toml:"script"`
+	MaxExecutionSteps int    `toml:"max_execution_steps"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	initialized bool
+	err         error
+	thread      *starlark.Thread
+	apply       *starlark.Function
+	state       *starlark.Dict
+}
+
+func (s *Starlark) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *Starlark) Description() string {
+	return "Process metrics using a Starlark script"
+}
+
+// SetLogger implements telegraf.LoggerPlugin.
+func (s *Starlark) SetLogger(l telegraf.Logger) {
+	s.Log = l
+}
+
+func (s *Starlark) init() error {
+	s.initialized = true
+	if (s.Source == "") == (s.Script == "") {
+		return errors.New("exactly one of source or script must be set")
+	}
+	if s.MaxExecutionSteps == 0 {
+		s.MaxExecutionSteps = defaultMaxExecutionSteps
+	}
+	if s.MaxExecutionSteps < 0 {
+		return fmt.Errorf("max_execution_steps must be positive, found %d", s.MaxExecutionSteps)
+	}
+
+	s.thread = &starlark.Thread{
+		Name: "processors.starlark",
+		Print: func(_ *starlark.Thread, msg string) {
+			s.Log.Info(msg)
+		},
+	}
+	s.state = starlark.NewDict(0)
+	predeclared := starlark.StringDict{
+		"Metric":   starlark.NewBuiltin("Metric", newMetric),
+		"deepcopy": starlark.NewBuiltin("deepcopy", deepcopy),
+		"state":    s.state,
+	}
+
+	// The script is read from the file when no source is given.
+	filename, src := s.Script, interface{}(nil)
+	if s.Source != "" {
+		filename, src = "processor.star", s.Source
+	}
+	_, program, err := starlark.SourceProgram(filename, src, predeclared.Has)
+	if err != nil {
+		return err
+	}
+	s.limit()
+	globals, err := program.Init(s.thread, predeclared)
+	if err != nil {
+		return err
+	}
+	globals.Freeze()
+
+	apply, ok := globals["apply"].(*starlark.Function)
+	if !ok {
+		return errors.New("apply function is not defined")
+	}
+	if apply.NumParams() != 1 {
+		return errors.New("apply function must take one parameter")
+	}
+	s.apply = apply
+	return nil
+}
+
+func (s *Starlark) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	if !s.initialized {
+		s.err = s.init()
+		if s.err != nil {
+			s.logError("could not load script", s.err)
+		}
+	}
+	if s.err != nil {
+		return in
+	}
+
+	var out []telegraf.Metric
+	for _, m := range in {
+		out = append(out, s.applyMetric(m)...)
+	}
+	return out
+}
+
+// applyMetric calls apply with the metric and returns its results.  The
+// metrics created by the script are delivered along with the original.
+func (s *Starlark) applyMetric(original telegraf.Metric) []telegraf.Metric {
+	wrapper := &Metric{metric: original}
+	defer wrapper.invalidate()
+
+	s.limit()
+	rv, err := starlark.Call(s.thread, s.apply, starlark.Tuple{wrapper}, nil)
+	if err != nil {
+		s.logError("apply failed", err)
+		original.Reject()
+		return nil
+	}
+
+	var results []starlark.Value
+	switch rv := rv.(type) {
+	case starlark.NoneType:
+	case *starlark.List:
+		for i := 0; i < rv.Len(); i++ {
+			results = append(results, rv.Index(i))
+		}
+	default:
+		results = append(results, rv)
+	}
+
+	out := make([]telegraf.Metric, 0, len(results))
+	returned := false
+	derivation := metric.NewDerivation(original)
+	for _, result := range results {
+		w, ok := result.(*Metric)
+		if !ok {
+			s.Log.Errorf("apply returned %s, expected Metric", result.Type())
+			continue
+		}
+		if w.metric == nil {
+			s.Log.Error("apply returned a metric twice or a metric of a previous call")
+			continue
+		}
+
+		if w.metric == original {
+			returned = true
+			out = append(out, original)
+		} else {
+			out = append(out, derivation.Track(w.metric))
+		}
+		w.invalidate()
+	}
+	derivation.Done()
+	if !returned {
+		original.Drop()
+	}
+	return out
+}
+
+// limit resets the thread for a call, so that each call may execute up to
+// max_execution_steps steps.
+func (s *Starlark) limit() {
+	s.thread.Uncancel()
+	s.thread.Steps = 0
+	s.thread.SetMaxExecutionSteps(uint64(s.MaxExecutionSteps))
+}
+
+// logError logs the error, with the backtrace of errors raised by the script.
+func (s *Starlark) logError(msg string, err error) {
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		s.Log.Errorf("%s:", msg)
+		for _, line := range strings.Split(evalErr.Backtrace(), "\n") {
+			s.Log.Errorf("  %s", line)
+		}
+		return
+	}
+	s.Log.Errorf("%s: %v", msg, err)
+}
+
+func init() {
+	// Metrics commonly have float fields, which the default dialect does not
+	// support.
+	resolve.AllowFloat = true
+	resolve.AllowLambda = true
+	resolve.AllowNestedDef = true
+	resolve.AllowSet = true
+
+	processors.Add("starlark", func() telegraf.Processor {
+		return &Starlark{}
+	})
+}
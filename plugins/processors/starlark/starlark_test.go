@@ -0,0 +1,343 @@
+package starlark
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// withField adds a field after the existing ones, the fields of a metric
+// created from a map are in no particular order.
+func withField(m telegraf.Metric, key string, value interface{}) telegraf.Metric {
+	m.AddField(key, value)
+	return m
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name: "passthrough",
+			source: `
+def apply(metric):
+	return metric
+`,
+			input: []telegraf.Metric{
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 42}, time.Unix(42, 0)),
+			},
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 42}, time.Unix(42, 0)),
+			},
+		},
+		{
+			name: "drop",
+			source: `
+def apply(metric):
+	return None
+`,
+			input: []telegraf.Metric{
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 42}, time.Unix(42, 0)),
+			},
+		},
+		{
+			name: "rename and retime",
+			source: `
+def apply(metric):
+	metric.name = "mem"
+	metric.time = metric.time + 1
+	return metric
+`,
+			input: []telegraf.Metric{
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 42}, time.Unix(42, 0)),
+			},
+			expected: []telegraf.Metric{
+				testutil.MustMetric("mem", nil, map[string]interface{}{"value": 42}, time.Unix(42, 1)),
+			},
+		},
+		{
+			name: "conditional tags",
+			source: `
+def apply(metric):
+	if metric.tags.get("host", "").startswith("db"):
+		metric.tags["role"] = "database"
+	metric.tags.pop("unused", None)
+	return metric
+`,
+			input: []telegraf.Metric{
+				testutil.MustMetric("cpu", map[string]string{"host": "db01", "unused": "x"},
+					map[string]interface{}{"value": 42}, time.Unix(42, 0)),
+				testutil.MustMetric("cpu", map[string]string{"host": "web01"},
+					map[string]interface{}{"value": 42}, time.Unix(42, 0)),
+			},
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu", map[string]string{"host": "db01", "role": "database"},
+					map[string]interface{}{"value": 42}, time.Unix(42, 0)),
+				testutil.MustMetric("cpu", map[string]string{"host": "web01"},
+					map[string]interface{}{"value": 42}, time.Unix(42, 0)),
+			},
+		},
+		{
+			name: "field math",
+			source: `
+def apply(metric):
+	metric.fields["used_percent"] = 100.0 * metric.fields["used"] / metric.fields["total"]
+	metric.fields.update(ok=True, count=len(metric.fields))
+	return metric
+`,
+			input: []telegraf.Metric{
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"used": 1, "total": 4}, time.Unix(42, 0)),
+			},
+			expected: []telegraf.Metric{testutil.MustMetric("cpu", nil, map[string]interface{}{
+				"used":         1,
+				"total":        4,
+				"used_percent": 25.0,
+				"ok":           true,
+				"count":        3,
+			}, time.Unix(42, 0))},
+		},
+		{
+			name: "split fields",
+			source: `
+def apply(metric):
+	metrics = []
+	for k, v in metric.fields.items():
+		m = Metric(metric.name)
+		m.tags.update(metric.tags)
+		m.tags["field"] = k
+		m.fields["value"] = v
+		m.time = metric.time
+		metrics.append(m)
+	return metrics
+`,
+			input: []telegraf.Metric{
+				withField(testutil.MustMetric("cpu", map[string]string{"host": "a"},
+					map[string]interface{}{"user": 1}, time.Unix(42, 0)), "system", 2),
+			},
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu", map[string]string{"host": "a", "field": "user"},
+					map[string]interface{}{"value": 1}, time.Unix(42, 0)),
+				testutil.MustMetric("cpu", map[string]string{"host": "a", "field": "system"},
+					map[string]interface{}{"value": 2}, time.Unix(42, 0)),
+			},
+		},
+		{
+			name: "state",
+			source: `
+def apply(metric):
+	count = state.get("count", 0) + 1
+	state["count"] = count
+	metric.fields["count"] = count
+	return metric
+`,
+			input: []telegraf.Metric{
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 1}, time.Unix(42, 0)),
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 2}, time.Unix(42, 0)),
+			},
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 1, "count": 1}, time.Unix(42, 0)),
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 2, "count": 2}, time.Unix(42, 0)),
+			},
+		},
+		{
+			name: "keep a copy",
+			source: `
+def apply(metric):
+	last = state.pop("last", None)
+	state["last"] = deepcopy(metric)
+	if last == None:
+		return None
+	metric.fields["previous"] = last.fields["value"]
+	return metric
+`,
+			input: []telegraf.Metric{
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 1}, time.Unix(42, 0)),
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 2}, time.Unix(42, 0)),
+			},
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 2, "previous": 1}, time.Unix(42, 0)),
+			},
+		},
+		{
+			name: "duplicate is ignored",
+			source: `
+def apply(metric):
+	return [metric, metric]
+`,
+			input: []telegraf.Metric{
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 42}, time.Unix(42, 0)),
+			},
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu", nil,
+					map[string]interface{}{"value": 42}, time.Unix(42, 0)),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Starlark{Source: tt.source, Log: testutil.Logger{}}
+			var actual []telegraf.Metric
+			for _, m := range tt.input {
+				actual = append(actual, s.Apply(m)...)
+			}
+			require.NoError(t, s.err)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestInvalidSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin *Starlark
+	}{
+		{name: "no source", plugin: &Starlark{}},
+		{name: "source and script", plugin: &Starlark{Source: "def apply(m):\n\treturn m", Script: "x.star"}},
+		{name: "syntax error", plugin: &Starlark{Source: "def apply(m)"}},
+		{name: "no apply", plugin: &Starlark{Source: "x = 1"}},
+		{name: "apply parameters", plugin: &Starlark{Source: "def apply():\n\treturn None"}},
+		{name: "missing script", plugin: &Starlark{Script: "/nonexistent.star"}},
+		{name: "negative steps", plugin: &Starlark{Source: "def apply(m):\n\treturn m", MaxExecutionSteps: -1}},
+		{name: "load over steps", plugin: &Starlark{Source: "x = [i for i in range(1000)]\ndef apply(m):\n\treturn m", MaxExecutionSteps: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = testutil.Logger{}
+			m := testutil.MustMetric("cpu", nil,
+				map[string]interface{}{"value": 42}, time.Unix(42, 0))
+			out := tt.plugin.Apply(m)
+			require.Error(t, tt.plugin.err)
+			require.Equal(t, []telegraf.Metric{m}, out)
+		})
+	}
+}
+
+func TestScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "starlark")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "rename.star")
+	require.NoError(t, ioutil.WriteFile(script, []byte(`
+def apply(metric):
+	metric.name = "renamed"
+	return metric
+`), 0644))
+
+	s := &Starlark{Script: script, Log: testutil.Logger{}}
+	out := s.Apply(testutil.MustMetric("cpu", nil,
+		map[string]interface{}{"value": 42}, time.Unix(42, 0)))
+	require.NoError(t, s.err)
+	require.Len(t, out, 1)
+	require.Equal(t, "renamed", out[0].Name())
+}
+
+func TestInvalidMetricUse(t *testing.T) {
+	s := &Starlark{Source: `
+def apply(metric):
+	last = state.get("last")
+	state["last"] = metric
+	if last != None:
+		last.name = "invalid"
+	return metric
+`, Log: testutil.Logger{}}
+
+	out := s.Apply(testutil.MustMetric("cpu", nil,
+		map[string]interface{}{"value": 1}, time.Unix(42, 0)))
+	require.Len(t, out, 1)
+	first := out[0]
+
+	// The metric of the previous call cannot be used, so apply fails.
+	var delivered []telegraf.DeliveryInfo
+	m, _ := metric.WithTracking(testutil.MustMetric("cpu", nil,
+		map[string]interface{}{"value": 2}, time.Unix(42, 0)),
+		func(di telegraf.DeliveryInfo) { delivered = append(delivered, di) })
+	out = s.Apply(m)
+	require.Len(t, out, 0)
+	require.Len(t, delivered, 1)
+	require.False(t, delivered[0].Delivered())
+	require.Equal(t, "cpu", first.Name())
+}
+
+func TestMaxExecutionSteps(t *testing.T) {
+	s := &Starlark{
+		Source: `
+def apply(metric):
+	total = 0
+	for i in range(metric.fields["n"]):
+		total += i
+	metric.fields["total"] = total
+	return metric
+`,
+		MaxExecutionSteps: 1000,
+		Log:               testutil.Logger{},
+	}
+
+	out := s.Apply(testutil.MustMetric("cpu", nil,
+		map[string]interface{}{"n": 10}, time.Unix(42, 0)))
+	require.NoError(t, s.err)
+	require.Len(t, out, 1)
+
+	var delivered []telegraf.DeliveryInfo
+	m, _ := metric.WithTracking(testutil.MustMetric("cpu", nil,
+		map[string]interface{}{"n": 1000000}, time.Unix(42, 0)),
+		func(di telegraf.DeliveryInfo) { delivered = append(delivered, di) })
+	out = s.Apply(m)
+	require.Len(t, out, 0)
+	require.Len(t, delivered, 1)
+	require.False(t, delivered[0].Delivered())
+
+	// The limit applies to each call.
+	for i := 0; i < 10; i++ {
+		out = s.Apply(testutil.MustMetric("cpu", nil,
+			map[string]interface{}{"n": 10}, time.Unix(42, 0)))
+		require.Len(t, out, 1)
+		require.Equal(t, int64(45), out[0].Fields()["total"])
+	}
+}
+
+func TestCreatedMetricsAreTracked(t *testing.T) {
+	s := &Starlark{Source: `
+def apply(metric):
+	m = Metric("created")
+	m.fields["value"] = 1
+	return m
+`, Log: testutil.Logger{}}
+
+	var delivered []telegraf.DeliveryInfo
+	m, _ := metric.WithTracking(testutil.MustMetric("cpu", nil,
+		map[string]interface{}{"value": 42}, time.Unix(42, 0)),
+		func(di telegraf.DeliveryInfo) { delivered = append(delivered, di) })
+
+	out := s.Apply(m)
+	require.Len(t, out, 1)
+	require.Equal(t, "created", out[0].Name())
+	require.Len(t, delivered, 0)
+
+	out[0].Accept()
+	require.Len(t, delivered, 1)
+	require.True(t, delivered[0].Delivered())
+}
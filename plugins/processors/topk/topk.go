@@ -2,7 +2,6 @@ package topk
 
 import (
 	"fmt"
-	"log"
 	"math"
 	"sort"
 	"time"
@@ -25,6 +24,8 @@ type TopK struct {
 	AddRankFields      []string `toml:"add_rank_fields"`
 	AddAggregateFields []string `toml:"add_aggregate_fields"`
 
+	Log telegraf.Logger `toml:"-"`
+
 	cache           map[string][]telegraf.Metric
 	tagsGlobs       filter.Filter
 	rankFieldSet    map[string]bool
@@ -137,6 +138,11 @@ func (t *TopK) Description() string {
 	return "Print all metrics that pass through this filter."
 }
 
+// SetLogger implements telegraf.LoggerPlugin.
+func (t *TopK) SetLogger(l telegraf.Logger) {
+	t.Log = l
+}
+
 func (t *TopK) generateGroupByKey(m telegraf.Metric) (string, error) {
 	// Create the filter.Filter objects if they have not been created
 	if t.tagsGlobs == nil && len(t.GroupBy) > 0 {
@@ -174,7 +180,7 @@ func (t *TopK) groupBy(m telegraf.Metric) {
 	if err != nil {
 		// If we could not generate the groupkey, fail hard
 		// by dropping this and all subsequent metrics
-		log.Printf("E! [processors.topk]: could not generate group key: %v", err)
+		t.Log.Errorf("could not generate group key: %v", err)
 		return
 	}
 
@@ -269,7 +275,8 @@ func (t *TopK) push() []telegraf.Metric {
 	if err != nil {
 		// If we could not generate the aggregation
 		// function, fail hard by dropping all metrics
-		log.Printf("E! [processors.topk]: %v", err)
+		t.Log.Error(err)
+		t.Reset()
 		return []telegraf.Metric{}
 	}
 	for k, ms := range t.cache {
@@ -342,7 +349,7 @@ func (t *TopK) getAggregationFunction(aggOperation string) (func([]telegraf.Metr
 				}
 				val, ok := convert(fieldVal)
 				if !ok {
-					log.Printf("Cannot convert value '%s' from metric '%s' with tags '%s'",
+					t.Log.Infof("Cannot convert value '%s' from metric '%s' with tags '%s'",
 						m.Fields()[field], m.Name(), m.Tags())
 					continue
 				}
@@ -408,7 +415,7 @@ func (t *TopK) getAggregationFunction(aggOperation string) (func([]telegraf.Metr
 					}
 					val, ok := convert(fieldVal)
 					if !ok {
-						log.Printf("Cannot convert value '%s' from metric '%s' with tags '%s'",
+						t.Log.Infof("Cannot convert value '%s' from metric '%s' with tags '%s'",
 							m.Fields()[field], m.Name(), m.Tags())
 						continue
 					}
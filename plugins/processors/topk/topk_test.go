@@ -6,6 +6,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/testutil"
 )
 
@@ -143,6 +144,7 @@ func TestTopkAggregatorsSmokeTests(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.Fields = []string{"a"}
 	topk.GroupBy = []string{"tag_name"}
@@ -166,6 +168,7 @@ func TestTopkMeanAddAggregateFields(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.Aggregation = "mean"
 	topk.AddAggregateFields = []string{"a"}
@@ -196,6 +199,7 @@ func TestTopkSumAddAggregateFields(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.Aggregation = "sum"
 	topk.AddAggregateFields = []string{"a"}
@@ -226,6 +230,7 @@ func TestTopkMaxAddAggregateFields(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.Aggregation = "max"
 	topk.AddAggregateFields = []string{"a"}
@@ -256,6 +261,7 @@ func TestTopkMinAddAggregateFields(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.Aggregation = "min"
 	topk.AddAggregateFields = []string{"a"}
@@ -286,6 +292,7 @@ func TestTopkGroupby1(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.K = 3
 	topk.Aggregation = "sum"
@@ -312,6 +319,7 @@ func TestTopkGroupby2(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.K = 3
 	topk.Aggregation = "mean"
@@ -342,6 +350,7 @@ func TestTopkGroupby3(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.K = 1
 	topk.Aggregation = "min"
@@ -369,6 +378,7 @@ func TestTopkGroupbyFields1(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.K = 4 // This settings generate less than 3 groups
 	topk.Aggregation = "mean"
@@ -397,6 +407,7 @@ func TestTopkGroupbyFields2(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.K = 2
 	topk.Aggregation = "sum"
@@ -426,6 +437,7 @@ func TestTopkGroupbyMetricName1(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.K = 1
 	topk.Aggregation = "sum"
@@ -453,6 +465,7 @@ func TestTopkGroupbyMetricName2(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.K = 2
 	topk.Aggregation = "sum"
@@ -482,6 +495,7 @@ func TestTopkBottomk(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.K = 3
 	topk.Aggregation = "sum"
@@ -509,6 +523,7 @@ func TestTopkGroupByKeyTag(t *testing.T) {
 	// Build the processor
 	var topk TopK
 	topk = *New()
+	topk.Log = testutil.Logger{}
 	topk.Period = createDuration(1)
 	topk.K = 3
 	topk.Aggregation = "sum"
@@ -530,3 +545,38 @@ func TestTopkGroupByKeyTag(t *testing.T) {
 	// Run the test
 	runAndCompare(&topk, input, answer, "GroupByKeyTag test", t)
 }
+
+// Tracked metrics are delivered when they are cached, so that an input
+// limiting its undelivered metrics does not wait for the end of the period
+func TestTopkTracking(t *testing.T) {
+	var topk TopK
+	topk = *New()
+	topk.Log = testutil.Logger{}
+	topk.Period = createDuration(3600)
+	topk.K = 1
+	topk.Fields = []string{"a"}
+	topk.GroupBy = []string{"tag_name"}
+
+	// Simulate an input with max_undelivered_messages = 2
+	maxUndelivered := 2
+	undelivered := 0
+	notify := func(info telegraf.DeliveryInfo) {
+		undelivered--
+	}
+
+	for i, m := range deepCopy(MetricsSet1) {
+		if undelivered >= maxUndelivered {
+			t.Fatalf("Input stopped after %d metrics waiting for delivery", i)
+		}
+		tm, _ := metric.WithTracking(m, notify)
+		undelivered++
+
+		ret := topk.Apply(tm)
+		if len(ret) != 0 {
+			t.Fatalf("Expected no metrics before the end of the period, got %d", len(ret))
+		}
+	}
+	if undelivered != 0 {
+		t.Fatalf("Expected all metrics to be delivered, got %d undelivered", undelivered)
+	}
+}
@@ -8,5 +8,26 @@ type Processor interface {
 	Description() string
 
 	// Apply the filter to the given metric.
+	//
+	// Metrics which are not returned must be marked with Drop, and metrics
+	// held for a later call remain undelivered until they are returned.
+	// Metrics created from the input should be tracked with a
+	// metric.Derivation, so that the input is delivered along with them.
 	Apply(in ...Metric) []Metric
 }
+
+// ServiceProcessor is a Processor running a service, such as an external
+// program, for as long as it is used.
+type ServiceProcessor interface {
+	Processor
+
+	// Flush returns the metrics output by the service since the last call to
+	// Apply or Flush.  It is called periodically, so that the output does not
+	// wait for the next metrics to be applied.
+	Flush() []Metric
+
+	// Stop stops the service and returns the metrics it output until it
+	// stopped.  It is called once no more metrics are applied, the other
+	// metrics still held by the processor must be dropped.
+	Stop() []Metric
+}
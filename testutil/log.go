@@ -0,0 +1,43 @@
+package testutil
+
+import (
+	"log"
+)
+
+// Logger is a telegraf.Logger for use in plugin tests, messages are written
+// with the log package.
+type Logger struct {
+	Name string
+}
+
+func (l Logger) Errorf(format string, args ...interface{}) {
+	log.Printf("E! ["+l.Name+"] "+format, args...)
+}
+
+func (l Logger) Error(args ...interface{}) {
+	log.Print(append([]interface{}{"E! [" + l.Name + "] "}, args...)...)
+}
+
+func (l Logger) Warnf(format string, args ...interface{}) {
+	log.Printf("W! ["+l.Name+"] "+format, args...)
+}
+
+func (l Logger) Warn(args ...interface{}) {
+	log.Print(append([]interface{}{"W! [" + l.Name + "] "}, args...)...)
+}
+
+func (l Logger) Infof(format string, args ...interface{}) {
+	log.Printf("I! ["+l.Name+"] "+format, args...)
+}
+
+func (l Logger) Info(args ...interface{}) {
+	log.Print(append([]interface{}{"I! [" + l.Name + "] "}, args...)...)
+}
+
+func (l Logger) Debugf(format string, args ...interface{}) {
+	log.Printf("D! ["+l.Name+"] "+format, args...)
+}
+
+func (l Logger) Debug(args ...interface{}) {
+	log.Print(append([]interface{}{"D! [" + l.Name + "] "}, args...)...)
+}